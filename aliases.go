@@ -21,8 +21,6 @@ package main
 import (
 	"errors"
 	"strings"
-
-	"github.com/chzyer/readline"
 )
 
 // ErrInvalidAlias means there is a name conflict with an existing command
@@ -67,11 +65,13 @@ func addAlias(name, command string) {
 	projectData.update()
 
 	// add to completer
-	completer.Children = append(completer.Children, readline.PcItem(name, nil))
+	commandIndex.add(name)
 }
 
 func deleteAlias(name string) {
 	delete(projectData.Aliases, name)
+	projectData.update()
+	commandIndex.remove(name)
 }
 
 // print alias names to stdout
@@ -0,0 +1,134 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// suffix for the previous generation of a file written with writeFileAtomic
+const previousGenerationSuffix = ".prev"
+
+// write data to path atomically
+// the data is written to a temporary file in the same directory, synced and renamed over path,
+// so a crash never leaves a truncated file behind
+// the current content of path is kept as previous generation
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+
+	// clean up in case of an error, after the rename this is a noop
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), perm)
+	if err != nil {
+		return err
+	}
+
+	keepPreviousGeneration(path)
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// keep the current content of path as previous generation
+// a hardlink is used when possible, so path exists at any time
+func keepPreviousGeneration(path string) {
+
+	var prev = path + previousGenerationSuffix
+
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+
+	os.Remove(prev)
+	if err := os.Link(path, prev); err == nil {
+		return
+	}
+
+	c, err := ioutil.ReadFile(path)
+	if err != nil {
+		Log.WithError(err).Debug("failed to read " + path)
+		return
+	}
+
+	err = ioutil.WriteFile(prev, c, 0600)
+	if err != nil {
+		Log.WithError(err).Debug("failed to write " + prev)
+	}
+}
+
+// flush the directory entry after a rename
+// not supported on all platforms, errors are ignored
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// read the file at path and decode it with unmarshal
+// if the file cannot be decoded the previous generation is used
+func readWithFallback(path string, unmarshal func([]byte) error) error {
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	err = unmarshal(contents)
+	if err == nil {
+		return nil
+	}
+
+	prev, prevErr := ioutil.ReadFile(path + previousGenerationSuffix)
+	if prevErr != nil {
+		return err
+	}
+
+	if prevErr = unmarshal(prev); prevErr != nil {
+		return err
+	}
+
+	Log.WithError(err).Warn("failed to decode " + path + ", using the previous generation")
+	return nil
+}
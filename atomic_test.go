@@ -0,0 +1,117 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadWithFallback(t *testing.T) {
+
+	// the fallback to the previous generation is logged
+	defer func(out io.Writer) {
+		Log.Out = out
+	}(Log.Out)
+	Log.Out = ioutil.Discard
+
+	dir, err := ioutil.TempDir("", "zeus-atomic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		current  string
+		previous string
+		want     string
+		valid    bool
+	}{
+		{"current", `{"Name": "current"}`, `{"Name": "previous"}`, "current", true},
+		{"current without previous", `{"Name": "current"}`, "", "current", true},
+		{"corrupt current", `{"Name": "curr`, `{"Name": "previous"}`, "previous", true},
+		{"empty current", ``, `{"Name": "previous"}`, "previous", true},
+		{"corrupt current without previous", `{"Name": "curr`, "", "", false},
+		{"both corrupt", `{"Name": "curr`, `{"Name": "prev`, "", false},
+	}
+
+	for i, test := range tests {
+
+		path := filepath.Join(dir, "data"+string(rune('a'+i))+".json")
+
+		err := ioutil.WriteFile(path, []byte(test.current), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.previous != "" {
+			err = ioutil.WriteFile(path+previousGenerationSuffix, []byte(test.previous), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var v struct {
+			Name string
+		}
+		err = readWithFallback(path, func(b []byte) error {
+			v.Name = ""
+			return json.Unmarshal(b, &v)
+		})
+		if (err == nil) != test.valid {
+			t.Errorf("%s: readWithFallback error = %v, want valid = %v", test.name, err, test.valid)
+			continue
+		}
+		if test.valid && v.Name != test.want {
+			t.Errorf("%s: readWithFallback decoded %q, want %q", test.name, v.Name, test.want)
+		}
+	}
+
+	if err := readWithFallback(filepath.Join(dir, "missing.json"), func([]byte) error { return nil }); !os.IsNotExist(err) {
+		t.Errorf("missing file: readWithFallback error = %v, want a not exist error", err)
+	}
+}
+
+func TestWriteFileAtomicKeepsPreviousGeneration(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "zeus-atomic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "data.json")
+
+	for _, content := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for p, want := range map[string]string{path: "second", path + previousGenerationSuffix: "first"} {
+		got, err := ioutil.ReadFile(p)
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", filepath.Base(p), got, err, want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// path to the append-only audit log
+var auditLogPath = "zeus/audit.log"
+
+// syslog priorities for the local0 facility
+const (
+	syslogInfo  = 16*8 + 6
+	syslogError = 16*8 + 3
+)
+
+// auditEntry is a single executed command in the audit log
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	User     string    `json:"user"`
+	Command  string    `json:"command"`
+	Args     []string  `json:"args"`
+	Dir      string    `json:"dir"`
+	Git      string    `json:"git,omitempty"`
+	ExitCode int       `json:"exitCode"`
+}
+
+// serializes writes to the audit log
+var auditLock sync.Mutex
+
+// record an executed command in the audit log and / or syslog
+func audit(name string, args []string, dir string, err error) {
+
+	if !conf.AuditLog && conf.AuditSyslog == "" {
+		return
+	}
+
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+
+	entry := &auditEntry{
+		Time:     time.Now(),
+		User:     auditUser(),
+		Command:  name,
+		Args:     args,
+		Dir:      dir,
+		Git:      gitRevision(dir),
+		ExitCode: exitCode(err),
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		Log.WithError(err).Error("failed to encode audit entry")
+		return
+	}
+	b = []byte(maskSecrets(string(b)))
+
+	if conf.AuditLog {
+		err = appendAuditLog(b)
+		if err != nil {
+			Log.WithError(err).Error("failed to write audit log")
+		}
+	}
+
+	if conf.AuditSyslog != "" {
+		priority := syslogInfo
+		if entry.ExitCode != 0 {
+			priority = syslogError
+		}
+		err = sendSyslog(conf.AuditSyslog, priority, b)
+		if err != nil {
+			Log.WithError(err).Error("failed to send audit entry to syslog")
+		}
+	}
+}
+
+// append a line to the audit log, existing entries are never modified
+func appendAuditLog(line []byte) error {
+
+	auditLock.Lock()
+	defer auditLock.Unlock()
+
+	f, err := os.OpenFile(auditLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// send a message to the syslog endpoint, for example udp://localhost:514
+func sendSyslog(endpoint string, priority int, msg []byte) error {
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout(u.Scheme, u.Host, 2*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	hostname, _ := os.Hostname()
+
+	// RFC 5424 header
+	header := "<" + strconv.Itoa(priority) + ">1 " + time.Now().Format(time.RFC3339) + " " + hostname + " zeus " + strconv.Itoa(os.Getpid()) + " - - "
+
+	_, err = conn.Write(append([]byte(header), append(msg, '\n')...))
+	return err
+}
+
+// name of the user executing the command
+func auditUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// current git commit of the repository at dir, empty if there is none
+func gitRevision(dir string) string {
+
+	cmd := systemCommand("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
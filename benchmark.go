@@ -0,0 +1,258 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mgutz/ansi"
+)
+
+const (
+	// default number of measured runs
+	defaultBenchmarkRuns = 10
+
+	// default number of runs discarded before measuring
+	defaultBenchmarkWarmup = 1
+)
+
+// benchmarkStats are the wall times of a benchmark
+type benchmarkStats struct {
+	Runs     int
+	Min      time.Duration
+	Median   time.Duration
+	P95      time.Duration
+	Mean     time.Duration
+	StdDev   time.Duration
+	Recorded time.Time
+}
+
+func printBenchmarkUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: bench <command> [args] [--runs <n>] [--warmup <n>] [--save] [-- args]")
+}
+
+// handle bench shell command
+func handleBenchmarkCommand(args []string) {
+
+	if len(args) < 2 {
+		printBenchmarkUsageErr()
+		return
+	}
+
+	var (
+		name        = args[1]
+		runs        = defaultBenchmarkRuns
+		warmup      = defaultBenchmarkWarmup
+		save        bool
+		commandArgs []string
+	)
+
+	for i := 2; i < len(args); i++ {
+		switch args[i] {
+		case "--":
+			commandArgs = append(commandArgs, args[i+1:]...)
+			i = len(args)
+		case "--save":
+			save = true
+		case "--runs", "--warmup":
+			if i+1 >= len(args) {
+				printBenchmarkUsageErr()
+				return
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 0 || (args[i] == "--runs" && n < 1) {
+				printBenchmarkUsageErr()
+				return
+			}
+			if args[i] == "--runs" {
+				runs = n
+			} else {
+				warmup = n
+			}
+			i++
+		default:
+			commandArgs = append(commandArgs, args[i])
+		}
+	}
+
+	commandMutex.Lock()
+	cmd, ok := commands[name]
+	commandMutex.Unlock()
+	if !ok {
+		Log.WithError(ErrUnknownCommand).Error(name)
+		return
+	}
+
+	stats, err := benchmark(cmd, commandArgs, runs, warmup)
+	if err != nil {
+		Log.WithError(err).Error("benchmark of " + name + " failed")
+		return
+	}
+
+	printBenchmark(name, stats, projectData.Benchmarks[name])
+
+	if save {
+		if projectData.Benchmarks == nil {
+			projectData.Benchmarks = make(map[string]*benchmarkStats)
+		}
+		projectData.Benchmarks[name] = stats
+		projectData.update()
+		l.Println(cp.colorText + "saved as baseline" + ansi.Reset)
+	}
+}
+
+// execute the script of the command warmup + runs times with args and measure the wall time of the measured runs
+// only the script is executed, without the chain, the dependencies and the plugins,
+// and nothing is recorded: no audit log, artifacts, snapshots or resource usage
+// the output of the script is discarded
+func benchmark(c *command, args []string, runs, warmup int) (*benchmarkStats, error) {
+
+	if err := checkExecutionAllowed(c.name); err != nil {
+		return nil, err
+	}
+
+	if len(args) != len(c.args) && !(c.discovered && len(c.args) == 0) {
+		if len(args) > len(c.args) {
+			return nil, ErrTooManyArguments
+		}
+		Log.Info("expected: ", getArgumentString(c.args))
+		return nil, ErrNotEnoughArguments
+	}
+
+	// refuse to run scripts that changed since they were approved
+	if conf.PinScripts {
+		if err := c.checkPins(); err != nil {
+			return nil, err
+		}
+	}
+
+	// the script is executed directly when there are no globals
+	if !c.discovered {
+		if err := os.Chmod(c.path, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	env, err := c.baseEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var times []time.Duration
+
+	for i := 0; i < warmup+runs; i++ {
+
+		cmd, _, err := c.newProcess(args)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = env
+		if c.pkg != nil {
+			cmd.Dir = c.pkg.dir
+		} else if clientDir != "" {
+			cmd.Dir = clientDir
+		}
+		cmd.Stdout = ioutil.Discard
+		cmd.Stderr = ioutil.Discard
+
+		start := time.Now()
+		err = cmd.Run()
+		elapsed := time.Since(start)
+
+		if err != nil {
+			return nil, err
+		}
+		if i >= warmup {
+			times = append(times, elapsed)
+		}
+	}
+
+	return newBenchmarkStats(times), nil
+}
+
+// calculate the statistics for the measured times
+func newBenchmarkStats(times []time.Duration) *benchmarkStats {
+
+	sort.Slice(times, func(i, j int) bool {
+		return times[i] < times[j]
+	})
+
+	var sum float64
+	for _, t := range times {
+		sum += float64(t)
+	}
+	mean := sum / float64(len(times))
+
+	var variance float64
+	for _, t := range times {
+		variance += (float64(t) - mean) * (float64(t) - mean)
+	}
+	variance /= float64(len(times))
+
+	median := times[len(times)/2]
+	if len(times)%2 == 0 {
+		median = (times[len(times)/2-1] + times[len(times)/2]) / 2
+	}
+
+	return &benchmarkStats{
+		Runs:     len(times),
+		Min:      times[0],
+		Median:   median,
+		P95:      times[int(math.Ceil(0.95*float64(len(times))))-1],
+		Mean:     time.Duration(mean),
+		StdDev:   time.Duration(math.Sqrt(variance)),
+		Recorded: time.Now(),
+	}
+}
+
+// print the statistics and the difference to the baseline
+func printBenchmark(name string, s, baseline *benchmarkStats) {
+
+	row := func(label string, d time.Duration, base time.Duration) {
+		line := cp.colorText + pad(label, 10) + cp.colorPrompt + pad(d.String(), 16)
+		if baseline != nil && base > 0 {
+			change := (float64(d) - float64(base)) / float64(base) * 100
+			color := ansi.Green
+			if change > 0 {
+				color = ansi.Red
+			}
+			line += cp.colorText + "baseline " + pad(base.String(), 16) + color + strconv.FormatFloat(change, 'f', 1, 64) + "%"
+		}
+		l.Println(line + ansi.Reset)
+	}
+
+	l.Println(cp.colorText + "benchmark " + cp.colorPrompt + name + cp.colorText + ", " + strconv.Itoa(s.Runs) + " runs" + ansi.Reset)
+
+	var b = baseline
+	if b == nil {
+		b = &benchmarkStats{}
+	}
+
+	row("min", s.Min, b.Min)
+	row("median", s.Median, b.Median)
+	row("p95", s.P95, b.P95)
+	row("mean", s.Mean, b.Mean)
+	row("stddev", s.StdDev, b.StdDev)
+}
@@ -60,5 +60,5 @@ func bootstrapCommand() {
 	bootstrapFile("run.sh")
 	bootstrapFile("test.sh")
 	bootstrapFile("install.sh")
-	bootstrapFile("bench.sh")
+	bootstrapFile("benchmarks.sh")
 }
@@ -20,7 +20,6 @@ package main
 
 import (
 	"encoding/json"
-	"io/ioutil"
 	"os"
 	"os/exec"
 	"sort"
@@ -46,6 +45,25 @@ const (
 	dataCommand       = "data"
 	makefileCommand   = "makefile"
 	authorCommand     = "author"
+	bumpCommand       = "bump"
+	workspaceCommand  = "workspace"
+	daemonCommand     = "daemon"
+	outputCommand     = "output"
+	validateCommand   = "validate"
+	pinsCommand       = "pins"
+	signaturesCommand = "signatures"
+	uiCommand         = "ui"
+	benchCommand      = "bench"
+	coverageCommand   = "coverage"
+	watchCommand      = "watch"
+	doctorCommand     = "doctor"
+	queueCommand      = "queue"
+	gcCommand         = "gc"
+	snapshotCommand   = "snapshot"
+	replayCommand     = "replay"
+	cleanCommand      = "clean"
+	busCommand        = "bus"
+	usageCommand      = "usage"
 )
 
 var builtins = map[string]string{
@@ -54,19 +72,38 @@ var builtins = map[string]string{
 	clearCommand:      "clear the terminal screen",
 	infoCommand:       "print project info (lines of code + latest git commits)",
 	formatCommand:     "run the formatter for all scripts",
-	globalsCommand:    "print the current globals",
+	globalsCommand:    "print, set or remove the project globals",
 	configCommand:     "print or change the current config",
 	deadlineCommand:   "print or change the deadline",
 	milestonesCommand: "print, add or remove the milestones",
 	versionCommand:    "print version",
 	eventsCommand:     "print, add or remove events",
-	dataCommand:       "print the current project data",
+	dataCommand:       "print, export or import the project data",
 	aliasCommand:      "print, add or remove aliases",
 	colorsCommand:     "change the current ANSI color profile",
 	makefileCommand:   "show or migrate GNU Makefiles",
 	authorCommand:     "print or change project author name",
 	keysCommand:       "manage keybindings",
 	builtinsCommand:   "print the builtins overview",
+	bumpCommand:       "print or bump the semantic project version",
+	workspaceCommand:  "manage the projects of the user workspace and run commands across them",
+	daemonCommand:     "start, stop or check the project daemon",
+	outputCommand:     "print or search the output of the recent runs",
+	validateCommand:   "check all scripts, events, globals and aliases of the project",
+	pinsCommand:       "print or approve the checksums of the project scripts",
+	signaturesCommand: "verify the signed script manifest or create a new one",
+	uiCommand:         "start the full screen dashboard (only from the command line)",
+	benchCommand:      "run a command repeatedly and report its wall time statistics",
+	coverageCommand:   "merge the coverage files of the test commands and print or render a report",
+	watchCommand:      "execute a command again whenever a file of the project changes",
+	doctorCommand:     "check the environment and print fixes for the problems found",
+	queueCommand:      "print or manage the queue of runs triggered by events and daemon clients",
+	gcCommand:         "remove the artifacts and logs of old runs according to the retention policy",
+	snapshotCommand:   "list the environment snapshots of a command or compare two of them",
+	replayCommand:     "execute a previous run again with the arguments and environment of its snapshot",
+	cleanCommand:      "remove the declared outputs and cached runs of a command or of all commands",
+	busCommand:        "print the events of the peers on the event bus or manage the reactions to them",
+	usageCommand:      "print the resource usage history of a command or the last run of all commands",
 }
 
 // executed when running the info command
@@ -100,9 +137,11 @@ func printBuiltins() {
 	width := 15
 	l.Println(cp.colorText + "builtins:")
 	for builtin, description := range builtins {
-		l.Println(cp.colorCommandName + pad(builtin, width) + cp.colorText + " (" + description + ")")
+		l.Println(cp.colorCommandName + pad(builtin, width) + cp.colorText + " (" + msg(description) + ")")
 	}
 	l.Println("")
+
+	printPluginBuiltins()
 }
 
 // print all available commands
@@ -136,8 +175,8 @@ func printCommands() {
 		}
 
 		// print command chain if there is one
-		if len(cmd.commandChain) > 0 {
-			l.Println(cp.colorText + "├──── " + pad("chain:", 18) + cp.colorCommandChain + formatcommandChain(cmd.commandChain) + cp.colorText)
+		if len(cmd.parsedCommands) > 0 {
+			l.Println(cp.colorText + "├──── " + pad("chain:", 18) + cp.colorCommandChain + formatcommandChain(cmd.parsedCommands) + cp.colorText)
 		}
 
 		// print help section
@@ -149,7 +188,7 @@ func printCommands() {
 // format argStr
 func getArgumentString(args []*commandArg) (argStr string) {
 	for _, arg := range args {
-		argStr += "[" + arg.name + ":" + arg.argType.String() + "] "
+		argStr += "[" + arg.name + ":" + arg.typeName() + "] "
 	}
 	return
 }
@@ -177,17 +216,3 @@ func printProjectData() {
 
 	l.Println(string(b))
 }
-
-// print the contents of globals.sh on stdout
-func listGlobals() {
-
-	if len(globalsContent) > 0 {
-		c, err := ioutil.ReadFile("zeus/globals.sh")
-		if err != nil {
-			l.Fatal("failed to read globals: ", err)
-		}
-		l.Println(string(c))
-	} else {
-		l.Println("no globals defined.")
-	}
-}
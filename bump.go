@@ -0,0 +1,275 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNoVersionFile means the VersionFile field in the config is empty
+	ErrNoVersionFile = errors.New("no version file configured. set one with: config set VersionFile <path>")
+
+	// ErrInvalidVersion means the version string is not a valid semantic version
+	ErrInvalidVersion = errors.New("invalid semantic version")
+
+	// match a MAJOR.MINOR.PATCH version with an optional v prefix
+	semVersion = regexp.MustCompile(`^v?([0-9]+)\.([0-9]+)\.([0-9]+)$`)
+
+	// match the version field inside a package.json
+	jsonVersion = regexp.MustCompile(`"version"\s*:\s*"([^"]*)"`)
+
+	// match a constant or variable named Version, version or VERSION inside a go source file
+	// identifiers that only end in version, like apiVersion, are not matched
+	goVersion = regexp.MustCompile(`(?m)^\s*(?:(?:const|var)\s+)?(?:Version|version|VERSION)(?:\s+string)?\s*=\s*"([^"]*)"`)
+
+	// name of the variable that holds the current project version inside the scripts
+	projectVersionVar = "PROJECT_VERSION"
+)
+
+// semantic version
+type semver struct {
+	major int
+	minor int
+	patch int
+}
+
+// parse a MAJOR.MINOR.PATCH string
+func parseSemver(s string) (*semver, error) {
+
+	m := semVersion.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, ErrInvalidVersion
+	}
+
+	var v = new(semver)
+	v.major, _ = strconv.Atoi(m[1])
+	v.minor, _ = strconv.Atoi(m[2])
+	v.patch, _ = strconv.Atoi(m[3])
+
+	return v, nil
+}
+
+func (v *semver) String() string {
+	return strconv.Itoa(v.major) + "." + strconv.Itoa(v.minor) + "." + strconv.Itoa(v.patch)
+}
+
+func printBumpUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: bump <major | minor | patch>")
+}
+
+// get the regex for the version field of the configured version file
+// returns nil for plain text version files
+func versionFieldRegex(path string) *regexp.Regexp {
+	switch filepath.Ext(path) {
+	case ".json":
+		return jsonVersion
+	case ".go":
+		return goVersion
+	default:
+		return nil
+	}
+}
+
+// read the current project version from the configured version file
+func readProjectVersion() (string, error) {
+
+	if conf.VersionFile == "" {
+		return "", ErrNoVersionFile
+	}
+
+	c, err := ioutil.ReadFile(conf.VersionFile)
+	if err != nil {
+		return "", err
+	}
+
+	r := versionFieldRegex(conf.VersionFile)
+	if r == nil {
+		return strings.TrimSpace(string(c)), nil
+	}
+
+	m := r.FindSubmatch(c)
+	if m == nil {
+		return "", ErrInvalidVersion
+	}
+
+	return string(m[1]), nil
+}
+
+// write the version into the configured version file
+// for JSON and go files only the first version field will be replaced
+func writeProjectVersion(version string) error {
+
+	c, err := ioutil.ReadFile(conf.VersionFile)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(conf.VersionFile)
+	if err != nil {
+		return err
+	}
+
+	r := versionFieldRegex(conf.VersionFile)
+	if r == nil {
+		return ioutil.WriteFile(conf.VersionFile, []byte(version+"\n"), stat.Mode())
+	}
+
+	// replace only the submatch, keep everything else as it is
+	loc := r.FindSubmatchIndex(c)
+	if loc == nil {
+		return ErrInvalidVersion
+	}
+
+	var out []byte
+	out = append(out, c[:loc[2]]...)
+	out = append(out, version...)
+	out = append(out, c[loc[3]:]...)
+
+	return ioutil.WriteFile(conf.VersionFile, out, stat.Mode())
+}
+
+// undo a bump after git failed
+// the bump commit is removed as well if it was created already
+func restoreProjectVersion(previous []byte, committed bool) {
+
+	// a soft reset keeps the changes the user staged before
+	if committed {
+		if err := exec.Command("git", "reset", "-q", "--soft", "HEAD~1").Run(); err != nil {
+			Log.WithError(err).Error("failed to remove the bump commit")
+		}
+	}
+	exec.Command("git", "reset", "-q", "--", conf.VersionFile).Run()
+
+	stat, err := os.Stat(conf.VersionFile)
+	if err != nil {
+		Log.WithError(err).Error("failed to restore the version file")
+		return
+	}
+
+	err = ioutil.WriteFile(conf.VersionFile, previous, stat.Mode())
+	if err != nil {
+		Log.WithError(err).Error("failed to restore the version file")
+		return
+	}
+
+	Log.Info("restored " + conf.VersionFile)
+}
+
+// handle bump shell command
+func handleBumpCommand(args []string) {
+
+	if len(args) < 2 {
+		v, err := readProjectVersion()
+		if err != nil {
+			Log.WithError(err).Error("failed to read project version")
+			return
+		}
+		l.Println(cp.colorText + "Version: " + cp.colorPrompt + v + cp.colorText)
+		return
+	}
+
+	var cLog = Log.WithField("prefix", "bump")
+
+	if readOnly {
+		cLog.WithError(ErrReadOnly).Error("not bumping version")
+		return
+	}
+
+	current, err := readProjectVersion()
+	if err != nil {
+		cLog.WithError(err).Error("failed to read project version")
+		return
+	}
+
+	v, err := parseSemver(current)
+	if err != nil {
+		cLog.WithError(err).Error("failed to parse version: ", current)
+		return
+	}
+
+	switch args[1] {
+	case "major":
+		v.major++
+		v.minor = 0
+		v.patch = 0
+	case "minor":
+		v.minor++
+		v.patch = 0
+	case "patch":
+		v.patch++
+	default:
+		printBumpUsageErr()
+		return
+	}
+
+	// keep the v prefix if the previous version had one
+	version := v.String()
+	if strings.HasPrefix(strings.TrimSpace(current), "v") {
+		version = "v" + version
+	}
+
+	// keep the previous contents, to restore them if git fails
+	previous, err := ioutil.ReadFile(conf.VersionFile)
+	if err != nil {
+		cLog.WithError(err).Error("failed to read version file")
+		return
+	}
+
+	err = writeProjectVersion(version)
+	if err != nil {
+		cLog.WithError(err).Error("failed to write project version")
+		return
+	}
+
+	l.Println(printPrompt() + "bumped version from " + cp.colorPrompt + current + cp.colorText + " to " + cp.colorPrompt + version + cp.colorText)
+
+	// commit and tag
+	tag := "v" + strings.TrimPrefix(version, "v")
+	for _, a := range [][]string{
+		{"add", conf.VersionFile},
+		// only the version file, changes the user staged before stay staged
+		{"commit", "--only", "-m", "bump version to " + version, "--", conf.VersionFile},
+		{"tag", tag},
+	} {
+		cmd := exec.Command("git", a...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+		if err != nil {
+			cLog.WithError(err).Error("running git ", a[0], " failed.")
+			restoreProjectVersion(previous, a[0] == "tag")
+			return
+		}
+	}
+
+	l.Println(printPrompt() + "created tag " + cp.colorPrompt + tag + cp.colorText)
+
+	if conf.ReleaseChain != "" && trustCommandSet() {
+		executeCommandChain(conf.ReleaseChain)
+	}
+}
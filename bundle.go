@@ -0,0 +1,258 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidBundle means the bundle file could not be used for importing
+	ErrInvalidBundle = errors.New("invalid project data bundle")
+
+	// ErrBundleNotApproved means the user did not approve the code a bundle would run
+	ErrBundleNotApproved = errors.New("bundle not approved")
+
+	// default filename for exported project data bundles
+	defaultBundlePath = "zeus_bundle.json"
+)
+
+// bundle contains all project metadata
+// used for backups or moving a project to another machine
+type bundle struct {
+
+	// zeus version that created the bundle
+	Version string
+
+	// creation time of the bundle
+	Created time.Time
+
+	// project data: events, milestones, aliases, keybindings, typed globals etc
+	Data *data
+
+	// interactive shell history
+	History []string
+
+	// contents of the globals.sh script
+	GlobalsScript string
+}
+
+func printDataUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: data [export [path]] [import <path>]")
+}
+
+// handle data shell command
+func handleDataCommand(args []string) {
+
+	if len(args) < 2 {
+		printProjectData()
+		return
+	}
+
+	switch args[1] {
+	case "export":
+		path := defaultBundlePath
+		if len(args) > 2 {
+			path = args[2]
+		}
+		err := exportBundle(path)
+		if err != nil {
+			Log.WithError(err).Error("failed to export project data")
+			return
+		}
+		Log.Info("exported project data to ", path)
+	case "import":
+		if len(args) < 3 {
+			printDataUsageErr()
+			return
+		}
+		err := importBundle(args[2])
+		if err != nil {
+			Log.WithError(err).Error("failed to import project data")
+			return
+		}
+		Log.Info("imported project data from ", args[2])
+	default:
+		printDataUsageErr()
+	}
+}
+
+// write the project data bundle to path
+func exportBundle(path string) error {
+
+	// the header cache belongs to the scripts on this machine
+	d := *projectData
+	d.HeaderCache = nil
+
+	var b = &bundle{
+		Version: version,
+		Created: time.Now(),
+		Data:    &d,
+	}
+
+	// history file is optional
+	h, err := ioutil.ReadFile(historyFilePath)
+	if err == nil {
+		for _, line := range strings.Split(string(h), "\n") {
+			if line != "" {
+				b.History = append(b.History, line)
+			}
+		}
+	}
+
+	// globals script is optional
+	g, err := ioutil.ReadFile(globalsScriptPath)
+	if err == nil {
+		b.GlobalsScript = string(g)
+	}
+
+	// make it pretty
+	c, err := json.MarshalIndent(b, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, c, 0700)
+}
+
+// restore the project data, history and globals from the bundle at path
+func importBundle(path string) error {
+
+	if readOnly {
+		return ErrReadOnly
+	}
+
+	var b = new(bundle)
+
+	c, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(c, b)
+	if err != nil {
+		return err
+	}
+
+	if b.Data == nil {
+		return ErrInvalidBundle
+	}
+
+	// make sure all maps are initialized
+	d := newData()
+	c, err = json.Marshal(b.Data)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(c, d)
+	if err != nil {
+		return err
+	}
+
+	if !approveBundle(b, d) {
+		return ErrBundleNotApproved
+	}
+
+	// keep the header cache of the local scripts, older bundles contain the cache of the exporting machine
+	headerCacheMutex.Lock()
+	d.HeaderCache = projectData.HeaderCache
+	headerCacheMutex.Unlock()
+
+	// stop the watchers of the current user events
+	eventLock.Lock()
+	for _, e := range projectData.Events {
+		if e.Chain != "" && e.stopChan != nil {
+			e.stopChan <- true
+		}
+	}
+	eventLock.Unlock()
+
+	projectData = d
+	projectData.update()
+
+	if len(b.History) > 0 {
+		err = ioutil.WriteFile(historyFilePath, []byte(strings.Join(b.History, "\n")+"\n"), 0700)
+		if err != nil {
+			return err
+		}
+	}
+
+	if b.GlobalsScript != "" {
+		err = ioutil.WriteFile(globalsScriptPath, []byte(b.GlobalsScript), 0700)
+		if err != nil {
+			return err
+		}
+
+		// add newline to prevent parse errors
+		globalsContent = append([]byte(b.GlobalsScript), []byte("\n")...)
+
+		// the approval of the bundle covers the globals script it contains
+		err = pins.approve(globalsScriptPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	validateGlobals()
+	loadEvents()
+
+	return nil
+}
+
+// ask the user to approve the code an imported bundle would run
+// the globals script is part of every script, events and bus reactions execute command chains automatically
+// returns true if the bundle contains nothing to approve
+func approveBundle(b *bundle, d *data) bool {
+
+	var automation []string
+
+	if b.GlobalsScript != "" {
+		current, _ := ioutil.ReadFile(globalsScriptPath)
+		if string(current) != b.GlobalsScript {
+			automation = append(automation, "globals script: "+globalsScriptPath)
+		}
+	}
+	for path, e := range d.Events {
+		if e.Chain != "" {
+			automation = append(automation, "event "+e.Op.String()+" "+path+": "+e.Chain)
+		}
+	}
+	for pattern, chain := range d.BusReactions {
+		automation = append(automation, "bus reaction "+pattern+": "+chain)
+	}
+
+	if len(automation) == 0 {
+		return true
+	}
+
+	sort.Strings(automation)
+
+	Log.Warn("the bundle contains code that runs automatically")
+	for _, a := range automation {
+		Log.Warn("  ", a)
+	}
+
+	return confirm("import the bundle and allow it to run?")
+}
@@ -0,0 +1,444 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/mgutz/ansi"
+)
+
+// environment variable holding the shared secret of the event bus
+const busSecretVar = "ZEUS_BUS_SECRET"
+
+// states of a finished command on the event bus
+const (
+	busStatusOK     = "ok"
+	busStatusFailed = "failed"
+)
+
+const (
+	// number of received events kept for the bus builtin
+	busHistorySize = 20
+
+	// maximum size of a message on the wire
+	busMaxMessageSize = 64 * 1024
+
+	// older events are dropped, so recorded messages cannot be sent again later
+	busMaxAge = 5 * time.Minute
+
+	// events from the future are dropped, unless the clocks of the peers are only slightly off
+	busMaxClockSkew = 30 * time.Second
+)
+
+var (
+	// ErrInvalidBusSignature means an event was not signed with the shared secret
+	ErrInvalidBusSignature = errors.New("invalid event signature")
+
+	// ErrInvalidReaction means the event pattern of a reaction is invalid
+	ErrInvalidReaction = errors.New("invalid reaction, expected: <command>[:ok|:failed]")
+
+	// ErrBusEventInFuture means the time of an event is too far ahead of the local clock
+	ErrBusEventInFuture = errors.New("event time is in the future")
+
+	// matches CSI and OSC terminal escape sequences
+	busEscape = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)?|[@-_])`)
+
+	// identifies this instance, so it ignores its own events
+	busInstance = newBusInstance()
+
+	// received events, the latest last
+	busHistory []*busEvent
+	busMutex   sync.Mutex
+
+	// times of the received events for their instances, events younger than busMaxAge are remembered
+	// the same event can arrive from multiple peers, and recorded messages can be sent again
+	busSeen = make(map[busEventKey]time.Time)
+)
+
+// busEventKey identifies an event
+type busEventKey struct {
+	instance string
+	time     int64
+}
+
+// busEvent is sent to the peers when a command finished
+type busEvent struct {
+	Instance string    `json:"instance"`
+	Project  string    `json:"project"`
+	User     string    `json:"user"`
+	Host     string    `json:"host"`
+	Command  string    `json:"command"`
+	Args     []string  `json:"args,omitempty"`
+	Status   string    `json:"status"`
+	Duration string    `json:"duration"`
+	Time     time.Time `json:"time"`
+}
+
+// busMessage is the signed envelope of an event on the wire
+type busMessage struct {
+	Event     json.RawMessage `json:"event"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+func newBusInstance() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// sign the event with the shared secret, empty if there is none
+func busSignature(event []byte) string {
+
+	secret := os.Getenv(busSecretVar)
+	if secret == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(event)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// remove escape sequences and control characters from the fields of a received event
+// peers must not be able to move the cursor, change the terminal title or fake output lines
+func (e *busEvent) sanitize() {
+
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, busEscape.ReplaceAllString(s, ""))
+	}
+
+	e.Project = clean(e.Project)
+	e.User = clean(e.User)
+	e.Host = clean(e.Host)
+	e.Command = clean(e.Command)
+	e.Status = clean(e.Status)
+	e.Duration = clean(e.Duration)
+	for i, a := range e.Args {
+		e.Args[i] = clean(a)
+	}
+}
+
+// describe the event for the notification
+func (e *busEvent) String() string {
+	return e.User + "@" + e.Host + ": " + strings.TrimSpace(e.Command+" "+shellJoin(e.Args)) + " " + e.Status + " in " + e.Duration
+}
+
+// send an event for a finished command to the peers
+// nothing is sent if there are no peers configured
+func publishEvent(command string, args []string, err error, elapsed time.Duration) {
+
+	peers := strings.Fields(conf.EventBusPeers)
+	if len(peers) == 0 {
+		return
+	}
+
+	host, _ := os.Hostname()
+
+	// the arguments leave the machine, secrets must not
+	masked := make([]string, len(args))
+	for i, a := range args {
+		masked[i] = maskSecrets(a)
+	}
+
+	e := &busEvent{
+		Instance: busInstance,
+		Project:  filepath.Base(workingDir),
+		User:     auditUser(),
+		Host:     host,
+		Command:  command,
+		Args:     masked,
+		Status:   busStatusOK,
+		Duration: elapsed.Round(time.Millisecond).String(),
+		Time:     time.Now(),
+	}
+	if err != nil {
+		e.Status = busStatusFailed
+	}
+
+	event, err := json.Marshal(e)
+	if err != nil {
+		Log.WithError(err).Error("failed to marshal bus event")
+		return
+	}
+
+	msg, err := json.Marshal(&busMessage{
+		Event:     event,
+		Signature: busSignature(event),
+	})
+	if err != nil {
+		Log.WithError(err).Error("failed to marshal bus message")
+		return
+	}
+
+	for _, peer := range peers {
+		conn, err := net.DialTimeout("udp", peer, time.Second)
+		if err != nil {
+			Log.WithError(err).Debug("failed to reach bus peer ", peer)
+			continue
+		}
+		_, err = conn.Write(msg)
+		if err != nil {
+			Log.WithError(err).Debug("failed to send event to bus peer ", peer)
+		}
+		conn.Close()
+	}
+}
+
+// listen for the events of the peers
+// blocks, nothing happens if the event bus is disabled
+func listenEventBus() {
+
+	if conf.EventBus == "" {
+		return
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", conf.EventBus)
+	if err != nil {
+		Log.WithError(err).Error("invalid EventBus address")
+		return
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		Log.WithError(err).Error("failed to listen on the event bus")
+		return
+	}
+	defer conn.Close()
+
+	Log.Debug("event bus listening on ", conf.EventBus)
+
+	buf := make([]byte, busMaxMessageSize)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			Log.WithError(err).Error("failed to read from the event bus")
+			return
+		}
+
+		e, err := parseBusMessage(buf[:n])
+		if err != nil {
+			Log.WithError(err).Debug("dropped bus message from ", from)
+			continue
+		}
+
+		if e != nil {
+			handleBusEvent(e)
+		}
+	}
+}
+
+// verify and decode a message
+// returns nil if the event should be ignored
+func parseBusMessage(b []byte) (*busEvent, error) {
+
+	var msg = new(busMessage)
+	if err := json.Unmarshal(b, msg); err != nil {
+		return nil, err
+	}
+
+	if sig := busSignature(msg.Event); sig != "" && !hmac.Equal([]byte(sig), []byte(msg.Signature)) {
+		return nil, ErrInvalidBusSignature
+	}
+
+	var e = new(busEvent)
+	if err := json.Unmarshal(msg.Event, e); err != nil {
+		return nil, err
+	}
+
+	if e.Instance == busInstance || time.Since(e.Time) > busMaxAge {
+		return nil, nil
+	}
+
+	if time.Until(e.Time) > busMaxClockSkew {
+		return nil, ErrBusEventInFuture
+	}
+
+	e.sanitize()
+
+	busMutex.Lock()
+	defer busMutex.Unlock()
+
+	// forget the events that are dropped for their age anyway
+	for k, t := range busSeen {
+		if time.Since(t) > busMaxAge {
+			delete(busSeen, k)
+		}
+	}
+
+	key := busEventKey{instance: e.Instance, time: e.Time.UnixNano()}
+	if _, ok := busSeen[key]; ok {
+		return nil, nil
+	}
+	busSeen[key] = e.Time
+
+	busHistory = append(busHistory, e)
+	if len(busHistory) > busHistorySize {
+		busHistory = busHistory[1:]
+	}
+
+	return e, nil
+}
+
+// display an event and execute the reactions to it
+// reactions only run for events of the same project, only when the events are signed
+// and only after the command set was approved
+func handleBusEvent(e *busEvent) {
+
+	level := "info"
+	color := cp.colorText
+	if e.Status == busStatusFailed {
+		level = "error"
+		color = ansi.Red
+	}
+
+	l.Println(cp.colorPrompt + "[" + e.Project + "] " + color + e.String() + ansi.Reset)
+	notifyPlugins(level, "["+e.Project+"] "+e.String())
+
+	if e.Project != filepath.Base(workingDir) || os.Getenv(busSecretVar) == "" {
+		return
+	}
+
+	for _, pattern := range []string{e.Command, e.Command + ":" + e.Status} {
+		if chain, ok := projectData.BusReactions[pattern]; ok {
+
+			// dont run anything automatically before the command set was approved
+			if !trustCommandSet() {
+				return
+			}
+
+			Log.Info("reacting to " + pattern + " of " + e.User + "@" + e.Host + ": " + chain)
+			queueEvent(chain)
+		}
+	}
+}
+
+// check the event pattern of a reaction
+func validateReaction(pattern string) error {
+
+	parts := strings.SplitN(pattern, ":", 2)
+	if parts[0] == "" {
+		return ErrInvalidReaction
+	}
+	if len(parts) == 2 && parts[1] != busStatusOK && parts[1] != busStatusFailed {
+		return ErrInvalidReaction
+	}
+
+	return nil
+}
+
+func printBusUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: bus [on <command>[:ok|:failed] <commandChain>] [off <command>[:ok|:failed]]")
+}
+
+// handle bus shell command
+// prints the received events or manages the reactions to them
+func handleBusCommand(args []string) {
+
+	if len(args) < 2 {
+		printBus()
+		return
+	}
+
+	if len(args) < 3 {
+		printBusUsageErr()
+		return
+	}
+
+	switch args[1] {
+	case "on":
+		if len(args) < 4 {
+			printBusUsageErr()
+			return
+		}
+		if err := validateReaction(args[2]); err != nil {
+			Log.WithError(err).Error(args[2])
+			return
+		}
+		if projectData.BusReactions == nil {
+			projectData.BusReactions = make(map[string]string)
+		}
+		projectData.BusReactions[args[2]] = shellJoin(args[3:])
+		projectData.update()
+	case "off":
+		delete(projectData.BusReactions, args[2])
+		projectData.update()
+	default:
+		printBusUsageErr()
+	}
+}
+
+// print the state of the event bus, the reactions and the received events
+func printBus() {
+
+	if conf.EventBus == "" && conf.EventBusPeers == "" {
+		l.Println(cp.colorText + "the event bus is disabled, set EventBus and EventBusPeers to enable it" + ansi.Reset)
+		return
+	}
+
+	l.Println(cp.colorText + "listening on: " + cp.colorPrompt + conf.EventBus + ansi.Reset)
+	l.Println(cp.colorText + "peers:        " + cp.colorPrompt + conf.EventBusPeers + ansi.Reset)
+	if os.Getenv(busSecretVar) == "" {
+		l.Println(ansi.Yellow + busSecretVar + " is not set, events are not signed and reactions are disabled" + ansi.Reset)
+	}
+
+	var patterns []string
+	for pattern := range projectData.BusReactions {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	if len(patterns) > 0 {
+		l.Println(cp.colorText + "\nreactions:" + ansi.Reset)
+		for _, pattern := range patterns {
+			l.Println(cp.colorText + pad(pattern, 20) + cp.colorPrompt + projectData.BusReactions[pattern] + ansi.Reset)
+		}
+	}
+
+	busMutex.Lock()
+	defer busMutex.Unlock()
+
+	if len(busHistory) > 0 {
+		l.Println(cp.colorText + "\nreceived events:" + ansi.Reset)
+		for _, e := range busHistory {
+			l.Println(cp.colorText + e.Time.Format("15:04:05") + " " + cp.colorPrompt + "[" + e.Project + "] " + cp.colorText + e.String() + ansi.Reset)
+		}
+	}
+}
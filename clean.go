@@ -0,0 +1,242 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mgutz/ansi"
+)
+
+// ErrOutsideProject means a declared output does not belong to the project
+var ErrOutsideProject = errors.New("path is outside of the project")
+
+// cleanTarget is a path that is removed by the clean builtin
+type cleanTarget struct {
+	command string
+	path    string
+	size    int64
+}
+
+// collect the declared outputs and the cached runs of a command
+// outputs are the sandbox outputs, the coverage files and the dependency of the command
+func (c *command) cleanTargets() ([]cleanTarget, error) {
+
+	var paths []string
+
+	if c.sandbox != "" {
+		p, err := parseSandbox(c.sandbox)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p.outputs...)
+	}
+	paths = append(paths, strings.Fields(c.coverage)...)
+	if c.dependency != "" {
+		paths = append(paths, c.dependency)
+	}
+
+	// outputs are relative to the directory the command is executed in
+	for i, path := range paths {
+		if c.pkg != nil && !filepath.IsAbs(path) {
+			paths[i] = filepath.Join(c.pkg.dir, path)
+		}
+	}
+
+	for _, root := range []string{artifactsDir, runLogsDir, snapshotsDir} {
+		paths = append(paths, filepath.Join(root, runDirName(c.name)))
+	}
+
+	var targets []cleanTarget
+	for _, pattern := range paths {
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range matches {
+			if err := checkCleanPath(c, path); err != nil {
+				return nil, errors.New(err.Error() + ": " + path)
+			}
+			targets = append(targets, cleanTarget{
+				command: c.name,
+				path:    filepath.Clean(path),
+				size:    dirSize(path),
+			})
+		}
+	}
+
+	return targets, nil
+}
+
+// make sure that a path can be removed safely
+// only paths inside the project are removed, never the zeus directory or a script
+func checkCleanPath(c *command, path string) error {
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ErrOutsideProject
+	}
+
+	script, err := filepath.Abs(c.path)
+	if err != nil {
+		return err
+	}
+
+	zeus, err := filepath.Abs(zeusDir)
+	if err != nil {
+		return err
+	}
+
+	if abs == zeus || abs == script || strings.HasPrefix(script, abs+string(filepath.Separator)) {
+		return ErrOutsideProject
+	}
+
+	return nil
+}
+
+// handle clean shell command
+// removes the declared outputs and cached runs of a command or of all commands
+// a clean command of the project takes precedence over the builtin
+func handleCleanCommand(args []string) error {
+
+	commandMutex.Lock()
+	cmd, ok := commands[cleanCommand]
+	commandMutex.Unlock()
+	if ok {
+		numCommands = getTotalCommandCount(cmd)
+		defer func() {
+			numCommands = 0
+			currentCommand = 0
+		}()
+		return cmd.Run(args[1:])
+	}
+
+	var (
+		dryRun bool
+		names  []string
+	)
+	for _, a := range args[1:] {
+		if a == "--dry-run" {
+			dryRun = true
+			continue
+		}
+		names = append(names, a)
+	}
+
+	if len(names) > 1 {
+		printCleanUsageErr()
+		return ErrInvalidUsage
+	}
+
+	if readOnly && !dryRun {
+		Log.WithError(ErrReadOnly).Error("not removing any outputs")
+		return ErrReadOnly
+	}
+
+	var selected []*command
+
+	commandMutex.Lock()
+	if len(names) == 1 {
+		if c, ok := commands[names[0]]; ok {
+			selected = append(selected, c)
+		}
+	} else {
+		for _, c := range commands {
+			selected = append(selected, c)
+		}
+	}
+	commandMutex.Unlock()
+
+	if len(names) == 1 && len(selected) == 0 {
+		Log.WithError(ErrUnknownCommand).Error(names[0])
+		return ErrUnknownCommand
+	}
+
+	var (
+		targets []cleanTarget
+		seen    = make(map[string]bool)
+	)
+	for _, c := range selected {
+		t, err := c.cleanTargets()
+		if err != nil {
+			Log.WithError(err).Error("failed to collect the outputs of " + c.name)
+			return err
+		}
+		for _, target := range t {
+			if !seen[target.path] {
+				seen[target.path] = true
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	if len(targets) == 0 {
+		l.Println(cp.colorText + "nothing to remove" + ansi.Reset)
+		return nil
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].command != targets[j].command {
+			return targets[i].command < targets[j].command
+		}
+		return targets[i].path < targets[j].path
+	})
+
+	var freed int64
+	for _, t := range targets {
+		if !dryRun {
+			if err := os.RemoveAll(t.path); err != nil {
+				Log.WithError(err).Error("failed to remove " + t.path)
+				return err
+			}
+		}
+		freed += t.size
+		l.Println(cp.colorText + pad(t.command, 20) + cp.colorPrompt + pad(formatBytes(uint64(t.size)), 12) + cp.colorText + filepath.ToSlash(t.path) + ansi.Reset)
+	}
+
+	verb := "removed "
+	if dryRun {
+		verb = "would remove "
+	}
+	l.Println(cp.colorText + verb + strconv.Itoa(len(targets)) + " paths, " + formatBytes(uint64(freed)) + ansi.Reset)
+
+	return nil
+}
+
+func printCleanUsageErr() {
+	l.Println(ErrInvalidUsage)
+	l.Println("usage: clean [--dry-run] [command]")
+}
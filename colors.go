@@ -39,6 +39,8 @@ type colorProfile struct {
 	colorCommandOutput string
 	colorCommandName   string
 	colorCommandChain  string
+	colorDiffAdded     string
+	colorDiffRemoved   string
 }
 
 func printColorsUsageErr() {
@@ -53,6 +55,8 @@ func darkProfile() *colorProfile {
 		colorCommandOutput: ansi.White,
 		colorCommandName:   ansi.Blue,
 		colorCommandChain:  ansi.White,
+		colorDiffAdded:     ansi.Green,
+		colorDiffRemoved:   ansi.Red,
 	}
 }
 
@@ -63,6 +67,8 @@ func lightProfile() *colorProfile {
 		colorCommandOutput: ansi.Black,
 		colorCommandName:   ansi.White,
 		colorCommandChain:  ansi.White,
+		colorDiffAdded:     ansi.Green,
+		colorDiffRemoved:   ansi.Red,
 	}
 }
 
@@ -73,6 +79,8 @@ func defaultProfile() *colorProfile {
 		colorCommandOutput: ansi.White,
 		colorCommandName:   ansi.Red,
 		colorCommandChain:  ansi.White,
+		colorDiffAdded:     ansi.Green,
+		colorDiffRemoved:   ansi.Red,
 	}
 }
 
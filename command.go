@@ -21,18 +21,19 @@ package main
 import (
 	"bytes"
 	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/chzyer/readline"
 	"github.com/mgutz/ansi"
 )
 
@@ -40,6 +41,9 @@ var (
 	// ErrEmptyName means the script has an empty name. thats cant be correct
 	ErrEmptyName = errors.New("script has an empty name - wtf")
 
+	// ErrCycle means a command appears too often in its own chain
+	ErrCycle = errors.New("cycle in command chain")
+
 	// ErrTooManyArguments means there are too many arguments
 	ErrTooManyArguments = errors.New("too many arguments")
 
@@ -76,10 +80,14 @@ type command struct {
 	manual string
 
 	// commandChain contains commands that will be executed before the command runs
+	// it is resolved from the parsedCommands on first use
 	commandChain commandChain
 
-	// completer for interactive shell
-	PrefixCompleter *readline.PrefixCompleter
+	// command names and params from the chain header field
+	parsedCommands [][]string
+
+	// indicates whether the commandChain has been resolved
+	chainResolved bool
 
 	// buildNumber
 	buildNumber bool
@@ -87,11 +95,58 @@ type command struct {
 	// dependency means that the command will only be executed if the named file does NOT exist
 	// if the file exists the dependency is complete and the command will be skipped
 	dependency string
+
+	// resource limits from the header, in the form: memory=512M cpu=1.5 files=1024
+	limits string
+
+	// sandbox policy from the header, in the form: inputs=src,go.mod outputs=bin network=false
+	sandbox string
+
+	// environment variables passed in addition to the EnvAllowlist, in the form: NAME PREFIX*
+	env string
+
+	// format of the test results in the output: go-json, tap or pytest
+	results string
+
+	// coverage files written by the command, go cover profiles or lcov
+	coverage string
+
+	// commands of other zeus projects executed before the command, in the form: ../shared-lib:build
+	depends string
+
+	// filters for the output of the command, in the form: include=<regex> exclude=<regex> transform=<script>
+	filter string
+
+	// pseudo terminal allocation: true, false or empty to inherit the terminal
+	tty string
+
+	// tools the command needs, their versions are recorded in the run snapshots
+	requires string
+
+	// package the command belongs to, nil for commands of the projects zeus directory
+	pkg *zeusPackage
+
+	// script from one of the ScriptDirs, executed as is with the arguments passed through
+	discovered bool
 }
 
 // Run executes the command
 func (c *command) Run(args []string) error {
 
+	if err := checkExecutionAllowed(c.name); err != nil {
+		return err
+	}
+
+	// executing commands keeps watch mode and the daemon alive
+	beginActivity()
+	defer endActivity()
+
+	// resolve the command chain if that did not happen yet
+	err := c.resolveChain()
+	if err != nil {
+		return err
+	}
+
 	// check if theres a dependency set for the current command
 	if c.dependency != "" {
 		_, err := os.Stat(c.dependency)
@@ -105,6 +160,13 @@ func (c *command) Run(args []string) error {
 		}
 	}
 
+	// read the arguments from a parameter file
+	args, err = c.applyParamsFile(args)
+	if err != nil {
+		Log.WithError(err).Error("invalid parameters for " + c.name)
+		return err
+	}
+
 	var (
 		argc         = len(args)
 		requiredArgs = len(c.args)
@@ -127,7 +189,8 @@ func (c *command) Run(args []string) error {
 	}
 
 	// check args
-	if argc != requiredArgs {
+	// discovered scripts without typed arguments accept any arguments
+	if argc != requiredArgs && !(c.discovered && requiredArgs == 0) {
 		if argc > requiredArgs {
 			return ErrTooManyArguments
 		}
@@ -135,6 +198,37 @@ func (c *command) Run(args []string) error {
 		return ErrNotEnoughArguments
 	}
 
+	// refuse to run scripts that changed since they were approved
+	if conf.PinScripts {
+		err = c.checkPins()
+		if err != nil {
+			cLog.WithError(err).Error("not executing " + c.name)
+			return err
+		}
+	}
+
+	// abort before the chain and the dependencies are executed if the script references undefined variables
+	if conf.StrictVariables && !c.discovered {
+		err = c.checkVariables()
+		if err != nil {
+			cLog.Error("not executing " + c.name)
+			return err
+		}
+	}
+
+	// give the plugins a chance to refuse the execution
+	err = pluginsBefore(c.name, args)
+	if err != nil {
+		return err
+	}
+
+	// execute the commands of other projects this command depends on
+	err = c.runDependencies()
+	if err != nil {
+		cLog.WithError(err).Error("failed to execute the dependencies of " + c.name)
+		return err
+	}
+
 	// execute build chain commands
 	if len(c.commandChain) > 0 {
 		for _, cmd := range c.commandChain {
@@ -149,69 +243,141 @@ func (c *command) Run(args []string) error {
 	}
 
 	// make script executable
-	err := os.Chmod(c.path, 0700)
-	if err != nil {
-		cLog.WithError(err).Fatal("failed to make script executable")
+	// discovered scripts are executable already, their permissions are left alone
+	if !c.discovered {
+		err = os.Chmod(c.path, 0700)
+		if err != nil {
+			cLog.WithError(err).Error("failed to make script executable")
+			return err
+		}
 	}
 
-	var (
-		cmd    *exec.Cmd
-		script string
-	)
-
-	// prepend projectGlobals if not empty
-	if len(globalsContent) > 0 {
+	cmd, script, err := c.newProcess(args)
+	if err != nil {
+		return err
+	}
 
-		// read the contents of this commands script
-		target, err := ioutil.ReadFile(c.path)
+	// restrict filesystem and network access
+	sandbox, err := c.sandboxPolicy()
+	if err != nil {
+		cLog.WithError(err).Error("failed to parse sandbox of " + c.name)
+		return err
+	}
+	if sandbox != nil {
+		dir := "."
+		if c.pkg != nil {
+			dir = c.pkg.dir
+		}
+		cmd, err = sandbox.wrap(cmd, dir, c.path)
 		if err != nil {
-			l.Fatal(err)
+			cLog.WithError(err).Error("failed to sandbox " + c.name)
+			return err
 		}
+	}
 
-		// parse arguments and add them to the script
-		var argBuf bytes.Buffer
-		for i, a := range args {
-			if i < len(c.args) {
-				if !validArgType(a, c.args[i].argType) {
-					cLog.WithError(ErrInvalidArgumentType).WithFields(logrus.Fields{
-						"value":   a,
-						"argName": c.args[i].name,
-					}).Error("expected type: ", c.args[i].argType.String())
-					return ErrInvalidArgumentType
-				}
-				argBuf.WriteString(c.args[i].name + "=" + a + "\n")
-			}
+	// restrict cpu, memory and open files
+	limits, err := c.resourceLimits()
+	if err != nil {
+		cLog.WithError(err).Error("failed to parse resource limits of " + c.name)
+		return err
+	}
+	if limits != nil {
+		cmd, err = limits.wrap(cmd)
+		if err != nil {
+			cLog.WithError(err).Error("failed to apply resource limits for " + c.name)
+			return err
 		}
+	}
 
-		// add the globals, append argument buffer and then append script contents
-		script = string(append(append(globalsContent, argBuf.Bytes()...), target...))
+	tty, err := parseTTY(c.tty)
+	if err != nil {
+		cLog.WithError(err).Error("failed to parse the tty option of " + c.name)
+		return err
+	}
 
-		if conf.Debug {
-			printScript(script)
-		}
+	// set up environment
+	cmd.Stdin = runStdin()
+	if tty == ttyOff {
+		cmd.Stdin = nil
+	}
+	cmd.Env, err = c.baseEnv()
+	if err != nil {
+		cLog.WithError(err).Error("failed to set up the environment of " + c.name)
+		return err
+	}
 
-		// create command instance and pass new script to bash
-		if conf.StopOnError {
-			cmd = exec.Command("/bin/bash", []string{"-e", "-c", script}...)
-		} else {
-			cmd = exec.Command("/bin/bash", "-c", script)
+	// signals must reach the children of the command as well
+	setProcessGroup(cmd)
+
+	// commands of nested packages are executed inside the package directory
+	// runs of daemon clients are executed in the directory of the client
+	if c.pkg != nil {
+		cmd.Dir = c.pkg.dir
+	} else if clientDir != "" {
+		cmd.Dir = clientDir
+	}
+
+	// the recorded environment of a replayed run
+	cmd.Env = append(cmd.Env, replayEnv...)
+
+	// keep the artifacts and the log of this run
+	out, err := newRunOutput(c.name)
+	if err != nil {
+		cLog.WithError(err).Error("failed to create the artifact directory of " + c.name)
+		return err
+	}
+	cmd.Env = append(cmd.Env, out.env()...)
+
+	// record the environment for comparing runs
+	if conf.RunSnapshots && !readOnly {
+		err = writeSnapshot(c, args, cmd, out.id)
+		if err != nil {
+			cLog.WithError(err).Error("failed to write the snapshot of " + c.name)
 		}
-	} else {
+	}
 
-		// create command instance
-		// no globals - only execute target script
-		if conf.StopOnError {
-			cmd = exec.Command(c.path, append([]string{"-e"}, args...)...)
-		} else {
-			cmd = exec.Command(c.path, args...)
+	// first command of a run: record the output of the run separately from the other runs
+	// the following commands of the chain record into the same run
+	if currentCommand == 0 || currentOutputRun == nil {
+		currentOutputRun = outputHistory.start(c.name)
+	}
+
+	// filter the displayed output
+	var (
+		stdoutRecorder = newOutputRecorder(commandStdout, currentOutputRun)
+		stderrRecorder = newOutputRecorder(commandStderr, currentOutputRun)
+
+		stdout  io.Writer = stdoutRecorder
+		stderr  io.Writer = stderrRecorder
+		filters []*filterWriter
+	)
+	filter, err := c.outputFilter()
+	if err == nil && filter != nil {
+		for _, w := range []*io.Writer{&stdout, &stderr} {
+			var fw *filterWriter
+			fw, err = filter.writer(*w)
+			if err != nil {
+				break
+			}
+			filters = append(filters, fw)
+			*w = fw
 		}
 	}
+	if err != nil {
+		closeFilters(filters)
+		out.close()
+		cLog.WithError(err).Error("failed to set up the output filter of " + c.name)
+		return err
+	}
 
-	// set up environment
-	cmd.Stdout = os.Stdout
-	cmd.Stdin = os.Stdin
-	cmd.Stderr = cWriter
-	cmd.Env = os.Environ()
+	// capture the output of test commands for parsing the results
+	var testOutput bytes.Buffer
+
+	cmd.Stdout = out.wrap(stdout)
+	if c.results != "" {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, &testOutput)
+	}
+	cmd.Stderr = out.wrap(stderr)
 
 	currentCommand++
 
@@ -223,17 +389,89 @@ func (c *command) Run(args []string) error {
 	l.Print(cp.colorText)
 	l.Println(printPrompt() + "[" + strconv.Itoa(currentCommand) + "/" + strconv.Itoa(numCommands) + "] executing " + cp.colorPrompt + c.name + ansi.Reset)
 
+	// commands that need a terminal for colors or their user interface get a pseudo terminal
+	// stdout and stderr are merged by the pseudo terminal
+	var pty *ptySession
+	if tty == ttyOn {
+		pty, err = newPTYSession(cmd, cmd.Stdout, cmd.Stdin == os.Stdin)
+		if err == ErrPTYUnsupported {
+			Log.Warn("pseudo terminals are not supported on " + runtime.GOOS + ", " + c.name + " uses pipes")
+		} else if err != nil {
+			closeFilters(filters)
+			out.close()
+			cLog.WithError(err).Error("failed to allocate a pseudo terminal for " + c.name)
+			return err
+		}
+	}
+
+	// interactive commands can change the terminal settings
+	restoreTerminal := saveTerminal(cmd.Stdin)
+
 	// lets go
+	started := time.Now()
 	err = cmd.Start()
 	if err != nil {
-		cLog.WithError(err).Fatal("failed to start command: " + c.name)
+		if pty != nil {
+			pty.close()
+		}
+		restoreTerminal()
+		closeFilters(filters)
+		out.close()
+		cLog.WithError(err).Error("failed to start command: " + c.name)
+		return err
+	}
+
+	if pty != nil {
+		pty.started()
 	}
 
+	sampler := startRSSSampler(cmd.Process.Pid)
+
 	// add to processMap
+	processLock.Lock()
 	processMap[c.name] = cmd.Process
+	processLock.Unlock()
 
 	// wait for command to finish execution
 	err = cmd.Wait()
+	usage := newResourceUsage(cmd.ProcessState, time.Since(started), sampler.stop())
+
+	// after command has finished running, remove from processMap
+	processLock.Lock()
+	delete(processMap, c.name)
+	processLock.Unlock()
+
+	// take back the terminal
+	if pty != nil {
+		pty.close()
+	}
+	restoreForeground()
+	restoreTerminal()
+
+	// the transform scripts must process the remaining output before it is flushed
+	closeFilters(filters)
+	stdoutRecorder.Flush()
+	stderrRecorder.Flush()
+
+	// write incomplete lines
+	flushOutput()
+
+	out.close()
+
+	audit(c.name, args, cmd.Dir, err)
+
+	// keep the resource usage, so regressions become visible over time
+	if recErr := recordUsage(c.name, usage); recErr != nil {
+		cLog.WithError(recErr).Error("failed to record the resource usage of " + c.name)
+	}
+
+	pluginsAfter(c.name, args, err, time.Since(start))
+	publishEvent(c.name, args, err, time.Since(start))
+
+	if c.results != "" {
+		c.reportTestResults(testOutput.Bytes())
+	}
+
 	if err != nil {
 
 		// when are no globals, read the command script directly and print it with line numbers to stdout for easy debugging
@@ -250,18 +488,141 @@ func (c *command) Run(args []string) error {
 		}
 
 		cLog.WithError(err).Error("failed to wait for command: " + c.name)
+		notifyPlugins("error", c.name+" failed: "+err.Error())
 		return err
 	}
 
-	// after command has finished running, remove from processMap
-	delete(processMap, c.name)
+	if currentCommand == numCommands {
+		notifyPlugins("info", c.name+" finished in "+time.Since(start).String())
+	}
 
 	// print stats
-	l.Println(printPrompt()+"["+strconv.Itoa(currentCommand)+"/"+strconv.Itoa(numCommands)+"] finished "+cp.colorPrompt+c.name+cp.colorText+" in"+cp.colorPrompt, time.Now().Sub(start), ansi.Reset)
+	l.Println(printPrompt()+"["+strconv.Itoa(currentCommand)+"/"+strconv.Itoa(numCommands)+"] finished "+cp.colorPrompt+c.name+cp.colorText+" in"+cp.colorPrompt, time.Now().Sub(start), cp.colorText+"("+usage.String()+")"+ansi.Reset)
 
 	return nil
 }
 
+// create the process for the script of the command with the globals and the arguments
+// returns the script passed to the shell, empty if the script file is executed directly
+func (c *command) newProcess(args []string) (cmd *exec.Cmd, script string, err error) {
+
+	var cLog = Log.WithField("prefix", "runCommand"+strings.ToTitle(c.name))
+
+	// commands of nested packages use the globals of their package
+	globals := globalsContent
+	if c.pkg != nil {
+		globals = c.pkg.globals
+	}
+
+	// prepend projectGlobals if not empty
+	// discovered scripts are not necessarily shell scripts, they get the globals from the environment only
+	if c.discovered {
+		cmd, err = scriptCommand(c.path, args...)
+	} else if len(globals) > 0 {
+
+		// read the contents of this commands script
+		var target []byte
+		target, err = ioutil.ReadFile(c.path)
+		if err != nil {
+			cLog.WithError(err).Error("failed to read script")
+			return nil, "", err
+		}
+
+		// parse arguments and add them to the script
+		var argBuf bytes.Buffer
+		for i, a := range args {
+			if i < len(c.args) {
+				if !validArgType(a, c.args[i].argType) {
+					cLog.WithError(ErrInvalidArgumentType).WithFields(logrus.Fields{
+						"value":   a,
+						"argName": c.args[i].name,
+					}).Error("expected type: ", c.args[i].typeName())
+					return nil, "", ErrInvalidArgumentType
+				}
+				argBuf.WriteString(c.args[i].name + "=" + shellQuote(a) + "\n")
+			}
+		}
+
+		// add the globals, append argument buffer and then append script contents
+		script = string(append(append(append([]byte{}, globals...), argBuf.Bytes()...), target...))
+
+		if conf.Debug {
+			printScript(script)
+		}
+
+		// create command instance and pass new script to bash
+		if conf.StopOnError {
+			cmd, err = shellCommand("-e", "-c", script)
+		} else {
+			cmd, err = shellCommand("-c", script)
+		}
+	} else {
+
+		// create command instance
+		// no globals - only execute target script
+		if conf.StopOnError {
+			cmd, err = scriptCommand(c.path, append([]string{"-e"}, args...)...)
+		} else {
+			cmd, err = scriptCommand(c.path, args...)
+		}
+	}
+	if err != nil {
+		cLog.WithError(err).Error("failed to create command: " + c.name)
+		return nil, "", err
+	}
+
+	return cmd, script, nil
+}
+
+// get the environment of the command with the globals and the project version
+func (c *command) baseEnv() ([]string, error) {
+
+	env, err := c.environment()
+	if err != nil {
+		return nil, err
+	}
+
+	// add typed globals
+	env = append(env, globalsEnv()...)
+
+	// expose the current project version
+	if v, err := readProjectVersion(); err == nil {
+		env = append(env, projectVersionVar+"="+v)
+	}
+
+	return env, nil
+}
+
+// check the script of the command for references to undefined variables
+// the environment is set up like for the execution, the globals and the arguments count as defined
+func (c *command) checkVariables() error {
+
+	script, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return err
+	}
+
+	env, err := c.baseEnv()
+	if err != nil {
+		return err
+	}
+	env = append(env, replayEnv...)
+	if !readOnly {
+		env = append(env, artifactsVar+"=")
+	}
+
+	// commands of nested packages use the globals of their package
+	prelude := string(globalsContent)
+	if c.pkg != nil {
+		prelude = string(c.pkg.globals)
+	}
+	for _, a := range c.args {
+		prelude += "\n" + a.name + "="
+	}
+
+	return checkUndefinedVariables(string(script), prelude, env)
+}
+
 /*
  *	Utils
  */
@@ -274,14 +635,16 @@ func addCommand(path string) error {
 
 		// create parse job
 		job         = p.AddJob(path)
-		commandName = strings.TrimSuffix(filepath.Base(path), f.fileExtension)
+		commandName = commandNameForPath(path)
 	)
 
 	commandMutex.Lock()
 	_, ok := commands[commandName]
 	commandMutex.Unlock()
 
-	if !ok {
+	if ok {
+		p.RemoveJob(job)
+	} else {
 
 		// create new command instance
 		cmd, err := job.newCommand(path)
@@ -293,13 +656,11 @@ func addCommand(path string) error {
 		// job done
 		p.RemoveJob(job)
 
-		// Add the completer.
-		completer.Children = append(completer.Children, cmd.PrefixCompleter)
-
-		// add to command map
+		// add to command map and the completer
 		commandMutex.Lock()
 		commands[cmd.name] = cmd
 		commandMutex.Unlock()
+		commandIndex.add(cmd.name)
 
 		cLog.Debug("added " + cmd.name + " to the command map")
 	}
@@ -316,7 +677,7 @@ func (job *parseJob) newCommand(path string) (*command, error) {
 	)
 
 	// parse the script
-	d, err := p.parseScript(path, job)
+	d, err := p.parseScriptCached(path, job)
 	if err != nil {
 
 		cLog.WithFields(logrus.Fields{
@@ -326,33 +687,76 @@ func (job *parseJob) newCommand(path string) (*command, error) {
 		return nil, err
 	}
 
-	// get build chain
-	commandChain, err := job.getCommandChain(d.parsedCommands)
-	if err != nil {
-		cLog.WithError(err).Fatal("failed to parse command chain")
-	}
-
 	// get name for command
-	name := strings.TrimSuffix(strings.TrimPrefix(path, zeusDir+"/"), f.fileExtension)
+	name := commandNameForPath(path)
 	if name == "" {
 		return nil, ErrEmptyName
 	}
 
 	return &command{
-		path:            path,
-		name:            name,
-		args:            d.args,
-		manual:          d.manual,
-		help:            d.help,
-		commandChain:    commandChain,
-		PrefixCompleter: readline.PcItem(name),
-		buildNumber:     d.buildNumber,
-		dependency:      d.dependency,
+		path:           path,
+		name:           name,
+		args:           d.args,
+		manual:         d.manual,
+		help:           d.help,
+		parsedCommands: d.parsedCommands,
+		buildNumber:    d.buildNumber,
+		dependency:     d.dependency,
+		limits:         d.limits,
+		sandbox:        d.sandbox,
+		env:            d.env,
+		results:        d.results,
+		coverage:       d.coverage,
+		depends:        d.depends,
+		filter:         d.filter,
+		tty:            d.tty,
+		requires:       d.requires,
+		pkg:            packageForPath(path),
 	}, nil
 }
 
+// resolve the command chain of the command and all commands inside of it
+// this is deferred until the command is used, to keep the startup fast
+// thread safe
+func (c *command) resolveChain() error {
+
+	chainMutex.Lock()
+	defer chainMutex.Unlock()
+
+	job := p.AddJob(c.path)
+	defer p.RemoveJob(job)
+
+	return c.resolve(job)
+}
+
+// resolve the command chain recursively, the job is used to detect cycles
+func (c *command) resolve(job *parseJob) error {
+
+	if c.chainResolved {
+		return nil
+	}
+
+	chain, err := job.getCommandChain(c.parsedCommands, c.pkg)
+	if err != nil {
+		return err
+	}
+
+	for _, cmd := range chain {
+		err = cmd.resolve(job)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.commandChain = chain
+	c.chainResolved = true
+
+	return nil
+}
+
 // assemble a commandChain with a list of parsed commands and their arguments
-func (job *parseJob) getCommandChain(parsedCommands [][]string) (commandChain commandChain, err error) {
+// command names without a namespace are resolved inside the given package
+func (job *parseJob) getCommandChain(parsedCommands [][]string, pkg *zeusPackage) (commandChain commandChain, err error) {
 
 	var cLog = Log.WithFields(logrus.Fields{
 		"prefix":         "getCommandChain",
@@ -366,6 +770,9 @@ func (job *parseJob) getCommandChain(parsedCommands [][]string) (commandChain co
 
 		var count int
 
+		// resolve the name inside the namespace of the package
+		args = append([]string{pkg.qualify(args[0])}, args[1:]...)
+
 		// check if there are repetitive targets in the chain - this is not allowed to prevent cycles
 		for _, c := range job.commands {
 
@@ -381,7 +788,8 @@ func (job *parseJob) getCommandChain(parsedCommands [][]string) (commandChain co
 				"path":           job.path,
 				"parsedCommands": parsedCommands,
 				"job.commands":   job.commands,
-			}).Fatal("CYCLE DETECTED! -> ", args[0], " appeared more than ", p.recursionDepth, " times - thats invalid.")
+			}).Error("CYCLE DETECTED! -> ", args[0], " appeared more than ", p.recursionDepth, " times - thats invalid.")
+			return nil, ErrCycle
 		}
 
 		job.commands = append(job.commands, args)
@@ -394,19 +802,17 @@ func (job *parseJob) getCommandChain(parsedCommands [][]string) (commandChain co
 		if !ok {
 
 			// add new command
-			cmd, err = job.newCommand(zeusDir + "/" + args[0] + f.fileExtension)
+			cmd, err = job.newCommand(commandPathForName(args[0]))
 			if err != nil {
 				cLog.WithError(err).Error("failed to create command")
 				return
 			}
 
-			// add the completer
-			completer.Children = append(completer.Children, cmd.PrefixCompleter)
-
-			// add to command map
+			// add to command map and the completer
 			commandMutex.Lock()
 			commands[args[0]] = cmd
 			commandMutex.Unlock()
+			commandIndex.add(args[0])
 
 			cLog.Debug("added " + cmd.name + " to the command map")
 		}
@@ -424,17 +830,9 @@ func (job *parseJob) getCommandChain(parsedCommands [][]string) (commandChain co
 
 			// creating a hard copy of the struct here,
 			// otherwise params would be set for every execution of the command
-			cmd = &command{
-				name:            cmd.name,
-				path:            cmd.path,
-				params:          args[1:],
-				args:            cmd.args,
-				manual:          cmd.manual,
-				help:            cmd.help,
-				commandChain:    cmd.commandChain,
-				PrefixCompleter: cmd.PrefixCompleter,
-				buildNumber:     cmd.buildNumber,
-			}
+			copied := *cmd
+			copied.params = args[1:]
+			cmd = &copied
 		}
 
 		// append command to build chain
@@ -444,29 +842,60 @@ func (job *parseJob) getCommandChain(parsedCommands [][]string) (commandChain co
 }
 
 // parse and execute a given commandChain string
-func executeCommandChain(chain string) {
+// stops at the first failing command, unless keepGoing is set
+// returns the error of the first failing command
+func executeCommandChain(chain string) error {
 
 	var (
 		cLog = Log.WithField("prefix", "executeCommandChain")
 		job  = p.AddJob(chain)
 	)
 
-	commandList := parseCommandChain(chain)
-	commandChain, err := job.getCommandChain(commandList)
+	commandList, err := parseCommandChain(chain)
+	if err != nil {
+		cLog.WithError(err).Error("failed to parse command chain")
+		p.RemoveJob(job)
+		return err
+	}
+
+	commandChain, err := job.getCommandChain(commandList, nil)
 	if err != nil {
 		cLog.WithError(err).Error("failed to get command chain")
+		p.RemoveJob(job)
+		return err
 	}
 
+	chainMutex.Lock()
+	for _, c := range commandChain {
+		err = c.resolve(job)
+		if err != nil {
+			cLog.WithError(err).Error("failed to resolve command chain for " + c.name)
+			chainMutex.Unlock()
+			p.RemoveJob(job)
+			return err
+		}
+	}
+	chainMutex.Unlock()
+
 	p.RemoveJob(job)
 
 	numCommands = countCommandChain(commandChain)
 
+	var firstErr error
 	for _, c := range commandChain {
 		err := c.Run([]string{})
 		if err != nil {
 			cLog.WithError(err).Error("failed to execute " + c.name)
+			if firstErr == nil {
+				firstErr = err
+			}
+			if !keepGoing {
+				return err
+			}
 		}
 	}
+
+	return firstErr
 }
 
 // walk all scripts in the zeus dir and setup commandMap and globals
@@ -479,8 +908,30 @@ func findCommands() {
 		wg      sync.WaitGroup
 	)
 
+	doneParsing := profilePhase("parsing")
+
+	// run the config script first, its commands are added after the scripts
+	generated, err := generateCommands()
+	if err != nil {
+		cLog.WithError(err).Error("failed to run " + configScriptPath)
+	}
+
 	// walk zeus directory and initialize commands
-	err := filepath.Walk(zeusDir, func(path string, info os.FileInfo, err error) error {
+	err = walkFollow(zeusDir, func(path string, info os.FileInfo, err error) error {
+
+		if err != nil {
+			cLog.WithError(err).Warn("skipping " + path)
+			return nil
+		}
+
+		// use forward slashes on all platforms
+		path = filepath.ToSlash(path)
+
+		// scripts stored as artifacts, logs or snapshots of previous runs are no commands
+		// the generated scripts are added with the names from the config script
+		if info.IsDir() && inNoCommandDir(path) {
+			return filepath.SkipDir
+		}
 
 		// check if its a valid script
 		if strings.HasSuffix(path, f.fileExtension) {
@@ -489,7 +940,7 @@ func findCommands() {
 			// the globals script wont be parsed for zeus header fields
 			if strings.HasPrefix(strings.TrimPrefix(path, zeusDir+"/"), "globals") {
 
-				g, err := ioutil.ReadFile("zeus/globals.sh")
+				g, err := ioutil.ReadFile(globalsScriptPath)
 				if err != nil {
 					l.Fatal(err)
 				}
@@ -507,35 +958,64 @@ func findCommands() {
 		cLog.WithError(err).Fatal("failed to walk zeus directory")
 	}
 
-	wg.Add(1)
+	// add the scripts of nested zeus packages
+	scripts = append(scripts, findPackages()...)
 
-	// first half
-	go func() {
-		for _, path := range scripts[:len(scripts)/2] {
-			err := addCommand(path)
-			if err != nil {
-				Log.WithError(err).Error("failed to add command")
+	// parse the scripts concurrently with a pool of workers
+	var (
+		paths   = make(chan string)
+		workers = conf.ParserWorkers
+	)
+
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				err := addCommand(path)
+				if err != nil {
+					Log.WithError(err).Error("failed to add command")
+				}
 			}
-		}
-		wg.Done()
-	}()
+		}()
+	}
+
+	for _, path := range scripts {
+		paths <- path
+	}
+	close(paths)
 
-	wg.Add(1)
+	wg.Wait()
+
+	addGeneratedCommands(generated)
+
+	// add the scripts of the ScriptDirs last, all other commands take precedence
+	discoverCommands()
+
+	// persist the parsed headers
+	if conf.HeaderCache {
+		updateHeaderCache()
+	}
 
-	// second half
-	go func() {
-		for _, path := range scripts[len(scripts)/2:] {
-			err := addCommand(path)
+	// resolve all command chains now if lazy parsing is disabled
+	// this detects cycles on startup
+	if !conf.LazyParsing {
+		for _, c := range commands {
+			err := c.resolveChain()
 			if err != nil {
-				Log.WithError(err).Error("failed to add command")
+				cLog.WithError(err).Fatal("failed to resolve command chain for " + c.name)
 			}
 		}
-		wg.Done()
-	}()
+	}
 
-	wg.Wait()
+	doneParsing()
 
-	l.Println(cp.colorText+"initialized "+cp.colorPrompt, len(commands), cp.colorText+" commands in: "+cp.colorPrompt, time.Now().Sub(start), ansi.Reset+"\n")
+	l.Println(cp.colorText+"initialized "+cp.colorPrompt, len(commands), cp.colorText+" commands in: "+cp.colorPrompt, time.Now().Sub(start), ansi.Reset)
+	l.Println("")
 
 	// check if custom command conflicts with builtin name
 	for _, name := range builtins {
@@ -544,38 +1024,47 @@ func findCommands() {
 		}
 	}
 
-	var commandCompletions []readline.PrefixCompleterInterface
-	for _, c := range commands {
-		commandCompletions = append(commandCompletions, readline.PcItem(c.name))
+	// projects bootstrapped by older versions have a bench command, which is shadowed by the bench builtin
+	if _, ok := commands[benchCommand]; ok {
+		cLog.Warn("command ", benchCommand, " is shadowed by the builtin of the same name, rename it to run it")
 	}
+}
 
-	// add all commands to the completer for the help page
-	for _, c := range completer.Children {
-		if string(c.GetName()) == "help " {
-			c.SetChildren(commandCompletions)
-		}
+// drop all commands and parse the scripts again
+// the daemon outlives changes to the scripts
+func reloadCommands() {
+
+	commandMutex.Lock()
+	for name := range commands {
+		commandIndex.remove(name)
 	}
+	commands = make(map[string]*command, 0)
+	commandMutex.Unlock()
+
+	findCommands()
 }
 
 // run an alias command (allows shell commands)
 // first checks for zeus commands then passes it to the shell
-func executeCommand(command string) {
+func executeCommand(command string) error {
 
-	s := strings.Split(command, " ")
+	s, err := splitCommandLine(command)
+	if err != nil {
+		Log.WithError(err).Error("invalid command: ", command)
+		return err
+	}
 	if len(s) > 0 {
 
 		// check if first command is known to zeus
 		// check user commands
 		if _, ok := commands[s[0]]; ok {
-			executeCommandChain(command)
-			return
+			return executeCommandChain(command)
 		}
 
 		// check builtins
 		for _, b := range builtins {
 			if b == s[0] {
-				executeCommandChain(command)
-				return
+				return executeCommandChain(command)
 			}
 		}
 
@@ -591,6 +1080,8 @@ func executeCommand(command string) {
 			if err != nil {
 				l.Println(err)
 			}
+			return err
 		}
 	}
+	return nil
 }
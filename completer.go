@@ -20,9 +20,12 @@ package main
 
 import (
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/chzyer/readline"
 )
@@ -58,6 +61,48 @@ func configItems() []readline.PrefixCompleterInterface {
 		readline.PcItem("ExitOnInterrupt", readline.PcItem("true"), readline.PcItem("false")),
 		readline.PcItem("DisableTimestamps", readline.PcItem("true"), readline.PcItem("false")),
 		readline.PcItem("PrintBuiltins", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("StopOnError", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("DumpScriptOnError", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("VersionFile", readline.PcItemDynamic(fileCompleter)),
+		readline.PcItem("ReleaseChain"),
+		readline.PcItem("GlobalsPrefix"),
+		readline.PcItem("PackagePatterns"),
+		readline.PcItem("LazyParsing", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("ParserWorkers"),
+		readline.PcItem("OutputBufferSize"),
+		readline.PcItem("HeaderCache", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("OutputHistorySize"),
+		readline.PcItem("ShutdownTimeout"),
+		readline.PcItem("StrictVariables", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("FollowSymlinks", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("PinScripts", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("AuditLog", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("AuditSyslog"),
+		readline.PcItem("Sandbox", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("SignatureVerification", readline.PcItem("gpg"), readline.PcItem("minisign")),
+		readline.PcItem("TrustedKeys"),
+		readline.PcItem("MaskSecrets", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("SecretPatterns"),
+		readline.PcItem("MinimalEnv", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("EnvAllowlist"),
+		readline.PcItem("JUnitDir"),
+		readline.PcItem("CoverageThreshold"),
+		readline.PcItem("WatchIgnore"),
+		readline.PcItem("Language"),
+		readline.PcItem("Plugins"),
+		readline.PcItem("ScriptDirs"),
+		readline.PcItem("HelpExport", readline.PcItem("markdown"), readline.PcItem("man"), readline.PcItem("bash")),
+		readline.PcItem("RunLogs", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("RetainRuns"),
+		readline.PcItem("RetainMaxSize"),
+		readline.PcItem("RetainMaxAge"),
+		readline.PcItem("ShowDiffs", readline.PcItem("unified"), readline.PcItem("side-by-side")),
+		readline.PcItem("RunSnapshots", readline.PcItem("true"), readline.PcItem("false")),
+		readline.PcItem("IdleShutdown"),
+		readline.PcItem("ShutdownAt"),
+		readline.PcItem("EventBus"),
+		readline.PcItem("EventBusPeers"),
+		readline.PcItem("DefaultLimits"),
 	}
 }
 
@@ -90,6 +135,113 @@ func keyKombItems() []readline.PrefixCompleterInterface {
 	}
 }
 
+// completionIndex is a sorted index of the command and alias names for the interactive shell
+// it is updated incrementally when commands or aliases are added or removed,
+// the builtins and their sub commands are completed by the prefix completer
+type completionIndex struct {
+	names []string
+	mutex *sync.RWMutex
+}
+
+// create a new completion index instance
+func newCompletionIndex() *completionIndex {
+	return &completionIndex{
+		names: make([]string, 0),
+		mutex: &sync.RWMutex{},
+	}
+}
+
+// add a name to the index
+// thread safe
+func (c *completionIndex) add(name string) {
+
+	defer profilePhase("completion setup")()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	i := sort.SearchStrings(c.names, name)
+	if i < len(c.names) && c.names[i] == name {
+		return
+	}
+
+	c.names = append(c.names, "")
+	copy(c.names[i+1:], c.names[i:])
+	c.names[i] = name
+}
+
+// remove a name from the index
+// thread safe
+func (c *completionIndex) remove(name string) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	i := sort.SearchStrings(c.names, name)
+	if i < len(c.names) && c.names[i] == name {
+		c.names = append(c.names[:i], c.names[i+1:]...)
+	}
+}
+
+// check if the name is in the index
+// thread safe
+func (c *completionIndex) contains(name string) bool {
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	i := sort.SearchStrings(c.names, name)
+	return i < len(c.names) && c.names[i] == name
+}
+
+// get the completions for all names starting with prefix
+// only the missing part of the names is returned
+// thread safe
+func (c *completionIndex) complete(prefix string) (candidates [][]rune) {
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for i := sort.SearchStrings(c.names, prefix); i < len(c.names); i++ {
+		if !strings.HasPrefix(c.names[i], prefix) {
+			break
+		}
+		candidates = append(candidates, []rune(c.names[i][len(prefix):]+" "))
+	}
+
+	return
+}
+
+// Do implements the readline.AutoCompleter interface
+func (c *completionIndex) Do(line []rune, pos int) ([][]rune, int) {
+
+	var input = strings.TrimLeft(string(line[:pos]), " ")
+
+	// complete the first word: commands, aliases and builtins
+	if !strings.Contains(input, " ") {
+		candidates, _ := completer.Do(line, pos)
+		return append(candidates, c.complete(input)...), len([]rune(input))
+	}
+
+	var (
+		fields = strings.SplitN(input, " ", 2)
+		rest   = strings.TrimLeft(fields[1], " ")
+	)
+
+	// complete command names and the export flag for the help builtin
+	if fields[0] == helpCommand && !strings.Contains(rest, " ") {
+		candidates, _ := completer.Do(line, pos)
+		return append(candidates, c.complete(rest)...), len([]rune(rest))
+	}
+
+	// commands complete their path arguments, aliases have no sub completions
+	if c.contains(fields[0]) {
+		return completePathArgument(fields[0], rest)
+	}
+
+	return completer.Do(line, pos)
+}
+
 // return a new default completer instance
 func newCompleter() *readline.PrefixCompleter {
 	c := readline.NewPrefixCompleter(
@@ -99,11 +251,21 @@ func newCompleter() *readline.PrefixCompleter {
 			readline.PcItem("commit"),
 		),
 		readline.PcItem("exit"),
-		readline.PcItem("help"),
+		readline.PcItem("help",
+			readline.PcItem("--export",
+				readline.PcItem("markdown"),
+				readline.PcItem("man"),
+				readline.PcItem("bash"),
+			),
+		),
 		readline.PcItem("info"),
 		readline.PcItem("clear"),
 		readline.PcItem("format"),
-		readline.PcItem("globals"),
+		readline.PcItem("globals",
+			readline.PcItem("set"),
+			readline.PcItem("validate"),
+			readline.PcItem("remove"),
+		),
 		readline.PcItem("version"),
 		readline.PcItem("config",
 			readline.PcItem("set",
@@ -144,7 +306,12 @@ func newCompleter() *readline.PrefixCompleter {
 		readline.PcItem("makefile",
 			readline.PcItem("migrate"),
 		),
-		readline.PcItem("data"),
+		readline.PcItem("data",
+			readline.PcItem("export"),
+			readline.PcItem("import",
+				readline.PcItemDynamic(fileCompleter),
+			),
+		),
 		readline.PcItem("alias",
 			readline.PcItem("set"),
 			readline.PcItem("remove"),
@@ -159,6 +326,65 @@ func newCompleter() *readline.PrefixCompleter {
 			readline.PcItem("remove"),
 		),
 		readline.PcItem("builtins"),
+		readline.PcItem("workspace",
+			readline.PcItem("add"),
+			readline.PcItem("remove"),
+			readline.PcItem("run",
+				readline.PcItem("--all"),
+			),
+		),
+		readline.PcItem("daemon",
+			readline.PcItem("start"),
+			readline.PcItem("stop"),
+			readline.PcItem("status"),
+		),
+		readline.PcItem("validate"),
+		readline.PcItem("pins",
+			readline.PcItem("approve",
+				readline.PcItem("all"),
+			),
+		),
+		readline.PcItem("ui"),
+		readline.PcItem("bench"),
+		readline.PcItem("watch"),
+		readline.PcItem("doctor"),
+		readline.PcItem("queue",
+			readline.PcItem("clear"),
+			readline.PcItem("remove"),
+			readline.PcItem("priority"),
+		),
+		readline.PcItem("gc",
+			readline.PcItem("--dry-run"),
+		),
+		readline.PcItem("snapshot",
+			readline.PcItem("diff"),
+		),
+		readline.PcItem("replay"),
+		readline.PcItem("clean",
+			readline.PcItem("--dry-run"),
+		),
+		readline.PcItem("bus",
+			readline.PcItem("on"),
+			readline.PcItem("off"),
+		),
+		readline.PcItem("usage"),
+		readline.PcItem("coverage",
+			readline.PcItem("html"),
+		),
+		readline.PcItem("signatures",
+			readline.PcItem("manifest"),
+		),
+		readline.PcItem("output",
+			readline.PcItem("runs"),
+			readline.PcItem("run"),
+			readline.PcItem("tail"),
+			readline.PcItem("search"),
+		),
+		readline.PcItem("bump",
+			readline.PcItem("major"),
+			readline.PcItem("minor"),
+			readline.PcItem("patch"),
+		),
 		readline.PcItem("keys",
 			readline.PcItem("set",
 				keyKombItems()...,
@@ -263,3 +489,97 @@ func fileCompleter(path string) []string {
 
 	return names
 }
+
+// complete a Path or Dir argument of a command from the filesystem
+// paths are relative to the working directory of the command
+func completePathArgument(name, input string) ([][]rune, int) {
+
+	commandMutex.Lock()
+	cmd, ok := commands[name]
+	commandMutex.Unlock()
+	if !ok {
+		return nil, 0
+	}
+
+	var (
+		words   = strings.Fields(input)
+		index   = len(words)
+		current string
+	)
+	if len(words) > 0 && !strings.HasSuffix(input, " ") {
+		index--
+		current = words[index]
+	}
+	if index >= len(cmd.args) || cmd.args[index].path == "" {
+		return nil, 0
+	}
+
+	dir := "."
+	if cmd.pkg != nil {
+		dir = cmd.pkg.dir
+	}
+
+	var candidates [][]rune
+	for _, path := range pathCandidates(dir, cmd.args[index], current) {
+		suffix := path[len(current):]
+		if !strings.HasSuffix(path, "/") {
+			suffix += " "
+		}
+		candidates = append(candidates, []rune(suffix))
+	}
+
+	return candidates, len([]rune(current))
+}
+
+// list the paths below dir starting with prefix that are valid for the argument
+// directories are always included for navigating, they end with a slash
+func pathCandidates(dir string, arg *commandArg, prefix string) (paths []string) {
+
+	var (
+		i      = strings.LastIndex(prefix, "/")
+		parent = prefix[:i+1]
+		base   = prefix[i+1:]
+	)
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, parent))
+	if err != nil {
+		return nil
+	}
+
+	for _, f := range files {
+
+		// hidden files only when asked for
+		if !strings.HasPrefix(f.Name(), base) || (strings.HasPrefix(f.Name(), ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+
+		isDir := f.IsDir()
+		if f.Mode()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(dir, parent, f.Name())); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+
+		switch {
+		case isDir:
+			paths = append(paths, parent+f.Name()+"/")
+		case arg.path == argTypePath && matchesArgGlob(arg.glob, f.Name()):
+			paths = append(paths, parent+f.Name())
+		}
+	}
+
+	return paths
+}
+
+// check if the file name matches one of the patterns of a path argument
+func matchesArgGlob(glob, name string) bool {
+	if glob == "" {
+		return true
+	}
+	for _, pattern := range strings.Split(glob, "|") {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -19,12 +19,14 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"os"
 	"reflect"
 	"strconv"
+	"sync"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/fsnotify/fsnotify"
@@ -41,62 +43,147 @@ var (
 	ErrInvalidLocalConfig = errors.New("local configuration file is invalid")
 
 	// path for global config file
-	globalConfigPath = os.Getenv("HOME") + "/.zeus_config.json"
+	globalConfigPath = homePath(".zeus_config.json")
 
 	// path for project config files
 	projectConfigPath = "zeus/zeus_config.json"
 	zeusDir           = "zeus"
+
+	// contents of the last config write of zeus itself
+	// the watcher does not reload them, only changes made by others
+	ownConfigWrite      []byte
+	ownConfigWriteMutex sync.Mutex
 )
 
 // config contains configurable parameters
 type config struct {
-	MakefileOverview    bool
-	AutoFormat          bool
-	FixParseErrors      bool
-	Colors              bool
-	PassCommandsToShell bool
-	WebInterface        bool
-	Interactive         bool
-	LogToFileColor      bool
-	LogToFile           bool
-	Debug               bool
-	RecursionDepth      int
-	ProjectNamePrompt   bool
-	AllowUntypedArgs    bool
-	ColorProfile        string
-	HistoryFile         bool
-	HistoryLimit        int
-	ExitOnInterrupt     bool
-	DisableTimestamps   bool
-	PrintBuiltins       bool
-	StopOnError         bool
-	DumpScriptOnError   bool
+	MakefileOverview      bool
+	AutoFormat            bool
+	FixParseErrors        bool
+	Colors                bool
+	PassCommandsToShell   bool
+	WebInterface          bool
+	Interactive           bool
+	LogToFileColor        bool
+	LogToFile             bool
+	Debug                 bool
+	RecursionDepth        int
+	ProjectNamePrompt     bool
+	AllowUntypedArgs      bool
+	ColorProfile          string
+	HistoryFile           bool
+	HistoryLimit          int
+	ExitOnInterrupt       bool
+	DisableTimestamps     bool
+	PrintBuiltins         bool
+	StopOnError           bool
+	DumpScriptOnError     bool
+	VersionFile           string
+	ReleaseChain          string
+	GlobalsPrefix         string
+	PackagePatterns       string
+	LazyParsing           bool
+	ParserWorkers         int
+	OutputBufferSize      int
+	HeaderCache           bool
+	OutputHistorySize     int
+	ShutdownTimeout       int
+	StrictVariables       bool
+	FollowSymlinks        bool
+	DefaultLimits         string
+	PinScripts            bool
+	AuditLog              bool
+	AuditSyslog           string
+	Sandbox               bool
+	SignatureVerification string
+	TrustedKeys           string
+	MaskSecrets           bool
+	SecretPatterns        string
+	MinimalEnv            bool
+	EnvAllowlist          string
+	JUnitDir              string
+	CoverageThreshold     int
+	WatchIgnore           string
+	Language              string
+	Plugins               string
+	ScriptDirs            string
+	HelpExport            string
+	RunLogs               bool
+	RetainRuns            int
+	RetainMaxSize         string
+	RetainMaxAge          string
+	ShowDiffs             string
+	RunSnapshots          bool
+	IdleShutdown          string
+	ShutdownAt            string
+	EventBus              string
+	EventBusPeers         string
 }
 
 // newConfig returns the default configuration in case there is no config file
 func newConfig() *config {
 	return &config{
-		MakefileOverview:    true,
-		AutoFormat:          true,
-		FixParseErrors:      true,
-		Colors:              true,
-		PassCommandsToShell: true,
-		WebInterface:        false,
-		Interactive:         true,
-		LogToFileColor:      false,
-		LogToFile:           true,
-		Debug:               false,
-		RecursionDepth:      1,
-		ProjectNamePrompt:   true,
-		AllowUntypedArgs:    false,
-		ColorProfile:        "default",
-		HistoryFile:         true,
-		HistoryLimit:        20,
-		ExitOnInterrupt:     true,
-		DisableTimestamps:   false,
-		PrintBuiltins:       true,
-		StopOnError:         true,
-		DumpScriptOnError:   true,
+		MakefileOverview:      true,
+		AutoFormat:            true,
+		FixParseErrors:        true,
+		Colors:                true,
+		PassCommandsToShell:   true,
+		WebInterface:          false,
+		Interactive:           true,
+		LogToFileColor:        false,
+		LogToFile:             true,
+		Debug:                 false,
+		RecursionDepth:        1,
+		ProjectNamePrompt:     true,
+		AllowUntypedArgs:      false,
+		ColorProfile:          "default",
+		HistoryFile:           true,
+		HistoryLimit:          20,
+		ExitOnInterrupt:       true,
+		DisableTimestamps:     false,
+		PrintBuiltins:         true,
+		StopOnError:           true,
+		DumpScriptOnError:     true,
+		VersionFile:           "",
+		ReleaseChain:          "",
+		GlobalsPrefix:         "",
+		PackagePatterns:       "*/zeus */*/zeus",
+		LazyParsing:           true,
+		ParserWorkers:         0,
+		OutputBufferSize:      64 * 1024,
+		HeaderCache:           true,
+		OutputHistorySize:     1000,
+		ShutdownTimeout:       5,
+		StrictVariables:       false,
+		FollowSymlinks:        true,
+		DefaultLimits:         "",
+		PinScripts:            false,
+		AuditLog:              false,
+		AuditSyslog:           "",
+		Sandbox:               false,
+		SignatureVerification: "",
+		TrustedKeys:           "",
+		MaskSecrets:           true,
+		SecretPatterns:        "AKIA[0-9A-Z]{16} gh[pousr]_[A-Za-z0-9]{36} xox[baprs]-[A-Za-z0-9-]{10,}",
+		MinimalEnv:            false,
+		EnvAllowlist:          "PATH HOME USER LOGNAME SHELL TERM LANG LC_* TMPDIR TZ",
+		JUnitDir:              "",
+		CoverageThreshold:     0,
+		WatchIgnore:           ".* vendor node_modules *.log *.swp *~",
+		Language:              "",
+		Plugins:               "",
+		ScriptDirs:            "",
+		HelpExport:            "",
+		RunLogs:               false,
+		RetainRuns:            0,
+		RetainMaxSize:         "",
+		RetainMaxAge:          "",
+		ShowDiffs:             "",
+		RunSnapshots:          false,
+		IdleShutdown:          "",
+		ShutdownAt:            "",
+		EventBus:              "",
+		EventBusPeers:         "",
 	}
 }
 
@@ -148,13 +235,14 @@ func parseProjectConfig() (*config, error) {
 		return nil, ErrConfigFileIsADirectory
 	}
 
-	contents, err := ioutil.ReadFile(projectConfigPath)
-	if err != nil {
-		return nil, err
-	}
-
-	err = json.Unmarshal(contents, c)
+	err = readWithFallback(projectConfigPath, func(contents []byte) error {
+		c = new(config)
+		return json.Unmarshal(contents, c)
+	})
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
 		Log.WithError(err).Fatal("failed to unmarshal confg - invalid JSON")
 	}
 
@@ -172,6 +260,10 @@ func handleConfigCommand(args []string) {
 			printConfigUsageErr()
 			return
 		}
+		if readOnly {
+			Log.Error(ErrReadOnly)
+			return
+		}
 		conf.setValue(args[2], args[3])
 	case "get":
 		if len(args) < 3 {
@@ -188,36 +280,56 @@ func handleConfigCommand(args []string) {
 // update config on disk
 func (c *config) update() {
 
+	if readOnly {
+		Log.Debug("read-only mode, not saving config")
+		return
+	}
+
 	// make it pretty
 	b, err := json.MarshalIndent(conf, "", "    ")
 	if err != nil {
 		Log.WithError(err).Fatal("failed to marshal config")
 	}
 
-	// open the config file write only and truncate if it exists
-	f, err := os.OpenFile(projectConfigPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0700)
-	if err != nil {
-		Log.WithError(err).Fatal("failed to open config")
-	}
+	// keep the previous contents for showing the changes
+	old, _ := ioutil.ReadFile(projectConfigPath)
+
+	// replace the config file atomically
+	ownConfigWriteMutex.Lock()
+	ownConfigWrite = b
+	ownConfigWriteMutex.Unlock()
 
-	// write to file
-	_, err = f.Write(b)
+	err = writeFileAtomic(projectConfigPath, b, 0700)
 	if err != nil {
 		Log.WithError(err).Fatal("failed to write config")
 	}
+
+	if old != nil {
+		printDiff(projectConfigPath, old, b)
+	}
 }
 
 // watch and reload on changes
+// the config is replaced atomically by zeus and most editors, so the rename creating the file is watched as well
 func (c *config) watch() {
 
-	err := addEvent(projectConfigPath, fsnotify.Write, func(event fsnotify.Event) {
+	err := addEvent(projectConfigPath, fsnotify.Write|fsnotify.Create, func(event fsnotify.Event) {
 
 		// check if the event name is correct because watching the zeus dir will also result in an event for zeus/config.json
 		if event.Name == projectConfigPath {
 
 			b, err := ioutil.ReadFile(projectConfigPath)
 			if err != nil {
-				Log.WithError(err).Fatal("failed to read config")
+				Log.WithError(err).Error("failed to read config")
+				return
+			}
+
+			// nothing changed since zeus wrote the config
+			ownConfigWriteMutex.Lock()
+			own := bytes.Equal(b, ownConfigWrite)
+			ownConfigWriteMutex.Unlock()
+			if own {
+				return
 			}
 
 			err = json.Unmarshal(b, c)
@@ -225,6 +337,8 @@ func (c *config) watch() {
 				Log.WithError(err).Error("config parse error")
 				return
 			}
+			invalidateSecrets()
+			invalidateMessages()
 		}
 	}, "")
 	if err != nil {
@@ -241,6 +355,8 @@ func (c *config) getFieldInfo(field string) string {
 		return "field type: " + f.Kind().String() + ", value: " + strconv.FormatBool(f.Bool())
 	case reflect.Int:
 		return "field type: " + f.Kind().String() + ", value: " + strconv.Itoa(int(f.Int()))
+	case reflect.String:
+		return "field type: " + f.Kind().String() + ", value: " + f.String()
 	default:
 		Log.Error(f.Kind())
 		return "unknown field"
@@ -278,6 +394,11 @@ func (c *config) setValue(field, value string) {
 
 		f.SetInt(i)
 
+		Log.Info("set config field ", field, " to ", value)
+
+	case reflect.String:
+		f.SetString(value)
+
 		Log.Info("set config field ", field, " to ", value)
 	default:
 		Log.Error("unknown type: ", f.Kind())
@@ -289,6 +410,8 @@ func (c *config) setValue(field, value string) {
 
 // handle the config by applying updated values
 func (c *config) handle() {
+	invalidateSecrets()
+	invalidateMessages()
 	if c.Debug {
 		Log.Level = logrus.DebugLevel
 	} else {
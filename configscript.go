@@ -0,0 +1,369 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+var (
+	// ErrConfigScriptNotApproved means the config script is new or changed and was not approved
+	ErrConfigScriptNotApproved = errors.New("the config script was not approved")
+
+	// ErrGeneratedScriptExists means a file in the generated directory was not created by zeus
+	ErrGeneratedScriptExists = errors.New("a file that was not generated by zeus exists in the generated directory")
+)
+
+const (
+	// lua script generating commands and globals at load time
+	configScriptPath = "zeus/zeus.lua"
+
+	// directory for the scripts generated by the config script
+	generatedDir = "zeus/generated"
+
+	// marks the scripts written by zeus, only those are removed from the generated directory
+	generatedMarker = "# generated by " + configScriptPath + ", do not edit"
+)
+
+var (
+	// globals set by the config script, they are not persisted
+	// typed globals from the project data take precedence
+	generatedGlobals = map[string]string{}
+
+	// script paths of the generated commands for their names
+	generatedPaths = map[string]string{}
+)
+
+// lua functions available to the config script, command and global are implemented in go
+const luaPrelude = `
+function matrix(axes)
+	local keys = {}
+	for k in pairs(axes) do
+		keys[#keys + 1] = k
+	end
+	table.sort(keys)
+
+	local result = {{}}
+	for _, k in ipairs(keys) do
+		local expanded = {}
+		for _, combination in ipairs(result) do
+			for _, v in ipairs(axes[k]) do
+				local c = {}
+				for ck, cv in pairs(combination) do
+					c[ck] = cv
+				end
+				c[k] = v
+				expanded[#expanded + 1] = c
+			end
+		end
+		result = expanded
+	end
+	return result
+end
+`
+
+// generatedCommand is a command declared by the config script
+type generatedCommand struct {
+	name   string
+	script string
+
+	// header fields, without the zeus- prefix
+	fields map[string]string
+}
+
+// run the config script and write the scripts for the generated commands
+// returns the script paths for the command names
+// the config script is executed and the scripts are written, so nothing is generated in read only mode
+func generateCommands() (map[string]string, error) {
+
+	if readOnly {
+		return nil, nil
+	}
+
+	if _, err := os.Stat(configScriptPath); err != nil {
+		return nil, nil
+	}
+
+	// the config script runs on every start, it must be approved like the scripts
+	if ok, err := pins.approved(configScriptPath); err != nil || !ok {
+		if !trustCommandSet() {
+			return nil, ErrConfigScriptNotApproved
+		}
+	}
+
+	generated, globals, err := runConfigScript()
+	if err != nil {
+		return nil, err
+	}
+
+	generatedGlobals = globals
+	invalidateSecrets()
+
+	// start from scratch, commands removed from the config script must disappear
+	err = removeGeneratedScripts()
+	if err != nil {
+		return nil, err
+	}
+	generatedPaths = map[string]string{}
+
+	if len(generated) == 0 {
+		return nil, nil
+	}
+
+	err = os.MkdirAll(generatedDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths = map[string]string{}
+	for i, c := range generated {
+
+		// the names can contain characters that are not valid in filenames
+		path := filepath.ToSlash(filepath.Join(generatedDir, strconv.Itoa(i)+f.fileExtension))
+
+		err = writeGeneratedScript(path, c.render())
+		if err != nil {
+			return nil, err
+		}
+		paths[c.name] = path
+	}
+
+	generatedPaths = paths
+
+	return paths, nil
+}
+
+// remove the scripts written by zeus from the generated directory
+// other files are left alone, the directory is only removed once it is empty
+func removeGeneratedScripts() error {
+
+	infos, err := ioutil.ReadDir(generatedDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		path := filepath.Join(generatedDir, info.Name())
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(content, []byte(generatedMarker)) {
+			continue
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	// fails if the directory contains files of the user
+	os.Remove(generatedDir)
+
+	return nil
+}
+
+// write a generated script to path
+// a file that is still there after removeGeneratedScripts belongs to the user and is not overwritten
+func writeGeneratedScript(path string, content []byte) error {
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0700)
+	if err != nil {
+		if os.IsExist(err) {
+			return ErrGeneratedScriptExists
+		}
+		return err
+	}
+
+	_, err = f.Write(content)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// render the script for the command, with the fields in the zeus header
+func (c *generatedCommand) render() []byte {
+
+	var (
+		b     bytes.Buffer
+		names = make([]string, 0, len(c.fields))
+	)
+
+	for name := range c.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b.WriteString(p.shebang + "\n\n")
+	b.WriteString("# ---------------------------------------------------------------------- #\n")
+	for _, name := range names {
+		switch value := c.fields[name]; value {
+		case "false":
+		case "true":
+			b.WriteString("# @zeus-" + name + "\n")
+		default:
+			b.WriteString("# @zeus-" + name + ": " + value + "\n")
+		}
+	}
+	b.WriteString("# ---------------------------------------------------------------------- #\n")
+	b.WriteString(generatedMarker + "\n")
+	b.WriteString("# ---------------------------------------------------------------------- #\n\n")
+	b.WriteString(strings.TrimSpace(c.script) + "\n")
+
+	return b.Bytes()
+}
+
+// execute the config script with the embedded lua interpreter
+// the commands are returned in the order they were declared
+func runConfigScript() ([]*generatedCommand, map[string]string, error) {
+
+	var (
+		L         = lua.NewState()
+		generated []*generatedCommand
+		byName    = map[string]*generatedCommand{}
+		globals   = map[string]string{}
+	)
+	defer L.Close()
+
+	// command{name=..., script=..., field=...}
+	L.SetGlobal("command", L.NewFunction(func(L *lua.LState) int {
+
+		var (
+			t        = L.CheckTable(1)
+			name, ok = t.RawGetString("name").(lua.LString)
+			script   = t.RawGetString("script")
+		)
+		if !ok || script == lua.LNil {
+			L.ArgError(1, "name and script are required")
+		}
+		if _, ok := byName[string(name)]; ok {
+			L.RaiseError("command declared twice: %s", name)
+		}
+
+		c := &generatedCommand{
+			name:   string(name),
+			script: L.ToStringMeta(script).String(),
+			fields: map[string]string{},
+		}
+
+		t.ForEach(func(k, v lua.LValue) {
+
+			key := k.String()
+			if key == "name" || key == "script" {
+				return
+			}
+
+			value := L.ToStringMeta(v).String()
+
+			// lists are joined, chains with the parser separator
+			if list, ok := v.(*lua.LTable); ok {
+				sep := " "
+				if key == "chain" {
+					sep = " " + p.separator + " "
+				}
+				var items []string
+				for i := 1; i <= list.Len(); i++ {
+					items = append(items, L.ToStringMeta(list.RawGetInt(i)).String())
+				}
+				value = strings.Join(items, sep)
+			}
+
+			c.fields[strings.Replace(key, "_", "-", -1)] = strings.Replace(value, "\n", " ", -1)
+		})
+
+		byName[c.name] = c
+		generated = append(generated, c)
+
+		return 0
+	}))
+
+	// global(name, value)
+	L.SetGlobal("global", L.NewFunction(func(L *lua.LState) int {
+		globals[L.CheckString(1)] = L.ToStringMeta(L.Get(2)).String()
+		return 0
+	}))
+
+	err := L.DoString(luaPrelude)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = L.DoFile(configScriptPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return generated, globals, nil
+}
+
+// add the generated commands to the command map
+// commands from scripts take precedence
+func addGeneratedCommands(paths map[string]string) {
+
+	var cLog = Log.WithField("prefix", "addGeneratedCommands")
+
+	for name, path := range paths {
+
+		commandMutex.Lock()
+		_, ok := commands[name]
+		commandMutex.Unlock()
+
+		if ok {
+			cLog.Warn("generated command ", name, " conflicts with a script, ignoring it")
+			continue
+		}
+
+		job := p.AddJob(path)
+		cmd, err := job.newCommand(path)
+		p.RemoveJob(job)
+		if err != nil {
+			cLog.WithError(err).Error("failed to create generated command " + name)
+			continue
+		}
+		cmd.name = name
+
+		commandMutex.Lock()
+		commands[name] = cmd
+		commandMutex.Unlock()
+		commandIndex.add(name)
+
+		cLog.Debug("added generated command " + name)
+	}
+}
@@ -0,0 +1,409 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mgutz/ansi"
+)
+
+var (
+	// ErrCoverageThreshold means the total coverage is below the configured threshold
+	ErrCoverageThreshold = errors.New("coverage below threshold")
+
+	// ErrNoCoverage means none of the declared coverage files exist
+	ErrNoCoverage = errors.New("no coverage files found, run the test commands first")
+
+	// ErrUnknownCoverageFormat means a coverage file is neither a go cover profile nor lcov
+	ErrUnknownCoverageFormat = errors.New("unknown coverage format, expected a go cover profile or lcov")
+
+	// path for the HTML coverage report
+	coverageReportPath = "coverage.html"
+)
+
+// coverage maps source files to the hit count of each instrumented line
+type coverage map[string]map[int]int
+
+// add hits for a line, merging keeps the highest count
+func (c coverage) add(file string, line, hits int) {
+	if c[file] == nil {
+		c[file] = make(map[int]int)
+	}
+	if old, ok := c[file][line]; !ok || hits > old {
+		c[file][line] = hits
+	}
+}
+
+// count covered and instrumented lines of a file, or all files if file is empty
+func (c coverage) count(file string) (covered, total int) {
+	for f, lines := range c {
+		if file != "" && f != file {
+			continue
+		}
+		for _, hits := range lines {
+			total++
+			if hits > 0 {
+				covered++
+			}
+		}
+	}
+	return
+}
+
+// get the coverage in percent
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(covered) / float64(total) * 100
+}
+
+// parse a go cover profile or lcov file into c
+func (c coverage) parseFile(path string) error {
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case bytes.HasPrefix(contents, []byte("mode:")):
+		c.parseGoProfile(contents)
+	case bytes.Contains(contents, []byte("SF:")):
+		c.parseLcov(contents)
+	default:
+		return ErrUnknownCoverageFormat
+	}
+
+	return nil
+}
+
+// parse a go cover profile
+// lines: file.go:startLine.startCol,endLine.endCol numStatements count
+func (c coverage) parseGoProfile(contents []byte) {
+
+	s := bufio.NewScanner(bytes.NewReader(contents))
+	for s.Scan() {
+
+		line := s.Text()
+		colon := strings.LastIndex(line, ":")
+		if strings.HasPrefix(line, "mode:") || colon < 0 {
+			continue
+		}
+
+		fields := strings.Fields(line[colon+1:])
+		if len(fields) != 3 {
+			continue
+		}
+
+		positions := strings.Split(fields[0], ",")
+		if len(positions) != 2 {
+			continue
+		}
+
+		start, err1 := strconv.Atoi(strings.SplitN(positions[0], ".", 2)[0])
+		end, err2 := strconv.Atoi(strings.SplitN(positions[1], ".", 2)[0])
+		hits, err3 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+
+		for l := start; l <= end; l++ {
+			c.add(line[:colon], l, hits)
+		}
+	}
+}
+
+// parse an lcov tracefile
+func (c coverage) parseLcov(contents []byte) {
+
+	var (
+		s    = bufio.NewScanner(bytes.NewReader(contents))
+		file string
+	)
+
+	for s.Scan() {
+
+		line := strings.TrimSpace(s.Text())
+
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			file = strings.TrimPrefix(line, "SF:")
+		case strings.HasPrefix(line, "DA:") && file != "":
+			fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(fields) < 2 {
+				continue
+			}
+			n, err1 := strconv.Atoi(fields[0])
+			hits, err2 := strconv.Atoi(fields[1])
+			if err1 == nil && err2 == nil {
+				c.add(file, n, hits)
+			}
+		case line == "end_of_record":
+			file = ""
+		}
+	}
+}
+
+// collect and merge the coverage files declared by the commands
+func collectCoverage() (coverage, error) {
+
+	var (
+		c     = make(coverage)
+		found int
+		seen  = make(map[string]bool)
+	)
+
+	commandMutex.Lock()
+	var files []string
+	for _, cmd := range commands {
+		for _, path := range strings.Fields(cmd.coverage) {
+			if cmd.pkg != nil && !filepath.IsAbs(path) {
+				path = filepath.Join(cmd.pkg.dir, path)
+			}
+			if !seen[path] {
+				seen[path] = true
+				files = append(files, path)
+			}
+		}
+	}
+	commandMutex.Unlock()
+
+	sort.Strings(files)
+
+	for _, path := range files {
+		err := c.parseFile(path)
+		if os.IsNotExist(err) {
+			Log.Debug("coverage file does not exist: ", path)
+			continue
+		}
+		if err != nil {
+			return nil, errors.New(path + ": " + err.Error())
+		}
+		found++
+	}
+
+	if found == 0 {
+		return nil, ErrNoCoverage
+	}
+
+	return c.normalize(), nil
+}
+
+// merge the entries for the same source file
+// go profiles use import paths, lcov files absolute or relative paths
+func (c coverage) normalize() coverage {
+
+	merged := make(coverage)
+	for file, lines := range c {
+
+		name := file
+		if src := resolveSource(file); src != "" {
+			name = filepath.ToSlash(src)
+		}
+
+		for line, hits := range lines {
+			merged.add(name, line, hits)
+		}
+	}
+
+	return merged
+}
+
+// sorted file names
+func (c coverage) files() (files []string) {
+	for f := range c {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return
+}
+
+// print the coverage of every file and the total
+func (c coverage) printSummary() {
+
+	var maxLen int
+	for f := range c {
+		if len(f) > maxLen {
+			maxLen = len(f)
+		}
+	}
+
+	for _, f := range c.files() {
+		covered, total := c.count(f)
+		l.Println(cp.colorText + pad(f, maxLen+2) + coverageColor(percent(covered, total)) + pad(strconv.FormatFloat(percent(covered, total), 'f', 1, 64)+"%", 8) + cp.colorText + strconv.Itoa(covered) + "/" + strconv.Itoa(total) + ansi.Reset)
+	}
+
+	covered, total := c.count("")
+	l.Println(cp.colorText + pad("total", maxLen+2) + coverageColor(percent(covered, total)) + strconv.FormatFloat(percent(covered, total), 'f', 1, 64) + "%" + ansi.Reset)
+}
+
+// check if the percentage is below the configured threshold
+func belowThreshold(p float64) bool {
+	return conf.CoverageThreshold > 0 && p < float64(conf.CoverageThreshold)
+}
+
+// color for a coverage percentage, relative to the threshold
+func coverageColor(p float64) string {
+	if belowThreshold(p) {
+		return ansi.Red
+	}
+	return ansi.Green
+}
+
+// find the source of a covered file
+// go profiles contain import paths, so leading path elements are stripped until a file exists
+func resolveSource(file string) string {
+
+	if filepath.IsAbs(file) {
+		if _, err := os.Stat(file); err != nil {
+			return ""
+		}
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+				return rel
+			}
+		}
+		return file
+	}
+
+	parts := strings.Split(filepath.ToSlash(file), "/")
+	for i := range parts {
+		path := filepath.Join(parts[i:]...)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// write the HTML report with a summary table and the annotated sources
+func (c coverage) writeHTML(path string) error {
+
+	var b bytes.Buffer
+
+	b.WriteString(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Coverage</title><style>
+body { font-family: sans-serif; } table { border-collapse: collapse; } td, th { padding: 2px 10px; text-align: left; }
+pre { margin: 0; } .hit { background: #dfd; } .miss { background: #fdd; } .low { color: #c00; }
+</style></head><body><h1>Coverage</h1><table><tr><th>File</th><th>Coverage</th><th>Lines</th></tr>
+`)
+
+	row := func(name, anchor string, covered, total int) {
+		p := percent(covered, total)
+		class := ""
+		if belowThreshold(p) {
+			class = ` class="low"`
+		}
+		if anchor != "" {
+			name = `<a href="#` + anchor + `">` + name + `</a>`
+		}
+		b.WriteString("<tr><td>" + name + "</td><td" + class + ">" + strconv.FormatFloat(p, 'f', 1, 64) + "%</td><td>" + strconv.Itoa(covered) + "/" + strconv.Itoa(total) + "</td></tr>\n")
+	}
+
+	for i, f := range c.files() {
+		covered, total := c.count(f)
+		row(html.EscapeString(f), "f"+strconv.Itoa(i), covered, total)
+	}
+	covered, total := c.count("")
+	row("<b>total</b>", "", covered, total)
+	b.WriteString("</table>\n")
+
+	for i, f := range c.files() {
+
+		b.WriteString(`<h2 id="f` + strconv.Itoa(i) + `">` + html.EscapeString(f) + "</h2>\n")
+
+		src := resolveSource(f)
+		if src == "" {
+			b.WriteString("<p>source not found</p>\n")
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(src)
+		if err != nil {
+			return err
+		}
+
+		for n, line := range strings.Split(string(contents), "\n") {
+			class := ""
+			if hits, ok := c[f][n+1]; ok {
+				class = "miss"
+				if hits > 0 {
+					class = "hit"
+				}
+			}
+			b.WriteString(`<pre class="` + class + `">` + pad(strconv.Itoa(n+1), 6) + html.EscapeString(line) + "</pre>\n")
+		}
+	}
+
+	b.WriteString("</body></html>\n")
+
+	return writeFileAtomic(path, b.Bytes(), 0644)
+}
+
+func printCoverageUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: coverage [html]")
+}
+
+// handle coverage shell command
+// returns an error if the coverage is below the threshold
+func handleCoverageCommand(args []string) error {
+
+	if len(args) > 2 || (len(args) == 2 && args[1] != "html") {
+		printCoverageUsageErr()
+		return ErrInvalidUsage
+	}
+
+	c, err := collectCoverage()
+	if err != nil {
+		Log.WithError(err).Error("failed to collect coverage")
+		return err
+	}
+
+	c.printSummary()
+
+	if len(args) == 2 {
+		err = c.writeHTML(coverageReportPath)
+		if err != nil {
+			Log.WithError(err).Error("failed to write coverage report")
+			return err
+		}
+		l.Println(cp.colorText + "coverage report written to " + coverageReportPath + ansi.Reset)
+	}
+
+	covered, total := c.count("")
+	if p := percent(covered, total); belowThreshold(p) {
+		Log.WithError(ErrCoverageThreshold).Error(strconv.FormatFloat(p, 'f', 1, 64), "% < ", conf.CoverageThreshold, "%")
+		return ErrCoverageThreshold
+	}
+
+	return nil
+}
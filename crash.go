@@ -0,0 +1,173 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"runtime"
+	runtimeDebug "runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/mgutz/ansi"
+)
+
+var (
+	// values of config fields and globals with matching names are redacted in crash reports
+	secretName = regexp.MustCompile(`(?i)(secret|token|passw|credential|apikey|api_key|private)`)
+
+	// number of history entries included in crash reports
+	crashReportHistory = 20
+
+	// format for crash report file names
+	crashReportTimeFormat = "2006-01-02_15-04-05"
+)
+
+// recover from a panic, write a crash report, restore the terminal and exit
+// must be deferred directly
+func recoverPanic() {
+	if r := recover(); r != nil {
+		handlePanic(r, runtimeDebug.Stack())
+		restoreTerminal()
+		shutdown(exitInternalError)
+	}
+}
+
+// recover from a panic in a long running loop, like the interactive shell or the event watchers
+// the crash is reported and the loop keeps running
+// must be deferred directly
+func recoverAndContinue() {
+	if r := recover(); r != nil {
+		handlePanic(r, runtimeDebug.Stack())
+		flushOutput()
+		restoreForeground()
+	}
+}
+
+// report the panic to the user and write the crash report
+func handlePanic(r interface{}, stack []byte) {
+
+	path, err := writeCrashReport(r, stack)
+
+	l.Println(ansi.Reset)
+	Log.Error("zeus crashed: ", r)
+	if err != nil {
+		Log.WithError(err).Error("failed to write crash report")
+		os.Stderr.Write(stack)
+		return
+	}
+	Log.Error("crash report written to ", path, ", please attach it when reporting the bug")
+}
+
+// leave the raw mode of the terminal
+func restoreTerminal() {
+	if rl != nil {
+		rl.Close()
+	}
+	print(ansi.Reset)
+}
+
+// write a crash report to the zeus directory and return its path
+func writeCrashReport(r interface{}, stack []byte) (string, error) {
+
+	var (
+		b    bytes.Buffer
+		path = zeusDir + "/crash-" + time.Now().Format(crashReportTimeFormat) + ".log"
+	)
+
+	b.WriteString("ZEUS crash report\n\n")
+	b.WriteString("time:    " + time.Now().Format(time.RFC3339) + "\n")
+	b.WriteString("version: " + version + "\n")
+	b.WriteString("go:      " + runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH + "\n")
+	b.WriteString("args:    " + strings.Join(os.Args, " ") + "\n\n")
+
+	b.WriteString(fmt.Sprintf("panic: %v\n\n", r))
+	b.Write(stack)
+
+	b.WriteString("\nrecent commands:\n")
+	for _, line := range recentHistory() {
+		b.WriteString("  " + line + "\n")
+	}
+
+	b.WriteString("\nconfig:\n")
+	b.WriteString(redactedConfig())
+
+	if projectData != nil {
+		b.WriteString("\n\nglobals:\n")
+		for name, g := range projectData.Globals {
+			value := g.Value
+			if secretName.MatchString(name) {
+				value = "<redacted>"
+			}
+			b.WriteString("  " + name + " = " + value + "\n")
+		}
+	}
+
+	return path, ioutil.WriteFile(path, []byte(maskSecrets(b.String())), 0600)
+}
+
+// get the last entries of the shell history
+func recentHistory() []string {
+
+	c, err := ioutil.ReadFile(historyFilePath)
+	if err != nil {
+		return nil
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(c)), "\n")
+	if len(lines) > crashReportHistory {
+		lines = lines[len(lines)-crashReportHistory:]
+	}
+	return lines
+}
+
+// get the config as JSON, with the values of secret fields redacted
+func redactedConfig() string {
+
+	if conf == nil {
+		return "not loaded"
+	}
+
+	var fields map[string]interface{}
+
+	b, err := json.Marshal(conf)
+	if err == nil {
+		err = json.Unmarshal(b, &fields)
+	}
+	if err != nil {
+		return err.Error()
+	}
+
+	for name := range fields {
+		if secretName.MatchString(name) {
+			fields[name] = "<redacted>"
+		}
+	}
+
+	b, err = json.MarshalIndent(fields, "", "    ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
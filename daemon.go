@@ -0,0 +1,523 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mgutz/ansi"
+)
+
+var (
+	// ErrDaemonNotRunning means there is no daemon listening on the socket
+	ErrDaemonNotRunning = errors.New("daemon is not running")
+
+	// ErrDaemonRunning means there is already a daemon listening on the socket
+	ErrDaemonRunning = errors.New("daemon is already running")
+
+	// path for the unix socket of the project daemon
+	daemonSocketPath = "zeus/zeus.sock"
+
+	// fingerprint of the scripts the commands of the daemon were parsed from
+	// protected by runMutex
+	daemonScripts string
+)
+
+// daemonRequest is sent by the client to the daemon
+type daemonRequest struct {
+
+	// commandline arguments, without the executable name
+	Args []string
+
+	// environment and working directory of the client
+	Env []string
+	Dir string
+
+	// the client was started with the keep going flag
+	KeepGoing bool
+
+	// stop the daemon
+	Stop bool
+
+	// input for the command, sent after the request
+	Input []byte
+
+	// the input of the client is closed
+	InputClosed bool
+}
+
+// daemonResponse is streamed from the daemon to the client
+type daemonResponse struct {
+
+	// output of the command
+	Output []byte
+
+	// output belongs to stderr
+	Stderr bool
+
+	// command has finished
+	Done bool
+
+	// exit code for the client
+	ExitCode int
+}
+
+// daemonWriter streams the written data as responses to the client
+type daemonWriter struct {
+	enc    *json.Encoder
+	stderr bool
+	mutex  *sync.Mutex
+}
+
+// implement io.Writer
+func (w *daemonWriter) Write(b []byte) (int, error) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := w.enc.Encode(&daemonResponse{
+		Output: b,
+		Stderr: w.stderr,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+func printDaemonUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: daemon [start] [stop] [status]")
+}
+
+// handle daemon shell command
+func handleDaemonCommand(args []string) {
+
+	if len(args) < 2 {
+		printDaemonStatus()
+		return
+	}
+
+	switch args[1] {
+	case "start":
+		if checkExecutionAllowed("daemon") != nil {
+			return
+		}
+		err := runDaemon()
+		if err != nil {
+			Log.WithError(err).Error("daemon failed")
+		}
+	case "stop":
+		err := stopDaemon()
+		if err != nil {
+			Log.WithError(err).Error("failed to stop daemon")
+			return
+		}
+		Log.Info("daemon stopped")
+	case "status":
+		printDaemonStatus()
+	default:
+		printDaemonUsageErr()
+	}
+}
+
+// print whether the daemon is running
+func printDaemonStatus() {
+
+	conn, err := net.Dial("unix", daemonSocketPath)
+	if err != nil {
+		l.Println("daemon is not running.")
+		return
+	}
+	conn.Close()
+
+	l.Println("daemon is running on " + daemonSocketPath)
+}
+
+// listen on the project socket and execute the incoming requests
+// blocks until the daemon is stopped
+func runDaemon() error {
+
+	var cLog = Log.WithField("prefix", "runDaemon")
+
+	// check for an existing daemon or a stale socket
+	if conn, err := net.Dial("unix", daemonSocketPath); err == nil {
+		conn.Close()
+		return ErrDaemonRunning
+	}
+	os.Remove(daemonSocketPath)
+
+	limits, err := newSessionLimits()
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: daemonSocketPath, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	defer os.Remove(daemonSocketPath)
+
+	// only the user running the daemon may execute commands with it
+	err = os.Chmod(daemonSocketPath, 0600)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	daemonScripts = scriptsFingerprint()
+
+	// the watchers are only started in interactive mode, the daemon needs them as well
+	if !conf.Interactive {
+		go conf.watch()
+		go listenEventBus()
+		if conf.AutoFormat && !readOnly {
+			go f.watchzeusDir()
+		}
+	}
+
+	l.Println(printPrompt() + "daemon listening on " + cp.colorPrompt + daemonSocketPath + cp.colorText)
+
+	var (
+		stopped  = make(chan struct{})
+		stopOnce sync.Once
+	)
+
+	for {
+		if limits != nil {
+			listener.SetDeadline(limits.deadline())
+		}
+
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-stopped:
+				return nil
+			default:
+			}
+			// the deadline moves when commands were executed in the meantime
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				if reason := limits.expired(); reason != "" {
+					cLog.Info("stopping daemon: " + reason)
+					return listener.Close()
+				}
+				continue
+			}
+			return err
+		}
+
+		// a client that runs a long command or does not close its input must not block the others
+		go func(conn net.Conn) {
+
+			defer conn.Close()
+
+			if handleDaemonConn(conn) {
+				stopOnce.Do(func() {
+					cLog.Info("received stop request")
+					close(stopped)
+					listener.Close()
+				})
+			}
+		}(conn)
+	}
+}
+
+// handle a client connection
+// returns true if the daemon should be stopped
+func handleDaemonConn(conn net.Conn) bool {
+
+	var (
+		req = new(daemonRequest)
+		enc = json.NewEncoder(conn)
+		dec = json.NewDecoder(conn)
+	)
+
+	err := dec.Decode(req)
+	if err != nil {
+		Log.WithError(err).Error("failed to decode daemon request")
+		return false
+	}
+
+	if req.Stop {
+		enc.Encode(&daemonResponse{Done: true})
+		return true
+	}
+
+	var (
+		mutex    = &sync.Mutex{}
+		stdout   = &daemonWriter{enc: enc, mutex: mutex}
+		stderr   = &daemonWriter{enc: enc, stderr: true, mutex: mutex}
+		exitCode int
+	)
+
+	// the queue is shown immediately, without waiting in it
+	if len(req.Args) > 0 && req.Args[0] == queueCommand {
+		handleQueueCommand(req.Args, log.New(stdout, "", 0))
+		enc.Encode(&daemonResponse{Done: true})
+		return false
+	}
+
+	// the input of the client is read by the command
+	// a file is passed to the command directly, the run does not wait for the client to close its input
+	input, inputWriter, err := os.Pipe()
+	if err != nil {
+		Log.WithError(err).Error("failed to create the input pipe for the client")
+		enc.Encode(&daemonResponse{Done: true, ExitCode: exitInternalError})
+		return false
+	}
+	defer input.Close()
+	go forwardDaemonInput(dec, inputWriter)
+
+	// one run at a time, the command execution uses global state
+	// the client is waiting, so its request is queued before the events
+	runs.enqueue(queueSourceDaemon, strings.Join(req.Args, " "), queuePriorityDaemon, func() error {
+
+		// the scripts might have changed since the daemon parsed them
+		if s := scriptsFingerprint(); s != daemonScripts {
+			daemonScripts = s
+			reloadCommands()
+		}
+
+		// redirect all input and output to the client
+		// and run with the environment and flags of the client
+		commandStdout = newColorWriter(stdout, "")
+		commandStderr = newColorWriter(stderr, ansi.Red)
+		clientStdin = input
+		clientEnv = req.Env
+		clientDir = req.Dir
+		daemonKeepGoing := keepGoing
+		keepGoing = req.KeepGoing
+		invalidateSecrets()
+		l.SetOutput(stdout)
+		logOut := Log.Out
+		Log.Out = &maskingWriter{stderr}
+
+		exitCode = executeDaemonRequest(req.Args)
+
+		commandStdout = oWriter
+		commandStderr = cWriter
+		clientStdin = nil
+		clientEnv = nil
+		clientDir = ""
+		keepGoing = daemonKeepGoing
+		invalidateSecrets()
+		l.SetOutput(logOutput)
+		Log.Out = logOut
+
+		// reset counters
+		numCommands = 0
+		currentCommand = 0
+
+		if exitCode != 0 {
+			return errors.New("exit code " + strconv.Itoa(exitCode))
+		}
+		return nil
+	}).wait()
+
+	enc.Encode(&daemonResponse{
+		Done:     true,
+		ExitCode: exitCode,
+	})
+
+	return false
+}
+
+// pass the input messages of the client to the pipe that is read by the command
+func forwardDaemonInput(dec *json.Decoder, w *os.File) {
+
+	defer w.Close()
+
+	for {
+		var req = new(daemonRequest)
+		if err := dec.Decode(req); err != nil || req.InputClosed {
+			return
+		}
+
+		// fails once the run is over and the pipe is closed
+		if _, err := w.Write(req.Input); err != nil {
+			return
+		}
+	}
+}
+
+// execute a command, command chain or alias for a client and return the exit code
+func executeDaemonRequest(args []string) int {
+
+	if len(args) == 0 {
+		return exitUsageError
+	}
+
+	if cmd, ok := commands[args[0]]; ok {
+
+		numCommands = getTotalCommandCount(cmd)
+
+		err := cmd.Run(args[1:])
+		if err != nil {
+			Log.WithError(err).Error("failed to execute " + cmd.name)
+			return exitCode(err)
+		}
+		return 0
+	}
+
+	if plugin, ok := pluginBuiltins[args[0]]; ok {
+		if plugin.runBuiltin(args) != nil {
+			return 1
+		}
+		return 0
+	}
+
+	if strings.Contains(args[0], p.separator) {
+		return exitCode(executeCommandChain(strings.Join(args, " ")))
+	}
+
+	if command, ok := projectData.Aliases[args[0]]; ok {
+		return exitCode(executeCommand(command))
+	}
+
+	Log.Error(ErrUnknownCommand, ": ", args[0])
+	return exitUsageError
+}
+
+// run the commandline arguments on the project daemon if there is one
+// returns false if no daemon is running
+func runOnDaemon(args []string) (bool, int) {
+
+	conn, err := net.Dial("unix", daemonSocketPath)
+	if err != nil {
+		return false, 0
+	}
+	defer conn.Close()
+
+	var enc = json.NewEncoder(conn)
+
+	dir, err := os.Getwd()
+	if err != nil {
+		Log.WithError(err).Error("failed to get the working directory")
+		return true, exitInternalError
+	}
+
+	err = enc.Encode(&daemonRequest{
+		Args:      args,
+		Env:       os.Environ(),
+		Dir:       dir,
+		KeepGoing: keepGoing,
+	})
+	if err != nil {
+		Log.WithError(err).Error("failed to send request to daemon")
+		return true, 1
+	}
+
+	// forward the input, the daemon runs the command with it
+	go sendDaemonInput(enc)
+
+	var dec = json.NewDecoder(conn)
+	for {
+		var res = new(daemonResponse)
+
+		err := dec.Decode(res)
+		if err != nil {
+			if err != io.EOF {
+				Log.WithError(err).Error("failed to read response from daemon")
+			}
+			return true, 1
+		}
+
+		if res.Done {
+			return true, res.ExitCode
+		}
+
+		if res.Stderr {
+			os.Stderr.Write(res.Output)
+		} else {
+			os.Stdout.Write(res.Output)
+		}
+	}
+}
+
+// send stdin to the daemon until it is closed
+func sendDaemonInput(enc *json.Encoder) {
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			if enc.Encode(&daemonRequest{Input: buf[:n]}) != nil {
+				return
+			}
+		}
+		if err != nil {
+			enc.Encode(&daemonRequest{InputClosed: true})
+			return
+		}
+	}
+}
+
+// fingerprint of the scripts the commands are parsed from
+// changes when a script is added, removed or modified
+func scriptsFingerprint() string {
+
+	scripts, err := projectScripts()
+	if err != nil {
+		Log.WithError(err).Debug("failed to collect the project scripts")
+	}
+	scripts = append(scripts, globalsScriptPath)
+	scripts = append(scripts, discoveredScripts()...)
+	scripts = append(scripts, projectExecutables()...)
+	sort.Strings(scripts)
+
+	var b strings.Builder
+	for _, path := range scripts {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintln(&b, path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	return b.String()
+}
+
+// send a stop request to the daemon
+func stopDaemon() error {
+
+	conn, err := net.Dial("unix", daemonSocketPath)
+	if err != nil {
+		return ErrDaemonNotRunning
+	}
+	defer conn.Close()
+
+	err = json.NewEncoder(conn).Encode(&daemonRequest{
+		Stop: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	var res = new(daemonResponse)
+	return json.NewDecoder(conn).Decode(res)
+}
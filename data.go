@@ -20,7 +20,6 @@ package main
 
 import (
 	"encoding/json"
-	"io/ioutil"
 	"os"
 
 	"strings"
@@ -53,40 +52,67 @@ type data struct {
 
 	// keys mapped to commands
 	KeyBindings map[string]string
+
+	// typed globals, injected into the environment of every command
+	Globals map[string]*typedGlobal
+
+	// parsed script headers mapped to the script path
+	HeaderCache map[string]*cachedHeader
+
+	// benchmark baselines mapped to the command name
+	Benchmarks map[string]*benchmarkStats
+
+	// fingerprints of the other projects after their commands succeeded, mapped to project:command
+	Dependencies map[string]string
+
+	// command chains executed when a peer on the event bus finished a command, mapped to command[:status]
+	BusReactions map[string]string
 }
 
 func newData() *data {
 	return &data{
-		BuildNumber: 0,
-		Deadline:    "",
-		Milestones:  make([]*milestone, 0),
-		Aliases:     make(map[string]string, 0),
-		Events:      make(map[string]*Event, 0),
-		Author:      "",
-		KeyBindings: make(map[string]string, 0),
+		BuildNumber:  0,
+		Deadline:     "",
+		Milestones:   make([]*milestone, 0),
+		Aliases:      make(map[string]string, 0),
+		Events:       make(map[string]*Event, 0),
+		Author:       "",
+		KeyBindings:  make(map[string]string, 0),
+		Globals:      make(map[string]*typedGlobal, 0),
+		HeaderCache:  make(map[string]*cachedHeader, 0),
+		Benchmarks:   make(map[string]*benchmarkStats, 0),
+		Dependencies: make(map[string]string, 0),
+		BusReactions: make(map[string]string, 0),
 	}
 }
 
 // update project data on disk
 func (d *data) update() {
-
-	// make it pretty
-	b, err := json.MarshalIndent(d, "", "    ")
+	err := d.save()
 	if err != nil {
-		Log.WithError(err).Fatal("failed to marshal zeus data")
+		Log.WithError(err).Fatal("failed to write zeus data")
 	}
+}
 
-	f, err := os.OpenFile(projectDataPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0700)
-	if err != nil {
-		Log.WithError(err).Fatal("failed to open zeus data")
-	}
+// write the project data to disk and return the error
+// used by the shutdown sequence, where a fatal log message would run the shutdown sequence again
+func (d *data) save() error {
+
+	// the globals might have changed
+	invalidateSecrets()
 
-	disableWriteEvent = true
+	if readOnly {
+		Log.Debug("read-only mode, not saving project data")
+		return nil
+	}
 
-	_, err = f.Write(b)
+	// make it pretty
+	b, err := json.MarshalIndent(d, "", "    ")
 	if err != nil {
-		Log.WithError(err).Fatal("failed to write zeus data")
+		return err
 	}
+
+	return writeFileAtomic(projectDataPath, b, 0700)
 }
 
 // parse the project data JSON
@@ -99,13 +125,14 @@ func parseProjectData() (*data, error) {
 		return nil, err
 	}
 
-	contents, err := ioutil.ReadFile(projectDataPath)
-	if err != nil {
-		return nil, err
-	}
-
-	err = json.Unmarshal(contents, d)
+	err = readWithFallback(projectDataPath, func(contents []byte) error {
+		d = new(data)
+		return json.Unmarshal(contents, d)
+	})
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
 		Log.WithError(err).Fatal("failed to unmarshal zeus data - invalid JSON")
 	}
 
@@ -115,6 +142,8 @@ func parseProjectData() (*data, error) {
 // load user events from projectData and create the watchers
 func loadEvents() {
 
+	var trusted bool
+
 	for _, e := range projectData.Events {
 
 		// skip loading of internal watchers
@@ -122,6 +151,14 @@ func loadEvents() {
 			continue
 		}
 
+		// dont run anything automatically before the command set was approved
+		if !trusted {
+			if !trustCommandSet() {
+				return
+			}
+			trusted = true
+		}
+
 		Log.Warn("EVENT: ", e)
 
 		// validate commandChain
@@ -145,7 +182,7 @@ func loadEvents() {
 					if event.Name == path {
 
 						Log.Info("event name matches: ", event, " COMMANDCHAIN: ", chain)
-						executeCommandChain(chain)
+						queueEvent(chain)
 					}
 
 				}, chain)
@@ -0,0 +1,280 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mgutz/ansi"
+)
+
+var (
+	// ErrInvalidDepends means the zeus-depends header field is invalid
+	ErrInvalidDepends = errors.New("invalid zeus-depends field, expected: <project>:<command>")
+
+	// ErrDependencyCycle means projects depend on each other
+	ErrDependencyCycle = errors.New("cycle in the dependencies between projects")
+)
+
+// environment variable with the project commands that are currently resolving their dependencies
+// it is inherited by the zeus processes of the other projects, to detect cycles
+const dependencyChainVar = "ZEUS_DEPENDENCY_CHAIN"
+
+// projectDependency is a command of another zeus project
+type projectDependency struct {
+
+	// path relative to the project root, or name of a workspace project
+	project string
+
+	command string
+}
+
+func (d *projectDependency) String() string {
+	return d.project + packageSeparator + d.command
+}
+
+// parse the dependencies from the zeus-depends header field
+// the project and the command are separated by the last colon, windows paths can contain a colon as well
+func parseDepends(field string) (deps []*projectDependency, err error) {
+
+	for _, s := range strings.Fields(field) {
+
+		i := strings.LastIndex(s, packageSeparator)
+		if i <= 0 || i == len(s)-1 {
+			return nil, ErrInvalidDepends
+		}
+
+		deps = append(deps, &projectDependency{
+			project: s[:i],
+			command: s[i+1:],
+		})
+	}
+
+	return deps, nil
+}
+
+// resolve the absolute directory of the project
+// paths are relative to the project root, other names are looked up in the workspace
+func (d *projectDependency) dir() (string, error) {
+
+	var dir string
+
+	if strings.ContainsAny(d.project, `/\`) || strings.HasPrefix(d.project, ".") || filepath.IsAbs(d.project) {
+		dir = d.project
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workingDir, dir)
+		}
+	} else {
+		w, err := parseWorkspace()
+		if err != nil {
+			return "", err
+		}
+		path, ok := w.Projects[d.project]
+		if !ok {
+			return "", errors.New(ErrUnknownProject.Error() + ": " + d.project)
+		}
+		dir = path
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if stat, err := os.Stat(filepath.Join(dir, zeusDir)); err != nil || !stat.IsDir() {
+		return "", errors.New(ErrNotAZeusProject.Error() + ": " + dir)
+	}
+
+	return dir, nil
+}
+
+// execute the commands of other projects the command depends on
+// a dependency is skipped if its project did not change since the command last succeeded
+func (c *command) runDependencies() error {
+
+	if c.depends == "" {
+		return nil
+	}
+
+	deps, err := parseDepends(c.depends)
+	if err != nil {
+		return err
+	}
+
+	var (
+		self  = filepath.Clean(workingDir) + packageSeparator + c.name
+		chain = filepath.SplitList(os.Getenv(dependencyChainVar))
+	)
+
+	executable, err := os.Executable()
+	if err != nil {
+		executable = os.Args[0]
+	}
+
+	for _, d := range deps {
+
+		dir, err := d.dir()
+		if err != nil {
+			return err
+		}
+
+		key := dir + packageSeparator + d.command
+		for _, k := range append(chain, self) {
+			if k == key {
+				return errors.New(ErrDependencyCycle.Error() + ": " + strings.Join(append(chain, self, key), " -> "))
+			}
+		}
+
+		fingerprint, err := projectFingerprint(dir)
+		if err != nil {
+			Log.WithError(err).Debug("failed to fingerprint " + dir)
+		} else if projectData.Dependencies[key] == fingerprint {
+			l.Println(printPrompt() + "dependency " + cp.colorPrompt + d.String() + cp.colorText + " is up to date" + ansi.Reset)
+			continue
+		}
+
+		l.Println(printPrompt() + "executing dependency " + cp.colorPrompt + d.String() + ansi.Reset)
+
+		cmd := exec.Command(executable, d.command)
+		cmd.Dir = dir
+		cmd.Stdout = commandStdout
+		cmd.Stderr = commandStderr
+		cmd.Env = append(runEnviron(), dependencyChainVar+"="+strings.Join(append(chain, self), string(os.PathListSeparator)))
+		setProcessGroup(cmd)
+
+		err = cmd.Start()
+		if err != nil {
+			return err
+		}
+
+		processLock.Lock()
+		processMap[d.String()] = cmd.Process
+		processLock.Unlock()
+
+		err = cmd.Wait()
+
+		processLock.Lock()
+		delete(processMap, d.String())
+		processLock.Unlock()
+
+		if err != nil {
+			return errors.New(d.String() + " failed: " + err.Error())
+		}
+
+		// remember the state of the project after the command succeeded
+		fingerprint, err = projectFingerprint(dir)
+		if err != nil {
+			Log.WithError(err).Debug("failed to fingerprint " + dir)
+			continue
+		}
+		if projectData.Dependencies == nil {
+			projectData.Dependencies = make(map[string]string, 0)
+		}
+		projectData.Dependencies[key] = fingerprint
+		projectData.update()
+	}
+
+	return nil
+}
+
+// check if a file is written by zeus itself, those files change on every run
+func isZeusStateFile(path string) bool {
+	path = filepath.ToSlash(path)
+	return strings.HasPrefix(path, zeusDir+"/") && !strings.HasSuffix(path, f.fileExtension)
+}
+
+// hash the state of the project at dir
+// for git repositories the revision and the changed files are used, otherwise all files of the project
+func projectFingerprint(dir string) (string, error) {
+
+	var (
+		h     = sha256.New()
+		files []string
+	)
+
+	if rev := gitRevision(dir); rev != "" {
+
+		h.Write([]byte(rev + "\n"))
+
+		// changes to tracked files since the revision, and untracked files
+		// the paths are relative to dir, which can be a subdirectory of the repository
+		for _, args := range [][]string{
+			{"diff", "HEAD", "--name-only", "--relative"},
+			{"ls-files", "--others", "--exclude-standard"},
+		} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = dir
+
+			out, err := cmd.Output()
+			if err != nil {
+				return "", err
+			}
+
+			scanner := bufio.NewScanner(bytes.NewReader(out))
+			for scanner.Scan() {
+				if line := scanner.Text(); line != "" {
+					files = append(files, line)
+				}
+			}
+		}
+	} else {
+		err := walkFollow(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if path != dir && strings.HasPrefix(info.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// the contents of the files are represented by their size and modification time
+	for _, path := range files {
+		if isZeusStateFile(path) {
+			continue
+		}
+		h.Write([]byte(path + "\n"))
+		if info, err := os.Stat(filepath.Join(dir, path)); err == nil {
+			h.Write([]byte(strconv.FormatInt(info.Size(), 10) + " " + strconv.FormatInt(info.ModTime().UnixNano(), 10) + "\n"))
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
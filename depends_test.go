@@ -0,0 +1,54 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDepends(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		field string
+		want  []*projectDependency
+		err   error
+	}{
+		{"empty", "", nil, nil},
+		{"workspace project", "api:build", []*projectDependency{{project: "api", command: "build"}}, nil},
+		{"relative path", "../lib:test", []*projectDependency{{project: "../lib", command: "test"}}, nil},
+		{"colon in path", "C:/src/lib:build", []*projectDependency{{project: "C:/src/lib", command: "build"}}, nil},
+		{"several", "api:build  web:test", []*projectDependency{{project: "api", command: "build"}, {project: "web", command: "test"}}, nil},
+		{"missing command", "api:", nil, ErrInvalidDepends},
+		{"missing project", ":build", nil, ErrInvalidDepends},
+		{"no separator", "api", nil, ErrInvalidDepends},
+	}
+
+	for _, test := range tests {
+		got, err := parseDepends(test.field)
+		if err != test.err {
+			t.Errorf("%s: parseDepends(%q) error = %v, want %v", test.name, test.field, err, test.err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: parseDepends(%q) = %v, want %v", test.name, test.field, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/mgutz/ansi"
+)
+
+// diff styles for the ShowDiffs config field
+const (
+	diffUnified    = "unified"
+	diffSideBySide = "side-by-side"
+)
+
+const (
+	// unchanged lines printed around the changes
+	diffContext = 3
+
+	// limit for the size of the table used to compute the diff
+	// larger files are shown as completely replaced
+	maxDiffCells = 4 << 20
+)
+
+// kind of a line in a diff
+const (
+	diffEqual = iota
+	diffRemoved
+	diffAdded
+)
+
+// diffLine is a single line of a line based diff
+type diffLine struct {
+	op   int
+	text string
+
+	// number of lines before this line in the old and the new file
+	oldPos int
+	newPos int
+}
+
+// split content into lines, a trailing newline does not start another line
+func splitLines(b []byte) []string {
+	s := strings.TrimSuffix(string(b), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// compute the line based diff from a to b
+// using the longest common subsequence of the lines between the common prefix and suffix
+func lineDiff(a, b []string) []diffLine {
+
+	var (
+		lines          []diffLine
+		oldPos, newPos int
+		add            = func(op int, text string) {
+			lines = append(lines, diffLine{op: op, text: text, oldPos: oldPos, newPos: newPos})
+			if op != diffAdded {
+				oldPos++
+			}
+			if op != diffRemoved {
+				newPos++
+			}
+		}
+	)
+
+	// common prefix and suffix
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, line := range a[:prefix] {
+		add(diffEqual, line)
+	}
+
+	var (
+		x = a[prefix : len(a)-suffix]
+		y = b[prefix : len(b)-suffix]
+	)
+
+	if (len(x)+1)*(len(y)+1) > maxDiffCells {
+		for _, line := range x {
+			add(diffRemoved, line)
+		}
+		for _, line := range y {
+			add(diffAdded, line)
+		}
+	} else {
+
+		// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+		lcs := make([][]int, len(x)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(y)+1)
+		}
+		for i := len(x) - 1; i >= 0; i-- {
+			for j := len(y) - 1; j >= 0; j-- {
+				if x[i] == y[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(x) || j < len(y) {
+			switch {
+			case i < len(x) && j < len(y) && x[i] == y[j]:
+				add(diffEqual, x[i])
+				i++
+				j++
+			case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+				add(diffRemoved, x[i])
+				i++
+			default:
+				add(diffAdded, y[j])
+				j++
+			}
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		add(diffEqual, line)
+	}
+
+	return lines
+}
+
+// group the changes with their context into hunks
+func diffHunks(lines []diffLine) [][]diffLine {
+
+	var (
+		hunks      [][]diffLine
+		start, end = -1, -1
+	)
+
+	for i, line := range lines {
+		if line.op == diffEqual {
+			continue
+		}
+
+		from := i - diffContext
+		if from < 0 {
+			from = 0
+		}
+
+		// close the current hunk if the context does not overlap
+		if start >= 0 && from > end {
+			hunks = append(hunks, lines[start:end])
+			start = -1
+		}
+		if start < 0 {
+			start = from
+		}
+
+		end = i + 1 + diffContext
+		if end > len(lines) {
+			end = len(lines)
+		}
+	}
+
+	if start >= 0 {
+		hunks = append(hunks, lines[start:end])
+	}
+
+	return hunks
+}
+
+// print the changes of the file at path if ShowDiffs is enabled
+func printDiff(path string, old, new []byte) {
+
+	if conf.ShowDiffs == "" || bytes.Equal(old, new) {
+		return
+	}
+
+	hunks := diffHunks(lineDiff(splitLines(old), splitLines(new)))
+	if len(hunks) == 0 {
+		// only the trailing newline changed
+		return
+	}
+
+	if conf.ShowDiffs == diffSideBySide {
+		printSideBySideDiff(path, path, hunks)
+		return
+	}
+	printUnifiedDiff(path, path, hunks)
+}
+
+// print hunks in the unified diff format
+func printUnifiedDiff(from, to string, hunks [][]diffLine) {
+
+	l.Println(cp.colorText + "--- " + from + ansi.Reset)
+	l.Println(cp.colorText + "+++ " + to + ansi.Reset)
+
+	for _, hunk := range hunks {
+
+		var oldCount, newCount int
+		for _, line := range hunk {
+			if line.op != diffAdded {
+				oldCount++
+			}
+			if line.op != diffRemoved {
+				newCount++
+			}
+		}
+
+		l.Println(cp.colorPrompt + "@@ -" + hunkRange(hunk[0].oldPos, oldCount) + " +" + hunkRange(hunk[0].newPos, newCount) + " @@" + ansi.Reset)
+
+		for _, line := range hunk {
+			switch line.op {
+			case diffEqual:
+				l.Println(cp.colorCommandOutput + " " + line.text + ansi.Reset)
+			case diffRemoved:
+				l.Println(cp.colorDiffRemoved + "-" + line.text + ansi.Reset)
+			case diffAdded:
+				l.Println(cp.colorDiffAdded + "+" + line.text + ansi.Reset)
+			}
+		}
+	}
+}
+
+// format the range of a hunk, line numbers start at 1
+// empty ranges refer to the line before them
+func hunkRange(pos, count int) string {
+	if count == 0 {
+		return strconv.Itoa(pos) + ",0"
+	}
+	return strconv.Itoa(pos+1) + "," + strconv.Itoa(count)
+}
+
+// print hunks in two columns, the old file on the left and the new file on the right
+func printSideBySideDiff(from, to string, hunks [][]diffLine) {
+
+	width, _, err := readline.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 40 {
+		width = 160
+	}
+
+	// line numbers take 5 characters, the gutter 3
+	col := (width-3)/2 - 5
+
+	cell := func(num int, text string) string {
+		text = strings.Replace(text, "\t", "    ", -1)
+		if r := []rune(text); len(r) > col {
+			text = string(r[:col])
+		}
+		return pad(strconv.Itoa(num), 5) + pad(text, col)
+	}
+
+	l.Println(cp.colorText + pad(from, col+5) + " | " + to + ansi.Reset)
+
+	for _, hunk := range hunks {
+
+		l.Println(cp.colorPrompt + strings.Repeat("-", width) + ansi.Reset)
+
+		for i := 0; i < len(hunk); {
+
+			if hunk[i].op == diffEqual {
+				l.Println(cp.colorCommandOutput + cell(hunk[i].oldPos+1, hunk[i].text) + "   " + cell(hunk[i].newPos+1, hunk[i].text) + ansi.Reset)
+				i++
+				continue
+			}
+
+			// pair the removed lines of a change with its added lines
+			var removed, added []diffLine
+			for ; i < len(hunk) && hunk[i].op == diffRemoved; i++ {
+				removed = append(removed, hunk[i])
+			}
+			for ; i < len(hunk) && hunk[i].op == diffAdded; i++ {
+				added = append(added, hunk[i])
+			}
+
+			for j := 0; j < len(removed) || j < len(added); j++ {
+
+				var left, right, gutter = strings.Repeat(" ", col+5), "", " | "
+				if j < len(removed) {
+					left = cp.colorDiffRemoved + cell(removed[j].oldPos+1, removed[j].text)
+				} else {
+					gutter = " > "
+				}
+				if j < len(added) {
+					right = cp.colorDiffAdded + cell(added[j].newPos+1, added[j].text)
+				} else {
+					gutter = " < "
+				}
+
+				l.Println(left + cp.colorText + gutter + right + ansi.Reset)
+			}
+		}
+	}
+}
@@ -0,0 +1,203 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ErrInvalidArgsField means the zeus-args field of a discovered script is invalid
+var ErrInvalidArgsField = errors.New("invalid zeus-args field")
+
+// check if the file in a script directory can be executed as a command
+// on windows scripts are executed with bash, so shell scripts qualify as well
+func isDiscoverableScript(info os.FileInfo) bool {
+	if runtime.GOOS == "windows" && info.Mode().IsRegular() && strings.EqualFold(filepath.Ext(info.Name()), f.fileExtension) {
+		return true
+	}
+	return isExecutableFile(info) && info.Name()[0] != '.'
+}
+
+// collect the executable scripts of the ScriptDirs, in the order of the directories
+func discoveredScripts() (paths []string) {
+
+	for _, dir := range strings.Fields(conf.ScriptDirs) {
+
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				Log.WithError(err).Warn("skipping " + dir)
+			}
+			continue
+		}
+
+		for _, info := range files {
+			if isDiscoverableScript(info) {
+				paths = append(paths, filepath.ToSlash(filepath.Join(dir, info.Name())))
+			}
+		}
+	}
+
+	return paths
+}
+
+// add the executable scripts of the ScriptDirs as commands
+// the commands are named after the file without extension,
+// commands of the zeus directory take precedence, and earlier directories over later ones
+func discoverCommands() {
+
+	var cLog = Log.WithField("prefix", "discoverCommands")
+
+	for _, path := range discoveredScripts() {
+
+		var (
+			base = filepath.Base(path)
+			name = strings.TrimSuffix(base, filepath.Ext(base))
+		)
+
+		commandMutex.Lock()
+		existing, ok := commands[name]
+		commandMutex.Unlock()
+
+		if ok {
+			if !existing.discovered {
+				cLog.Debug("discovered script " + path + " is shadowed by the command " + name)
+			}
+			continue
+		}
+
+		cmd, err := newDiscoveredCommand(name, path)
+		if err != nil {
+			cLog.WithError(err).Error("failed to add discovered script " + path)
+			continue
+		}
+
+		commandMutex.Lock()
+		commands[name] = cmd
+		commandMutex.Unlock()
+		commandIndex.add(name)
+
+		cLog.Debug("added discovered script " + path + " as " + name)
+	}
+}
+
+// create a command for a discovered script
+// the leading comment block is parsed: the zeus-help and zeus-args fields are used if present,
+// otherwise the first comment line is the help text, the whole block is the manual
+func newDiscoveredCommand(name, path string) (*command, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		c = &command{
+			name:          name,
+			path:          path,
+			discovered:    true,
+			chainResolved: true,
+		}
+		scanner = bufio.NewScanner(file)
+		first   = true
+	)
+
+	for scanner.Scan() {
+
+		line := strings.TrimSpace(scanner.Text())
+
+		if first && strings.HasPrefix(line, "#!") {
+			first = false
+			continue
+		}
+		first = false
+
+		// blank lines are allowed before the comment block
+		if line == "" {
+			if c.manual == "" {
+				continue
+			}
+			break
+		}
+
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+
+		text := strings.TrimSpace(strings.TrimLeft(line, "#"))
+		switch {
+		case strings.HasPrefix(text, "---"):
+			continue
+
+		case strings.HasPrefix(text, "@"+p.zeusFieldHelp+":"):
+			c.help = strings.TrimSpace(trimZeusPrefix(line))
+
+		case strings.HasPrefix(text, "@"+p.zeusFieldArgs+":"):
+			c.args, err = parseDiscoveredArgs(trimZeusPrefix(line))
+			if err != nil {
+				return nil, err
+			}
+
+		case strings.HasPrefix(text, "@zeus-"):
+			// other header fields are reserved for scripts in the zeus directory
+
+		default:
+			if c.help == "" && text != "" {
+				c.help = text
+			}
+			c.manual += line + "\n"
+		}
+	}
+
+	return c, scanner.Err()
+}
+
+// parse the typed arguments of a zeus-args field, in the form: name:Type
+func parseDiscoveredArgs(field string) (args []*commandArg, err error) {
+
+	for _, s := range strings.Fields(field) {
+
+		slice := strings.Split(s, ":")
+		if len(slice) != 2 {
+			return nil, ErrInvalidArgsField
+		}
+
+		for _, a := range args {
+			if a.name == slice[0] {
+				return nil, ErrDuplicateArgumentNames
+			}
+		}
+
+		a, ok := newCommandArg(slice[0], slice[1])
+		if !ok {
+			return nil, ErrInvalidArgsField
+		}
+
+		args = append(args, a)
+	}
+
+	return args, nil
+}
@@ -0,0 +1,352 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/mattn/go-isatty"
+	"github.com/mgutz/ansi"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// ErrDoctorFailed means the doctor found problems that must be fixed
+var ErrDoctorFailed = errors.New("doctor found problems")
+
+// severity of a diagnosis
+const (
+	doctorOK = iota
+	doctorWarn
+	doctorFail
+)
+
+// diagnosis is the result of a single doctor check
+type diagnosis struct {
+	status  int
+	area    string
+	message string
+
+	// what the user can do about it
+	fix string
+}
+
+// handle doctor shell command
+// runs all checks and prints the results with fixes
+func handleDoctorCommand() error {
+
+	var (
+		results  = runDoctor()
+		failures int
+		warnings int
+	)
+
+	for _, d := range results {
+		switch d.status {
+		case doctorOK:
+			l.Println(ansi.Green + pad("ok", 6) + cp.colorText + pad(d.area, 10) + d.message + ansi.Reset)
+		case doctorWarn:
+			warnings++
+			l.Println(ansi.Yellow + pad("warn", 6) + cp.colorText + pad(d.area, 10) + d.message + ansi.Reset)
+		case doctorFail:
+			failures++
+			l.Println(ansi.Red + pad("fail", 6) + cp.colorText + pad(d.area, 10) + d.message + ansi.Reset)
+		}
+		if d.fix != "" {
+			l.Println(cp.colorText + pad("", 16) + "fix: " + d.fix + ansi.Reset)
+		}
+	}
+
+	l.Println(cp.colorText + strconv.Itoa(failures) + " problems, " + strconv.Itoa(warnings) + " warnings." + ansi.Reset)
+
+	if failures > 0 {
+		return ErrDoctorFailed
+	}
+	return nil
+}
+
+// run all doctor checks
+func runDoctor() (results []*diagnosis) {
+	results = append(results, checkConfig()...)
+	results = append(results, checkTools()...)
+	results = append(results, checkWatcherLimits()...)
+	results = append(results, checkTerminal()...)
+	results = append(results, checkLock()...)
+	results = append(results, checkCaches()...)
+	return
+}
+
+// check the project config for invalid JSON, unknown fields and invalid values
+func checkConfig() (results []*diagnosis) {
+
+	add := func(status int, message, fix string) {
+		results = append(results, &diagnosis{status: status, area: "config", message: message, fix: fix})
+	}
+
+	contents, err := ioutil.ReadFile(projectConfigPath)
+	if err != nil {
+		add(doctorWarn, "no project config: "+err.Error(), "run 'zeus config' to create one")
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(contents))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(new(config)); err != nil {
+			add(doctorFail, projectConfigPath+": "+err.Error(), "correct or remove the field in "+projectConfigPath)
+		}
+	}
+
+	switch conf.ColorProfile {
+	case "dark", "light", "default":
+	default:
+		add(doctorFail, "unknown ColorProfile: "+conf.ColorProfile, "zeus config set ColorProfile default")
+	}
+
+	if conf.CoverageThreshold < 0 || conf.CoverageThreshold > 100 {
+		add(doctorFail, "CoverageThreshold must be between 0 and 100", "zeus config set CoverageThreshold 80")
+	}
+
+	for _, pattern := range strings.Fields(conf.SecretPatterns) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			add(doctorFail, "invalid SecretPatterns entry "+pattern+": "+err.Error(), "correct the pattern in "+projectConfigPath)
+		}
+	}
+
+	for _, format := range strings.Fields(conf.HelpExport) {
+		if format != exportMarkdown && format != exportMan && format != exportBash {
+			add(doctorFail, "unknown HelpExport format: "+format, "use markdown, man or bash")
+		}
+	}
+
+	switch conf.ShowDiffs {
+	case "", diffUnified, diffSideBySide:
+	default:
+		add(doctorFail, "unknown ShowDiffs style: "+conf.ShowDiffs, "use unified or side-by-side")
+	}
+
+	if _, err := newSessionLimits(); err != nil {
+		add(doctorFail, "invalid session limits: "+err.Error(), "use a duration like 8h for IdleShutdown and a time like 23:00 for ShutdownAt")
+	}
+
+	if (conf.EventBus != "" || conf.EventBusPeers != "") && os.Getenv(busSecretVar) == "" {
+		add(doctorWarn, "the event bus is enabled without "+busSecretVar+", events are not signed and reactions are disabled", "export "+busSecretVar+" with a secret shared by the team")
+	}
+
+	for _, dir := range strings.Fields(conf.ScriptDirs) {
+		if _, err := os.Stat(dir); err != nil {
+			add(doctorWarn, "ScriptDirs entry "+dir+" does not exist", "create it or remove it from ScriptDirs")
+		}
+	}
+
+	switch conf.SignatureVerification {
+	case "", "gpg", "minisign":
+	default:
+		add(doctorFail, "unknown SignatureVerification: "+conf.SignatureVerification, "use gpg, minisign or leave it empty")
+	}
+
+	if len(results) == 0 {
+		add(doctorOK, "config is valid", "")
+	}
+
+	return
+}
+
+// check that the tools required by the project and config are installed
+func checkTools() (results []*diagnosis) {
+
+	add := func(status int, message, fix string) {
+		results = append(results, &diagnosis{status: status, area: "tools", message: message, fix: fix})
+	}
+
+	require := func(tool, reason, fix string) {
+		if path, err := exec.LookPath(tool); err != nil {
+			add(doctorFail, filepath.Base(tool)+" not found, required "+reason, fix)
+		} else {
+			add(doctorOK, filepath.Base(tool)+": "+path, "")
+		}
+	}
+
+	if _, err := shellCommand(); err != nil {
+		add(doctorFail, err.Error(), "install bash")
+	} else {
+		require(p.interpreter, "to execute the scripts", "install bash")
+	}
+
+	if path, err := exec.LookPath("git"); err != nil {
+		add(doctorWarn, "git not found, project info and audit entries will lack the commit", "install git")
+	} else {
+		add(doctorOK, "git: "+path, "")
+	}
+
+	if conf.Sandbox {
+		if _, err := sandboxCommand(&sandboxMounts{root: workingDir}); err != nil {
+			add(doctorFail, "Sandbox is enabled: "+err.Error(), "install bubblewrap or nsjail, or disable Sandbox")
+		} else {
+			add(doctorOK, "sandbox backend available", "")
+		}
+	}
+
+	if conf.SignatureVerification != "" {
+		require(conf.SignatureVerification, "by SignatureVerification", "install "+conf.SignatureVerification+" or disable SignatureVerification")
+	}
+
+	// the config script is only compiled, not executed
+	if _, err := os.Stat(configScriptPath); err == nil {
+		L := lua.NewState()
+		if _, err := L.LoadFile(configScriptPath); err != nil {
+			add(doctorFail, err.Error(), "fix the syntax error in "+configScriptPath)
+		} else {
+			add(doctorOK, configScriptPath+" compiles", "")
+		}
+		L.Close()
+	}
+
+	return
+}
+
+// check if the terminal supports the interactive shell and colors
+func checkTerminal() (results []*diagnosis) {
+
+	add := func(status int, message, fix string) {
+		results = append(results, &diagnosis{status: status, area: "terminal", message: message, fix: fix})
+	}
+
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		add(doctorWarn, "stdin is not a terminal, the interactive shell and prompts are not available", "")
+	}
+
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		if conf.Colors {
+			add(doctorWarn, "stdout is not a terminal, colors will end up in the output", "zeus config set Colors false")
+		}
+		return
+	}
+
+	switch term := os.Getenv("TERM"); term {
+	case "":
+		if runtime.GOOS != "windows" {
+			add(doctorWarn, "TERM is not set", "export TERM=xterm-256color")
+		}
+	case "dumb":
+		add(doctorWarn, "TERM is dumb, the shell and the dashboard will not render correctly", "use a terminal emulator with ANSI support")
+	}
+
+	width, height, err := readline.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		add(doctorWarn, "failed to get the terminal size: "+err.Error(), "")
+	} else if width < 80 || height < 20 {
+		add(doctorWarn, "terminal is "+strconv.Itoa(width)+"x"+strconv.Itoa(height)+", the dashboard needs at least 80x20", "resize the terminal")
+	}
+
+	if len(results) == 0 {
+		add(doctorOK, "terminal supports the interactive shell", "")
+	}
+
+	return
+}
+
+// check the project lock and the daemon socket
+func checkLock() (results []*diagnosis) {
+
+	add := func(status int, message, fix string) {
+		results = append(results, &diagnosis{status: status, area: "lock", message: message, fix: fix})
+	}
+
+	switch {
+	case projectLock != nil:
+		add(doctorOK, "project is not locked by another instance", "")
+	case inspectMode:
+		add(doctorOK, "lock not checked in inspection mode", "")
+	case readOnly:
+		add(doctorWarn, "project is locked by another instance (pid "+lockHolder()+"), running read-only", "exit the other instance, the lock is released when it exits")
+	default:
+		add(doctorFail, "failed to lock the project", "check the permissions of "+lockfilePath)
+	}
+
+	if _, err := os.Stat(daemonSocketPath); err == nil {
+		if conn, err := net.Dial("unix", daemonSocketPath); err != nil {
+			add(doctorWarn, "stale daemon socket "+daemonSocketPath, "rm "+daemonSocketPath)
+		} else {
+			conn.Close()
+			add(doctorOK, "daemon is running", "")
+		}
+	}
+
+	return
+}
+
+// check the project data and the caches inside of it
+func checkCaches() (results []*diagnosis) {
+
+	add := func(status int, message, fix string) {
+		results = append(results, &diagnosis{status: status, area: "cache", message: message, fix: fix})
+	}
+
+	for _, path := range []string{projectDataPath, pinsFilePath} {
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(contents, &v); err != nil {
+			if _, prevErr := os.Stat(path + ".prev"); prevErr == nil {
+				add(doctorFail, path+" is corrupt: "+err.Error(), "cp "+path+".prev "+path)
+			} else {
+				add(doctorFail, path+" is corrupt: "+err.Error(), "fix the JSON or remove "+path)
+			}
+		}
+	}
+
+	headerCacheMutex.Lock()
+	var stale, outdated int
+	for path, h := range projectData.HeaderCache {
+		hash, err := hashFile(path)
+		if err != nil {
+			stale++
+		} else if hash != h.Hash {
+			outdated++
+		}
+	}
+	entries := len(projectData.HeaderCache)
+	headerCacheMutex.Unlock()
+
+	switch {
+	case !conf.HeaderCache:
+		add(doctorOK, "header cache is disabled", "")
+	case stale > 0:
+		add(doctorWarn, strconv.Itoa(stale)+" header cache entries for missing scripts", "restart zeus, the entries are removed on startup")
+	case outdated > 0:
+		add(doctorOK, strconv.Itoa(outdated)+" of "+strconv.Itoa(entries)+" cached headers will be parsed again", "")
+	default:
+		add(doctorOK, "header cache is up to date ("+strconv.Itoa(entries)+" scripts)", "")
+	}
+
+	return
+}
@@ -0,0 +1,90 @@
+//go:build linux
+// +build linux
+
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// kernel limits for inotify
+const (
+	maxUserWatchesPath   = "/proc/sys/fs/inotify/max_user_watches"
+	maxUserInstancesPath = "/proc/sys/fs/inotify/max_user_instances"
+)
+
+// read an integer from a file in /proc
+func readProcInt(path string) (int, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// check if the inotify limits are high enough to watch the project
+// every watched directory needs a watch, the watch builtin watches all directories of the project
+func checkWatcherLimits() (results []*diagnosis) {
+
+	add := func(status int, message, fix string) {
+		results = append(results, &diagnosis{status: status, area: "watcher", message: message, fix: fix})
+	}
+
+	maxWatches, err := readProcInt(maxUserWatchesPath)
+	if err != nil {
+		add(doctorWarn, "failed to read the inotify limits: "+err.Error(), "")
+		return
+	}
+
+	var dirs int
+	walkFollow(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != "." && ignoredByWatch(path) {
+				return filepath.SkipDir
+			}
+			dirs++
+		}
+		return nil
+	})
+
+	// other programs need watches as well
+	switch {
+	case dirs >= maxWatches:
+		add(doctorFail, "the project has "+strconv.Itoa(dirs)+" directories, but only "+strconv.Itoa(maxWatches)+" inotify watches are allowed", "sudo sysctl fs.inotify.max_user_watches=524288")
+	case dirs > maxWatches/2:
+		add(doctorWarn, "the project has "+strconv.Itoa(dirs)+" directories, "+strconv.Itoa(maxWatches)+" inotify watches are allowed for all programs", "sudo sysctl fs.inotify.max_user_watches=524288")
+	default:
+		add(doctorOK, strconv.Itoa(dirs)+" directories, "+strconv.Itoa(maxWatches)+" inotify watches allowed", "")
+	}
+
+	if maxInstances, err := readProcInt(maxUserInstancesPath); err == nil && maxInstances < 128 {
+		add(doctorWarn, "only "+strconv.Itoa(maxInstances)+" inotify instances are allowed, every zeus instance needs several", "sudo sysctl fs.inotify.max_user_instances=512")
+	}
+
+	return
+}
@@ -0,0 +1,90 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+var (
+	// byte order mark some windows editors put at the beginning of UTF-8 files
+	utf8BOM = "\ufeff"
+
+	// script issues that make bash fail to execute the script
+	issueBOM  = "UTF-8 byte order mark before the shebang"
+	issueCRLF = "CRLF line ending"
+)
+
+// split function for bufio.Scanner
+// works like bufio.ScanLines but keeps carriage returns, so CRLF line endings can be detected
+func scanRawLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+// normalize a line of a script header
+// strips the byte order mark from the first line and the carriage return of CRLF line endings,
+// unicode whitespace like non-breaking spaces is replaced with a regular space
+// returns the normalized line and a description of the issue, if the line would break the script execution
+func normalizeLine(line string, first bool) (string, string) {
+
+	var issue string
+
+	if first && strings.HasPrefix(line, utf8BOM) {
+		line = strings.TrimPrefix(line, utf8BOM)
+		issue = issueBOM
+	}
+
+	if strings.HasSuffix(line, "\r") {
+		line = strings.TrimSuffix(line, "\r")
+		if issue == "" {
+			issue = issueCRLF
+		}
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r != ' ' && r != '\t' && unicode.IsSpace(r) {
+			return ' '
+		}
+		return r
+	}, line), issue
+}
+
+// normalize the contents of a script
+// removes the byte order mark, converts CRLF line endings and strips a trailing carriage return
+// that is not followed by a newline
+func normalizeScript(contents []byte) []byte {
+	contents = bytes.TrimPrefix(contents, []byte(utf8BOM))
+	contents = bytes.Replace(contents, []byte("\r\n"), []byte("\n"), -1)
+	return bytes.TrimSuffix(contents, []byte("\r"))
+}
@@ -0,0 +1,109 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// ErrInvalidEnvName means a name in the env header field is not a valid variable name or prefix
+var ErrInvalidEnvName = errors.New("invalid environment variable name, expected NAME or PREFIX*")
+
+var (
+	// variable names, a trailing * matches all variables with the prefix
+	envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\*?$`)
+
+	// variables windows needs to run any program
+	windowsEnv = []string{"SYSTEMROOT", "WINDIR", "COMSPEC", "PATHEXT", "TEMP", "TMP", "USERPROFILE"}
+)
+
+// parse the names of the env header field
+func parseEnvNames(s string) ([]string, error) {
+
+	names := strings.Fields(s)
+	for _, name := range names {
+		if !envNamePattern.MatchString(name) {
+			return nil, ErrInvalidEnvName
+		}
+	}
+
+	return names, nil
+}
+
+// get the environment the command starts with
+// by default the full environment is inherited,
+// with MinimalEnv only the allowlisted and the declared variables are passed
+func (c *command) environment() ([]string, error) {
+
+	if !conf.MinimalEnv {
+		return runEnviron(), nil
+	}
+
+	declared, err := parseEnvNames(c.env)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := append(strings.Fields(conf.EnvAllowlist), declared...)
+	if runtime.GOOS == "windows" {
+		allowed = append(allowed, windowsEnv...)
+	}
+
+	return filterEnv(runEnviron(), allowed), nil
+}
+
+// keep the variables of env whose names are allowed
+func filterEnv(env []string, allowed []string) (filtered []string) {
+
+	for _, kv := range env {
+
+		i := strings.Index(kv, "=")
+		if i < 1 {
+			continue
+		}
+
+		for _, name := range allowed {
+			if envNameMatches(name, kv[:i]) {
+				filtered = append(filtered, kv)
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// check if the variable name matches an allowlist entry
+// names are case insensitive on windows
+func envNameMatches(pattern, name string) bool {
+
+	if runtime.GOOS == "windows" {
+		pattern = strings.ToUpper(pattern)
+		name = strings.ToUpper(name)
+	}
+
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(name, strings.TrimSuffix(pattern, "*"))
+	}
+
+	return pattern == name
+}
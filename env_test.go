@@ -0,0 +1,63 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestFilterEnv(t *testing.T) {
+
+	env := []string{"HOME=/root", "PATH=/bin", "LC_ALL=C", "LC_CTYPE=UTF-8", "SECRET=x", "EMPTY=", "=C:=C:\\", "BROKEN"}
+
+	tests := []struct {
+		name    string
+		allowed []string
+		want    []string
+	}{
+		{"nothing allowed", nil, nil},
+		{"exact names", []string{"PATH", "HOME"}, []string{"HOME=/root", "PATH=/bin"}},
+		{"wildcard", []string{"LC_*"}, []string{"LC_ALL=C", "LC_CTYPE=UTF-8"}},
+		{"empty value", []string{"EMPTY"}, []string{"EMPTY="}},
+		{"prefix is no match", []string{"PAT"}, nil},
+		{"unknown name", []string{"GOPATH"}, nil},
+		{"all", []string{"*"}, []string{"HOME=/root", "PATH=/bin", "LC_ALL=C", "LC_CTYPE=UTF-8", "SECRET=x", "EMPTY="}},
+	}
+
+	for _, test := range tests {
+		if got := filterEnv(env, test.allowed); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: filterEnv(%q) = %q, want %q", test.name, test.allowed, got, test.want)
+		}
+	}
+}
+
+func TestEnvNameMatchesCase(t *testing.T) {
+
+	// variable names are case insensitive on windows only
+	want := runtime.GOOS == "windows"
+
+	if got := envNameMatches("Path", "PATH"); got != want {
+		t.Errorf("envNameMatches(%q, %q) = %v, want %v", "Path", "PATH", got, want)
+	}
+	if got := envNameMatches("lc_*", "LC_ALL"); got != want {
+		t.Errorf("envNameMatches(%q, %q) = %v, want %v", "lc_*", "LC_ALL", got, want)
+	}
+}
@@ -21,16 +21,12 @@ package main
 import (
 	"errors"
 	"os"
-	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/fsnotify/fsnotify"
 )
 
 var (
-	// ignore the the write event when updating the config using the shell command
-	disableWriteEvent = false
-
 	// ErrInvalidEventType means the given event type string is invalid
 	ErrInvalidEventType = errors.New("invalid fsnotify event type. available types are: WRITE | REMOVE | RENAME | CHMOD")
 
@@ -46,6 +42,9 @@ type Event struct {
 	Chain    string
 	handler  func(fsnotify.Event)
 	stopChan chan bool
+
+	// the watched path is a directory
+	isDir bool
 }
 
 func printEventsUsageErr() {
@@ -90,7 +89,7 @@ func handleEventsCommand(args []string) {
 			return
 		}
 
-		chain := strings.Join(args[4:], " ")
+		chain := shellJoin(args[4:])
 
 		go func() {
 			err := addEvent(args[3], op, func(event fsnotify.Event) {
@@ -101,7 +100,7 @@ func handleEventsCommand(args []string) {
 				if event.Name == args[3] {
 
 					Log.Info("event name matches: ", event, " COMMANDCHAIN: ", chain)
-					executeCommand(chain)
+					queueEvent(chain)
 				}
 
 			}, chain)
@@ -166,8 +165,9 @@ func removeEvent(path string) {
 	Log.Error("event with name ", path, " does not exist")
 }
 
-// addEvent adds a watcher for path and register a handler that will fire if operation op occurs
+// addEvent adds a watch for path and register a handler that will fire if operation op occurs
 // the chain parameter contains the associated buildChain for user defined events
+// blocks until the event is removed
 func addEvent(path string, op fsnotify.Op, handler func(fsnotify.Event), chain string) error {
 
 	var (
@@ -191,63 +191,19 @@ func addEvent(path string, op fsnotify.Op, handler func(fsnotify.Event), chain s
 	projectData.update()
 	eventLock.Unlock()
 
-	// init new watcher
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return err
-	}
-	defer watcher.Close()
-
-	// listen for events
-	done := make(chan bool)
-	go func() {
-
-		for {
-			select {
-			case event := <-watcher.Events:
-
-				Log.WithFields(logrus.Fields{
-					"event": event,
-					"path":  path,
-				}).Debug("incoming event")
-
-				// check operation type
-				if event.Op == op {
-
-					// check if write event was disabled.
-					// example: when updating the config with the config command
-					// revalidating the config is not necessary
-					if disableWriteEvent {
-						disableWriteEvent = false
-						continue
-					}
-
-					// fire handler
-					handler(event)
-				}
-			case err := <-watcher.Errors:
-				cLog.WithError(err).Fatal("watcher failed")
-			case _ = <-e.stopChan:
-				watcher.Close()
-				done <- true
-				return
-			}
-		}
-	}()
-
-	// add path to watcher
-	err = watcher.Add(path)
+	// register at the shared watcher
+	err := watches.add(e)
 	if err != nil {
 		cLog.WithFields(logrus.Fields{
 			"error": err,
 			"path":  path,
 		}).Error("failed to add path to watcher")
-		e.stopChan <- true
 		return err
 	}
 
-	// wait for it
-	<-done
+	// wait until the event is removed
+	<-e.stopChan
+	watches.remove(e)
 
 	return nil
 }
@@ -0,0 +1,112 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// a command could not be started: unknown command, invalid arguments or undefined variables
+	exitUsageError = 64
+
+	// zeus itself failed
+	exitInternalError = 70
+
+	// exit codes of commands killed by a signal are 128 + signal number, like in bash
+	exitSignalBase = 128
+
+	// continue with the remaining commands of a chain after a failure
+	keepGoingFlag      = "--keep-going"
+	keepGoingFlagShort = "-k"
+)
+
+// run the remaining commands of a chain when one fails
+var keepGoing bool
+
+// check for the keep going flag in front of the command and remove it from the arguments
+func handleKeepGoingFlag() {
+	for len(os.Args) > 1 && (os.Args[1] == keepGoingFlag || os.Args[1] == keepGoingFlagShort) {
+		keepGoing = true
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+}
+
+// get the exit code from an error returned by a command
+func exitCode(err error) int {
+
+	if err == nil {
+		return 0
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			if status.Signaled() {
+				return exitSignalBase + int(status.Signal())
+			}
+			return status.ExitStatus()
+		}
+		return 1
+	}
+
+	if isUsageError(err) {
+		return exitUsageError
+	}
+
+	return exitInternalError
+}
+
+// check if err means the command could not be started because of the way it was called
+func isUsageError(err error) bool {
+	switch err {
+	case ErrUnknownCommand, ErrInvalidUsage, ErrTooManyArguments, ErrNotEnoughArguments,
+		ErrInvalidArgumentType, ErrUndefinedVariable, ErrUnterminatedQuote:
+		return true
+	}
+	return false
+}
+
+// number of logged error messages
+// builtins executed from the command line fail when they logged an error
+var loggedErrors int64
+
+// errorCountHook counts the logged error messages
+type errorCountHook struct{}
+
+func (errorCountHook) Levels() []logrus.Level {
+	return []logrus.Level{
+		logrus.ErrorLevel,
+	}
+}
+
+func (errorCountHook) Fire(entry *logrus.Entry) error {
+	atomic.AddInt64(&loggedErrors, 1)
+	return nil
+}
+
+// exit handler for fatal log messages
+func fatalExit() {
+	cleanup()
+	os.Exit(exitInternalError)
+}
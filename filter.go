@@ -0,0 +1,253 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidFilter means the zeus-filter header field could not be parsed
+var ErrInvalidFilter = errors.New("invalid output filter, expected: include=<regex> exclude=<regex> transform=<script>")
+
+// outputFilter tames the streamed output of a command
+// the test results and the run log always get the unfiltered output
+type outputFilter struct {
+
+	// lines must match one of these if there are any
+	include []*regexp.Regexp
+
+	// lines matching one of these are dropped
+	exclude []*regexp.Regexp
+
+	// script that reads the remaining lines on stdin and writes the output to display on stdout
+	transform string
+}
+
+// parse filters in the form: include=error|warn exclude=^DEBUG transform=zeus/filters/trim.sh
+// include and exclude can be used multiple times, regexes must not contain whitespace, use \s instead
+func parseFilter(s string) (*outputFilter, error) {
+
+	var f = new(outputFilter)
+
+	for _, field := range strings.Fields(s) {
+
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, ErrInvalidFilter
+		}
+
+		switch kv[0] {
+		case "include", "exclude":
+			r, err := regexp.Compile(kv[1])
+			if err != nil {
+				return nil, errors.New("invalid output filter regex: " + err.Error())
+			}
+			if kv[0] == "include" {
+				f.include = append(f.include, r)
+			} else {
+				f.exclude = append(f.exclude, r)
+			}
+		case "transform":
+			if f.transform != "" {
+				return nil, ErrInvalidFilter
+			}
+			f.transform = kv[1]
+		default:
+			return nil, ErrInvalidFilter
+		}
+	}
+
+	return f, nil
+}
+
+// get the output filter of the command, nil if there is none
+func (c *command) outputFilter() (*outputFilter, error) {
+	if c.filter == "" {
+		return nil, nil
+	}
+	return parseFilter(c.filter)
+}
+
+// check if a line without the newline should be displayed
+func (f *outputFilter) keep(line []byte) bool {
+
+	for _, r := range f.exclude {
+		if r.Match(line) {
+			return false
+		}
+	}
+
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, r := range f.include {
+		if r.Match(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterWriter applies an outputFilter line by line
+type filterWriter struct {
+	f *outputFilter
+
+	// destination for the kept lines, the stdin of the transform script if there is one
+	w io.Writer
+
+	// incomplete line
+	buf []byte
+
+	transform *exec.Cmd
+	stdin     io.WriteCloser
+
+	mutex sync.Mutex
+}
+
+// create a writer that filters the output before writing it to w
+// the transform script is started immediately and runs until the writer is closed
+func (f *outputFilter) writer(w io.Writer) (*filterWriter, error) {
+
+	fw := &filterWriter{
+		f: f,
+		w: w,
+	}
+
+	if f.transform != "" {
+
+		if _, err := os.Stat(f.transform); err != nil {
+			return nil, err
+		}
+
+		cmd, err := shellCommand(f.transform)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdout = w
+		cmd.Stderr = commandStderr
+
+		fw.stdin, err = cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+
+		err = cmd.Start()
+		if err != nil {
+			return nil, err
+		}
+
+		fw.transform = cmd
+		fw.w = fw.stdin
+	}
+
+	return fw, nil
+}
+
+// implement io.Writer
+func (fw *filterWriter) Write(b []byte) (int, error) {
+
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	var (
+		n     = len(b)
+		limit = outputBufferSize()
+	)
+
+	for len(b) > 0 {
+
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+
+			// incomplete line, keep it until the rest arrives
+			fw.buf = append(fw.buf, b...)
+			if len(fw.buf) >= limit {
+				err := fw.writeLine(fw.buf)
+				fw.buf = fw.buf[:0]
+				if err != nil {
+					return 0, err
+				}
+			}
+			break
+		}
+
+		line := b[:i]
+		if len(fw.buf) > 0 {
+			line = append(fw.buf, line...)
+		}
+
+		err := fw.writeLine(line)
+		fw.buf = fw.buf[:0]
+		if err != nil {
+			return 0, err
+		}
+
+		b = b[i+1:]
+	}
+
+	return n, nil
+}
+
+// write a single line if the filter keeps it
+func (fw *filterWriter) writeLine(line []byte) error {
+	if !fw.f.keep(line) {
+		return nil
+	}
+	_, err := fw.w.Write(append(append(make([]byte, 0, len(line)+1), line...), '\n'))
+	return err
+}
+
+// write the incomplete line and wait for the transform script to process the remaining output
+func (fw *filterWriter) Close() error {
+
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	var err error
+	if len(fw.buf) > 0 {
+		err = fw.writeLine(fw.buf)
+		fw.buf = fw.buf[:0]
+	}
+
+	if fw.transform != nil {
+		fw.stdin.Close()
+		if e := fw.transform.Wait(); e != nil && err == nil {
+			err = errors.New("output transform " + fw.f.transform + " failed: " + e.Error())
+		}
+	}
+
+	return err
+}
+
+// close the filters of a run
+func closeFilters(filters []*filterWriter) {
+	for _, fw := range filters {
+		err := fw.Close()
+		if err != nil {
+			Log.WithError(err).Error("failed to filter the output")
+		}
+	}
+}
@@ -24,7 +24,6 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 
@@ -63,11 +62,18 @@ type formatter struct {
 	parseMode   syntax.ParseMode
 	printConfig syntax.PrintConfig
 
-	// regexes
-	validShebang *regexp.Regexp
-	shellFile    *regexp.Regexp
+	// file extensions of shell scripts
+	shellExtensions map[string]bool
+
+	// interpreter names accepted in the shebang
+	shellInterpreters map[string]bool
 }
 
+var (
+	shebangPrefix  = []byte("#!")
+	shebangPadding = " \t\v\f\r"
+)
+
 // initialize the formatter to handle shell scripts
 func newFormatter() *formatter {
 	return &formatter{
@@ -80,8 +86,14 @@ func newFormatter() *formatter {
 		openMode:  os.O_RDWR,
 		parseMode: syntax.ParseComments,
 
-		validShebang: regexp.MustCompile(`^#!\s?/(usr/)?bin/(env *)?(sh|bash)`),
-		shellFile:    regexp.MustCompile(`\.(sh|bash)$`),
+		shellExtensions: map[string]bool{
+			".sh":   true,
+			".bash": true,
+		},
+		shellInterpreters: map[string]bool{
+			"sh":   true,
+			"bash": true,
+		},
 	}
 }
 
@@ -93,7 +105,7 @@ func isValidScript(info os.FileInfo) shellConfidence {
 	switch {
 	case info.IsDir(), name[0] == '.', !info.Mode().IsRegular():
 		return notShellFile
-	case f.shellFile.MatchString(name):
+	case f.shellExtensions[filepath.Ext(name)]:
 		return isShellFile
 	case strings.Contains(name, "."):
 		return notShellFile // different extension
@@ -104,6 +116,55 @@ func isValidScript(info os.FileInfo) shellConfidence {
 	}
 }
 
+// check if src starts with a shebang for one of the shell interpreters
+// only the first line is inspected, so files of any length are safe
+// whitespace after #! and between the fields is tolerated, as well as CRLF line endings
+// env style shebangs like #!/usr/bin/env -S bash are resolved to the interpreter passed to env
+// the bytes are scanned directly, because this runs for every file of a directory walk
+func (f *formatter) hasShellShebang(src []byte) bool {
+
+	if !bytes.HasPrefix(src, shebangPrefix) {
+		return false
+	}
+
+	line := src[len(shebangPrefix):]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+
+	interpreter, rest := nextField(line)
+	if len(interpreter) == 0 || interpreter[0] != '/' {
+		return false
+	}
+
+	name := interpreter[bytes.LastIndexByte(interpreter, '/')+1:]
+	if string(name) == "env" {
+
+		// skip env flags and variable assignments
+		for {
+			name, rest = nextField(rest)
+			if len(name) == 0 || (name[0] != '-' && bytes.IndexByte(name, '=') < 0) {
+				break
+			}
+		}
+	}
+
+	return f.shellInterpreters[string(name)]
+}
+
+// split the first whitespace separated field from b
+func nextField(b []byte) (field, rest []byte) {
+
+	b = bytes.TrimLeft(b, shebangPadding)
+
+	i := bytes.IndexAny(b, shebangPadding)
+	if i < 0 {
+		return b, nil
+	}
+
+	return b[:i], b[i:]
+}
+
 // format a single shell file on disk
 func (f *formatter) formatPath(path string) error {
 
@@ -127,7 +188,7 @@ func (f *formatter) formatPath(path string) error {
 
 	// check bang
 	src := f.readBuf.Bytes()
-	if !f.validShebang.Match(src[:32]) {
+	if !f.hasShellShebang(src) {
 		return nil
 	}
 
@@ -156,6 +217,8 @@ func (f *formatter) formatPath(path string) error {
 		if _, err := file.Write(res); err != nil {
 			return err
 		}
+
+		printDiff(path, src, res)
 	}
 	return nil
 }
@@ -174,22 +237,37 @@ func (f *formatter) formatzeusDir() error {
 		return ErrNoDirectory
 	}
 
-	return filepath.Walk(zeusDir, func(path string, info os.FileInfo, err error) error {
+	// real paths of the formatted scripts
+	// scripts reachable through symlinks are formatted only once
+	var formatted = map[string]bool{}
 
-		// no recursion for now
-		if info.IsDir() {
-			return nil
-		}
+	return walkFollow(zeusDir, func(path string, info os.FileInfo, err error) error {
 
 		if err != nil {
 			cLog.WithError(err).Error("error walking zeus directory")
 			return err
 		}
 
+		if info.IsDir() {
+			if inNoCommandDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		conf := isValidScript(info)
 		if conf == notShellFile {
-			return ErrNotAShellScript
+			return nil
+		}
+
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return err
 		}
+		if formatted[real] {
+			return nil
+		}
+		formatted[real] = true
 
 		err = f.formatPath(path)
 		if err != nil && !os.IsNotExist(err) {
@@ -217,6 +295,11 @@ func empty(f *os.File) error {
 // calculates runtime and displays error
 func (f *formatter) formatCommand() {
 
+	if readOnly {
+		Log.Error(ErrReadOnly)
+		return
+	}
+
 	var (
 		start = time.Now()
 		err   = f.formatzeusDir()
@@ -233,7 +316,10 @@ func (f *formatter) watchzeusDir() {
 	err := addEvent(zeusDir, fsnotify.Write, func(event fsnotify.Event) {
 
 		// check if its a valid script
-		if strings.HasSuffix(event.Name, f.fileExtension) {
+		if strings.HasSuffix(event.Name, f.fileExtension) && !inNoCommandDir(event.Name) {
+
+			// the parsed header is outdated now
+			invalidateHeaderCache(event.Name)
 
 			// format script
 			err := f.formatPath(event.Name)
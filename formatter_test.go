@@ -0,0 +1,101 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestHasShellShebang(t *testing.T) {
+
+	var f = newFormatter()
+
+	tests := []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{"empty", "", false},
+		{"shorter than the prefix", "#", false},
+		{"prefix only", "#!", false},
+		{"no newline", "#!/bin/sh", true},
+		{"bash", "#!/bin/bash\necho hi\n", true},
+		{"crlf", "#!/bin/bash\r\necho hi\r\n", true},
+		{"space after prefix", "#! /bin/sh\n", true},
+		{"tab after interpreter", "#!/bin/bash\t-e\n", true},
+		{"env bash", "#!/usr/bin/env bash\n", true},
+		{"env bash crlf", "#!/usr/bin/env bash\r\n", true},
+		{"env -S", "#!/usr/bin/env -S bash -e\n", true},
+		{"env -S with assignment", "#!/usr/bin/env -S LC_ALL=C bash\n", true},
+		{"env without interpreter", "#!/usr/bin/env\n", false},
+		{"env flags only", "#!/usr/bin/env -S\n", false},
+		{"python", "#!/usr/bin/python3\n", false},
+		{"env python", "#!/usr/bin/env python3\n", false},
+		{"env -S node", "#!/usr/bin/env -S node --harmony\n", false},
+		{"zsh", "#!/bin/zsh\n", false},
+		{"interpreter with suffix", "#!/bin/bashx\n", false},
+		{"relative interpreter", "#!bash\n", false},
+		{"shebang on second line", "\n#!/bin/bash\n", false},
+		{"no shebang", "echo hi\n", false},
+	}
+
+	for _, test := range tests {
+		if got := f.hasShellShebang([]byte(test.src)); got != test.want {
+			t.Errorf("%s: hasShellShebang(%q) = %v, want %v", test.name, test.src, got, test.want)
+		}
+	}
+}
+
+func BenchmarkHasShellShebang(b *testing.B) {
+
+	var (
+		f      = newFormatter()
+		script = append([]byte("#!/usr/bin/env bash\n"), bytes.Repeat([]byte("echo hello world\n"), 256)...)
+		inputs = []struct {
+			name string
+			src  []byte
+		}{
+			{"bash", script},
+			{"env -S", []byte("#!/usr/bin/env -S LC_ALL=C bash -e\necho hi\n")},
+			{"binary", append([]byte("\x7fELF"), make([]byte, 1<<20)...)},
+		}
+
+		// the regular expression used before the byte scanning, for comparison
+		validShebang = regexp.MustCompile(`^#!\s?/(usr/)?bin/(env *)?(sh|bash)`)
+	)
+
+	for _, in := range inputs {
+		b.Run(in.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				f.hasShellShebang(in.src)
+			}
+		})
+		b.Run(in.name+"/regexp", func(b *testing.B) {
+			src := in.src
+			if len(src) > 32 {
+				src = src[:32]
+			}
+			for i := 0; i < b.N; i++ {
+				validShebang.Match(src)
+			}
+		})
+	}
+}
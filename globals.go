@@ -0,0 +1,276 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrInvalidGlobalName means the name of a global can not be used as a shell variable
+	ErrInvalidGlobalName = errors.New("invalid global name")
+
+	// ErrGlobalValidationFailed means the value did not match the validation pattern of the global
+	ErrGlobalValidationFailed = errors.New("global value does not match validation pattern")
+
+	// ErrUnknownGlobal means the global does not exist
+	ErrUnknownGlobal = errors.New("unknown global")
+
+	// valid shell variable name
+	globalName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	// path for the globals script
+	globalsScriptPath = "zeus/globals.sh"
+)
+
+// typedGlobal is a project global with a type and an optional validation pattern
+type typedGlobal struct {
+	Type    string
+	Value   string
+	Pattern string
+}
+
+func printGlobalsUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: globals [set <name>[:<type>] <value>] [validate <name> <regex>] [remove <name>]")
+}
+
+// handle globals shell command
+func handleGlobalsCommand(args []string) {
+
+	if len(args) < 2 {
+		listGlobals()
+		return
+	}
+
+	if len(args) < 3 {
+		printGlobalsUsageErr()
+		return
+	}
+
+	switch args[1] {
+	case "set":
+		if len(args) < 4 {
+			printGlobalsUsageErr()
+			return
+		}
+		err := setGlobal(args[2], strings.Join(args[3:], " "))
+		if err != nil {
+			Log.WithError(err).Error("failed to set global: ", args[2])
+		}
+	case "validate":
+		if len(args) < 4 {
+			printGlobalsUsageErr()
+			return
+		}
+		err := setGlobalPattern(args[2], strings.Join(args[3:], " "))
+		if err != nil {
+			Log.WithError(err).Error("failed to set validation pattern for global: ", args[2])
+		}
+	case "remove":
+		removeGlobal(args[2])
+	default:
+		printGlobalsUsageErr()
+	}
+}
+
+// set a typed global, the argument can contain a type: name:Type
+// if no type is given the type of the existing global will be used, or String for new ones
+func setGlobal(arg, value string) error {
+
+	var (
+		slice = strings.Split(arg, ":")
+		name  = slice[0]
+	)
+
+	if !globalName.MatchString(name) {
+		return ErrInvalidGlobalName
+	}
+
+	if projectData.Globals == nil {
+		projectData.Globals = make(map[string]*typedGlobal, 0)
+	}
+
+	g, ok := projectData.Globals[name]
+	if !ok {
+		g = &typedGlobal{
+			Type: argTypeString,
+		}
+	}
+
+	if len(slice) > 1 {
+		if _, ok := getArgKind(slice[1]); !ok {
+			return ErrInvalidArgumentType
+		}
+		g.Type = slice[1]
+	}
+
+	err := g.validate(value)
+	if err != nil {
+		return err
+	}
+
+	g.Value = value
+	projectData.Globals[name] = g
+	projectData.update()
+
+	Log.Info("set global ", name, " to ", value)
+	return nil
+}
+
+// set the validation pattern for an existing global
+func setGlobalPattern(name, pattern string) error {
+
+	g, ok := projectData.Globals[name]
+	if !ok {
+		return ErrUnknownGlobal
+	}
+
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	if !r.MatchString(g.Value) {
+		return ErrGlobalValidationFailed
+	}
+
+	g.Pattern = pattern
+	projectData.update()
+
+	Log.Info("set validation pattern for global ", name)
+	return nil
+}
+
+// remove a typed global from the project data
+func removeGlobal(name string) {
+
+	if _, ok := projectData.Globals[name]; !ok {
+		Log.Error(ErrUnknownGlobal, ": ", name)
+		return
+	}
+
+	delete(projectData.Globals, name)
+	projectData.update()
+
+	Log.Info("removed global ", name)
+}
+
+// check if the value matches the type and the validation pattern of the global
+func (g *typedGlobal) validate(value string) error {
+
+	k, ok := getArgKind(g.Type)
+	if !ok || !validArgType(value, k) {
+		return ErrInvalidArgumentType
+	}
+
+	if g.Pattern != "" {
+		r, err := regexp.Compile(g.Pattern)
+		if err != nil {
+			return err
+		}
+		if !r.MatchString(value) {
+			return ErrGlobalValidationFailed
+		}
+	}
+
+	return nil
+}
+
+// assemble the environment variables for all typed globals
+func globalsEnv() (env []string) {
+	for name, value := range generatedGlobals {
+		if _, ok := projectData.Globals[name]; !ok {
+			env = append(env, conf.GlobalsPrefix+name+"="+value)
+		}
+	}
+	for name, g := range projectData.Globals {
+		env = append(env, conf.GlobalsPrefix+name+"="+g.Value)
+	}
+	return
+}
+
+// print the typed globals and the contents of globals.sh on stdout
+func listGlobals() {
+
+	if len(projectData.Globals) > 0 {
+
+		var (
+			names  = make([]string, 0, len(projectData.Globals))
+			maxLen int
+		)
+		for name := range projectData.Globals {
+			names = append(names, name)
+			if len(name) > maxLen {
+				maxLen = len(name)
+			}
+		}
+		sort.Strings(names)
+
+		l.Println(cp.colorText + "globals:")
+		for _, name := range names {
+			g := projectData.Globals[name]
+			if g.Pattern != "" {
+				l.Println(cp.colorCommandName+pad(conf.GlobalsPrefix+name, len(conf.GlobalsPrefix)+maxLen+1)+cp.colorText, "=", g.Value, "("+g.Type+", "+g.Pattern+")")
+			} else {
+				l.Println(cp.colorCommandName+pad(conf.GlobalsPrefix+name, len(conf.GlobalsPrefix)+maxLen+1)+cp.colorText, "=", g.Value, "("+g.Type+")")
+			}
+		}
+		l.Println("")
+	}
+
+	if len(generatedGlobals) > 0 {
+
+		var names = make([]string, 0, len(generatedGlobals))
+		for name := range generatedGlobals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		l.Println(cp.colorText + "generated by " + configScriptPath + ":")
+		for _, name := range names {
+			l.Println(cp.colorCommandName+conf.GlobalsPrefix+name+cp.colorText, "=", generatedGlobals[name])
+		}
+		l.Println("")
+	}
+
+	if len(globalsContent) > 0 {
+		c, err := ioutil.ReadFile(globalsScriptPath)
+		if err != nil {
+			l.Fatal("failed to read globals: ", err)
+		}
+		l.Println(string(c))
+	} else if len(projectData.Globals) == 0 && len(generatedGlobals) == 0 {
+		l.Println("no globals defined.")
+	}
+}
+
+// validate all typed globals from the project data
+func validateGlobals() {
+	for name, g := range projectData.Globals {
+		err := g.validate(g.Value)
+		if err != nil {
+			Log.WithError(err).Fatal("failed to validate global: ", name)
+		}
+	}
+}
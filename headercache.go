@@ -0,0 +1,250 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// format version of the cached headers
+// increment it whenever the fields of cachedHeader change,
+// entries written by other versions of zeus are parsed again
+const headerCacheVersion = 1
+
+var (
+	// guards the header cache in the project data
+	headerCacheMutex = &sync.Mutex{}
+
+	// indicates that the header cache has been modified and needs to be written to disk
+	headerCacheDirty bool
+)
+
+// cachedHeader contains the parsed header of a script
+// it is stored in the project data, keyed by the script path
+type cachedHeader struct {
+
+	// format of the entry, see headerCacheVersion
+	Version int
+
+	// hash of the script contents
+	Hash string
+
+	Help           string
+	Manual         string
+	Args           []*cachedArg
+	ParsedCommands [][]string
+	BuildNumber    bool
+	Dependency     string
+	Limits         string
+	Sandbox        string
+	Env            string
+	Results        string
+	Coverage       string
+	Depends        string
+	Filter         string
+	TTY            string
+	Requires       string
+}
+
+// cachedArg is a serializable command argument
+type cachedArg struct {
+	Name string
+	Type string
+}
+
+// hash the file at path
+func hashFile(path string) (string, error) {
+
+	c, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha1.Sum(c)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// parse the script at path, or use the cached header if the script did not change
+func (p *parser) parseScriptCached(path string, job *parseJob) (*commandData, error) {
+
+	if !conf.HeaderCache {
+		return p.parseScript(path, job)
+	}
+
+	hash, err := hashFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	headerCacheMutex.Lock()
+	h, ok := projectData.HeaderCache[path]
+	headerCacheMutex.Unlock()
+
+	if ok && h.Version == headerCacheVersion && h.Hash == hash {
+		if d, ok := h.commandData(); ok {
+			return d, nil
+		}
+	}
+
+	d, err := p.parseScript(path, job)
+	if err != nil {
+		return nil, err
+	}
+
+	// the sanitizer might have modified the file while parsing
+	hash, err = hashFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	headerCacheMutex.Lock()
+	if projectData.HeaderCache == nil {
+		projectData.HeaderCache = make(map[string]*cachedHeader, 0)
+	}
+	projectData.HeaderCache[path] = newCachedHeader(hash, d)
+	headerCacheDirty = true
+	headerCacheMutex.Unlock()
+
+	return d, nil
+}
+
+// create a cache entry for the parsed commandData
+func newCachedHeader(hash string, d *commandData) *cachedHeader {
+
+	var h = &cachedHeader{
+		Version:        headerCacheVersion,
+		Hash:           hash,
+		Help:           d.help,
+		Manual:         d.manual,
+		ParsedCommands: d.parsedCommands,
+		BuildNumber:    d.buildNumber,
+		Dependency:     d.dependency,
+		Limits:         d.limits,
+		Sandbox:        d.sandbox,
+		Env:            d.env,
+		Results:        d.results,
+		Coverage:       d.coverage,
+		Depends:        d.depends,
+		Filter:         d.filter,
+		TTY:            d.tty,
+		Requires:       d.requires,
+	}
+
+	for _, a := range d.args {
+		h.Args = append(h.Args, &cachedArg{
+			Name: a.name,
+			Type: argTypeName(a),
+		})
+	}
+
+	return h
+}
+
+// restore the commandData from the cache entry
+// returns false if the entry is invalid
+func (h *cachedHeader) commandData() (*commandData, bool) {
+
+	var d = &commandData{
+		help:           h.Help,
+		manual:         h.Manual,
+		parsedCommands: h.ParsedCommands,
+		buildNumber:    h.BuildNumber,
+		dependency:     h.Dependency,
+		limits:         h.Limits,
+		sandbox:        h.Sandbox,
+		env:            h.Env,
+		results:        h.Results,
+		coverage:       h.Coverage,
+		depends:        h.Depends,
+		filter:         h.Filter,
+		tty:            h.TTY,
+		requires:       h.Requires,
+	}
+
+	for _, a := range h.Args {
+		arg, ok := newCommandArg(a.Name, a.Type)
+		if !ok {
+			return nil, false
+		}
+		d.args = append(d.args, arg)
+	}
+
+	return d, true
+}
+
+// get the type of an argument in the notation of the zeus-args header field
+func argTypeName(a *commandArg) string {
+	if a.path != "" {
+		return a.typeName()
+	}
+	return kindToArgType(a.argType)
+}
+
+// get the argument type name for a reflect.Kind
+func kindToArgType(k reflect.Kind) string {
+	switch k {
+	case reflect.Bool:
+		return argTypeBool
+	case reflect.Float64:
+		return argTypeFloat
+	case reflect.Int:
+		return argTypeInt
+	default:
+		return argTypeString
+	}
+}
+
+// remove the cache entry for the script at path
+// called by the zeus directory watcher when a script changes
+func invalidateHeaderCache(path string) {
+
+	headerCacheMutex.Lock()
+	defer headerCacheMutex.Unlock()
+
+	if _, ok := projectData.HeaderCache[path]; ok {
+		delete(projectData.HeaderCache, path)
+		headerCacheDirty = true
+	}
+}
+
+// remove entries for scripts that do not exist anymore
+// and write the cache to disk if it was modified
+// the entries of generated and discovered scripts are kept as well as the ones of the zeus directory
+func updateHeaderCache() {
+
+	headerCacheMutex.Lock()
+	defer headerCacheMutex.Unlock()
+
+	for path := range projectData.HeaderCache {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(projectData.HeaderCache, path)
+			headerCacheDirty = true
+		}
+	}
+
+	if headerCacheDirty {
+		projectData.update()
+		headerCacheDirty = false
+	}
+}
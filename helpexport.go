@@ -0,0 +1,361 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownExportFormat means the help export format is not supported
+var ErrUnknownExportFormat = errors.New("unknown export format, available formats are: markdown | man | bash")
+
+const (
+	exportFlag     = "--export"
+	exportMarkdown = "markdown"
+	exportMan      = "man"
+	exportBash     = "bash"
+
+	// output files of the help export
+	commandsMarkdownPath = "COMMANDS.md"
+	manPageDir           = "man"
+	completionScriptPath = "zeus-completion.bash"
+)
+
+// export the command overview in the given format
+// the file is only written when its content changed
+// returns the path of the written file
+func exportHelp(format string) (string, error) {
+
+	var (
+		project = filepath.Base(workingDir)
+		path    string
+		content []byte
+	)
+
+	switch format {
+	case exportMarkdown:
+		path = commandsMarkdownPath
+		content = renderMarkdownHelp(project)
+	case exportMan:
+		path = filepath.Join(manPageDir, project+".1")
+		content = renderManPage(project)
+	case exportBash:
+		path = completionScriptPath
+		content = renderBashCompletion(project)
+	default:
+		return "", ErrUnknownExportFormat
+	}
+
+	if readOnly {
+		return "", ErrReadOnly
+	}
+
+	if existing, err := ioutil.ReadFile(path); err == nil && bytes.Equal(existing, content) {
+		return path, nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return "", err
+	}
+
+	return path, writeFileAtomic(path, content, 0644)
+}
+
+// regenerate the configured help export after the commands were loaded
+func updateHelpExport() {
+
+	if conf.HelpExport == "" || readOnly {
+		return
+	}
+
+	for _, format := range strings.Fields(conf.HelpExport) {
+		if _, err := exportHelp(format); err != nil {
+			Log.WithError(err).Error("failed to export the help as " + format)
+		}
+	}
+}
+
+// commands sorted by name
+func sortedCommands() []*command {
+
+	commandMutex.Lock()
+	defer commandMutex.Unlock()
+
+	var cmds = make([]*command, 0, len(commands))
+	for _, c := range commands {
+		cmds = append(cmds, c)
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].name < cmds[j].name
+	})
+
+	return cmds
+}
+
+// strip the comment characters from the manual of a command
+func manualText(c *command) string {
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(c.manual), "\n") {
+		line = strings.TrimPrefix(strings.TrimLeft(line, "#"), " ")
+		if strings.HasPrefix(line, "---") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// usage line of a command with its arguments
+func usageText(c *command) string {
+
+	var usage = "zeus " + c.name
+	for _, a := range c.args {
+		usage += " <" + a.name + ":" + a.typeName() + ">"
+	}
+
+	return usage
+}
+
+// render the commands as markdown
+func renderMarkdownHelp(project string) []byte {
+
+	var (
+		b    bytes.Buffer
+		cmds = sortedCommands()
+	)
+
+	b.WriteString("# " + project + " commands\n\n")
+	b.WriteString("<!-- generated by zeus help --export markdown, do not edit -->\n\n")
+
+	b.WriteString("Command | Arguments | Description\n")
+	b.WriteString("------- | --------- | -----------\n")
+	for _, c := range cmds {
+		b.WriteString("[" + c.name + "](#" + markdownAnchor(c.name) + ") | " + strings.TrimSpace(getArgumentString(c.args)) + " | " + c.help + "\n")
+	}
+
+	for _, c := range cmds {
+
+		b.WriteString("\n## " + c.name + "\n\n")
+
+		if c.help != "" {
+			b.WriteString(c.help + "\n\n")
+		}
+
+		b.WriteString("```shell\n" + usageText(c) + "\n```\n\n")
+
+		for _, a := range c.args {
+			b.WriteString("- argument *" + a.name + "*: " + a.typeName() + "\n")
+		}
+		if len(c.parsedCommands) > 0 {
+			b.WriteString("- runs before: " + strings.Trim(formatcommandChain(c.parsedCommands), "()") + "\n")
+		}
+		if c.depends != "" {
+			b.WriteString("- depends on: " + c.depends + "\n")
+		}
+		if c.dependency != "" {
+			b.WriteString("- skipped when *" + c.dependency + "* exists\n")
+		}
+		if len(c.args) > 0 || len(c.parsedCommands) > 0 || c.depends != "" || c.dependency != "" {
+			b.WriteString("\n")
+		}
+
+		if manual := manualText(c); manual != "" {
+			b.WriteString("```text\n" + manual + "\n```\n\n")
+		}
+
+		b.WriteString("Source: [" + c.path + "](" + c.path + ")\n")
+	}
+
+	return b.Bytes()
+}
+
+// anchor of a markdown heading, as generated by github
+func markdownAnchor(heading string) string {
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// escape text for roff
+func roffEscape(s string) string {
+
+	s = strings.Replace(s, `\`, `\e`, -1)
+	s = strings.Replace(s, "-", `\-`, -1)
+
+	var lines = strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// render the commands as a man page
+func renderManPage(project string) []byte {
+
+	var b bytes.Buffer
+
+	b.WriteString(`.\" generated by zeus help --export man, do not edit` + "\n")
+	b.WriteString(".TH " + roffEscape(strings.ToUpper(project)) + " 1 \"\" \"zeus\" \"" + roffEscape(project) + " commands\"\n")
+	b.WriteString(".SH NAME\n")
+	b.WriteString(roffEscape(project) + " \\- commands of the " + roffEscape(project) + " project\n")
+	b.WriteString(".SH SYNOPSIS\n")
+	b.WriteString(".B zeus\n.I command\n[\\fIarguments\\fR]\n")
+	b.WriteString(".SH COMMANDS\n")
+
+	for _, c := range sortedCommands() {
+
+		b.WriteString(".TP\n.B " + roffEscape(c.name))
+		for _, a := range c.args {
+			b.WriteString(" \\fI" + roffEscape(a.name) + "\\fR:" + a.typeName())
+		}
+		b.WriteString("\n")
+
+		if c.help != "" {
+			b.WriteString(roffEscape(c.help) + "\n")
+		}
+
+		if len(c.parsedCommands) > 0 || c.depends != "" || c.dependency != "" {
+			b.WriteString(".RS\n")
+			if len(c.parsedCommands) > 0 {
+				b.WriteString(".PP\nRuns before: " + roffEscape(strings.Trim(formatcommandChain(c.parsedCommands), "()")) + "\n")
+			}
+			if c.depends != "" {
+				b.WriteString(".PP\nDepends on: " + roffEscape(c.depends) + "\n")
+			}
+			if c.dependency != "" {
+				b.WriteString(".PP\nSkipped when " + roffEscape(c.dependency) + " exists.\n")
+			}
+			b.WriteString(".RE\n")
+		}
+
+		if manual := manualText(c); manual != "" {
+			b.WriteString(".RS\n.PP\n.nf\n" + roffEscape(manual) + "\n.fi\n.RE\n")
+		}
+	}
+
+	return b.Bytes()
+}
+
+func printHelpExportUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: help --export <markdown|man|bash>")
+}
+
+// render a bash completion script for the commands
+// zsh can use it after loading bashcompinit
+func renderBashCompletion(project string) []byte {
+
+	var (
+		b     bytes.Buffer
+		names []string
+		cases bytes.Buffer
+		cmds  = sortedCommands()
+	)
+
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for _, c := range cmds {
+		names = append(names, c.name)
+
+		dir := "."
+		if c.pkg != nil {
+			dir = c.pkg.dir
+		}
+
+		for i, a := range c.args {
+			if a.path == "" {
+				continue
+			}
+			cases.WriteString("\t" + shellQuote(c.name+":"+strconv.Itoa(i)) + ")\n")
+			cases.WriteString("\t\tkind=" + strings.ToLower(a.path) + " dir=" + shellQuote(filepath.ToSlash(dir)) + " glob=" + shellQuote(a.glob) + " ;;\n")
+		}
+	}
+	sort.Strings(names)
+
+	b.WriteString("# bash completion for the zeus commands of " + project + "\n")
+	b.WriteString("# generated by: zeus help --export bash\n")
+	b.WriteString("# usage: source " + completionScriptPath + " (zsh: autoload bashcompinit && bashcompinit first)\n\n")
+
+	// paths below the current directory of the command, relative to it
+	b.WriteString(`_zeus_paths() {
+	local kind=$1 glob=$2 cur=$3 f g globs
+	compgen -d -S / -- "$cur"
+	[ "$kind" = dir ] && return
+	IFS='|' read -ra globs <<< "${glob:-*}"
+	while IFS= read -r f; do
+		[ -d "$f" ] && continue
+		for g in "${globs[@]}"; do
+			if [[ ${f##*/} == $g ]]; then
+				echo "$f "
+				break
+			fi
+		done
+	done < <(compgen -f -- "$cur")
+}
+
+_zeus() {
+	local cur=${COMP_WORDS[COMP_CWORD]} kind= dir=. glob= IFS=$'\n'
+
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		IFS=' ' COMPREPLY=($(compgen -W "`)
+	b.WriteString(strings.Join(names, " "))
+	b.WriteString(`" -- "$cur"))
+		return
+	fi
+
+	case "${COMP_WORDS[1]}:$((COMP_CWORD - 2))" in
+`)
+	b.Write(cases.Bytes())
+	b.WriteString(`	*)
+		return
+		;;
+	esac
+
+	COMPREPLY=($(cd "$dir" 2>/dev/null && _zeus_paths "$kind" "$glob" "$cur"))
+	compopt -o nospace 2>/dev/null
+}
+
+complete -F _zeus zeus
+`)
+
+	return b.Bytes()
+}
@@ -0,0 +1,52 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrExecutionDisabled means a command would be executed in inspection mode
+var ErrExecutionDisabled = errors.New("execution is disabled in read-only mode")
+
+// browse the project without executing commands or modifying files
+const readOnlyFlag = "--read-only"
+
+// inspection mode was requested with the read-only flag
+var inspectMode bool
+
+// check for the read-only flag in front of the command and remove it from the arguments
+// inspection mode implies read-only mode for the project
+func handleReadOnlyFlag() {
+	for len(os.Args) > 1 && os.Args[1] == readOnlyFlag {
+		inspectMode = true
+		readOnly = true
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+}
+
+// refuse to execute anything in inspection mode
+func checkExecutionAllowed(name string) error {
+	if inspectMode {
+		Log.WithError(ErrExecutionDisabled).Error("not executing " + name)
+		return ErrExecutionDisabled
+	}
+	return nil
+}
@@ -37,7 +37,9 @@ var (
 
 		if keyName, ok := keyMap[key]; ok {
 			if chain, ok := projectData.KeyBindings[keyName]; ok {
-				executeCommand(chain)
+				inBackground(func() {
+					executeCommand(chain)
+				})
 			}
 		}
 
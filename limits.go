@@ -0,0 +1,170 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidLimits means the resource limits could not be parsed
+var ErrInvalidLimits = errors.New("invalid resource limits, expected: memory=<size> cpu=<cores> files=<count>")
+
+// resourceLimits restrict the resources a command can use
+// zero values mean no limit
+type resourceLimits struct {
+
+	// memory in bytes
+	memory int64
+
+	// number of CPU cores, fractions are allowed
+	cpu float64
+
+	// maximum number of open files
+	files int
+}
+
+// parse limits in the form: memory=512M cpu=1.5 files=1024
+// memory sizes accept the suffixes K, M and G
+func parseLimits(s string) (*resourceLimits, error) {
+
+	var r = new(resourceLimits)
+
+	for _, field := range strings.Fields(s) {
+
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, ErrInvalidLimits
+		}
+
+		var err error
+		switch kv[0] {
+		case "memory":
+			r.memory, err = parseSize(kv[1])
+		case "cpu":
+			r.cpu, err = strconv.ParseFloat(kv[1], 64)
+		case "files":
+			r.files, err = strconv.Atoi(kv[1])
+		default:
+			return nil, ErrInvalidLimits
+		}
+		if err != nil {
+			return nil, ErrInvalidLimits
+		}
+	}
+
+	return r, nil
+}
+
+// parse a size with an optional K, M or G suffix into bytes
+func parseSize(s string) (int64, error) {
+
+	if s == "" {
+		return 0, ErrInvalidLimits
+	}
+
+	var (
+		unit   int64 = 1
+		suffix       = strings.ToUpper(s[len(s)-1:])
+	)
+
+	switch suffix {
+	case "K":
+		unit = 1024
+	case "M":
+		unit = 1024 * 1024
+	case "G":
+		unit = 1024 * 1024 * 1024
+	}
+	if unit > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return n * unit, nil
+}
+
+// get the resource limits for a command
+// commands without a limits header field use the DefaultLimits from the config
+func (c *command) resourceLimits() (*resourceLimits, error) {
+
+	spec := c.limits
+	if spec == "" {
+		spec = conf.DefaultLimits
+	}
+	if spec == "" {
+		return nil, nil
+	}
+
+	return parseLimits(spec)
+}
+
+// wrap cmd so it runs with the resource limits
+// CPU and memory are limited with a cgroup when supported,
+// the number of open files and the memory otherwise are limited with ulimit
+func (r *resourceLimits) wrap(cmd *exec.Cmd) (*exec.Cmd, error) {
+
+	if runtime.GOOS == "windows" {
+		Log.Warn("resource limits are not supported on windows")
+		return cmd, nil
+	}
+
+	var (
+		args     = append([]string{cmd.Path}, cmd.Args[1:]...)
+		cgroup   bool
+		ulimits  []string
+		cgArgs   []string
+		cpuLimit = r.cpu > 0
+	)
+
+	if r.memory > 0 || cpuLimit {
+		cgArgs, cgroup = cgroupCommand(r)
+	}
+
+	if r.files > 0 {
+		ulimits = append(ulimits, "-n "+strconv.Itoa(r.files))
+	}
+	if r.memory > 0 && !cgroup {
+		ulimits = append(ulimits, "-v "+strconv.FormatInt(r.memory/1024, 10))
+	}
+	if cpuLimit && !cgroup {
+		Log.Warn("cpu limits require cgroups v2 and systemd-run, running without cpu limit")
+	}
+
+	if len(ulimits) > 0 {
+		wrapper, err := shellCommand("-c", "ulimit "+strings.Join(ulimits, " ")+` && exec "$@"`, "zeus")
+		if err != nil {
+			return nil, err
+		}
+		args = append(append([]string{wrapper.Path}, wrapper.Args[1:]...), args...)
+	}
+
+	if cgroup {
+		args = append(cgArgs, args...)
+	}
+
+	return exec.Command(args[0], args[1:]...), nil
+}
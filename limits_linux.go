@@ -0,0 +1,60 @@
+//go:build linux
+// +build linux
+
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// path to check for the unified cgroup v2 hierarchy
+var cgroupControllersPath = "/sys/fs/cgroup/cgroup.controllers"
+
+// get the command prefix for running a command inside a transient cgroup with memory and cpu limits
+// systemd-run is used, because creating cgroups directly requires delegation of the cgroup tree
+// returns false if cgroups v2 or systemd-run are not available
+func cgroupCommand(r *resourceLimits) ([]string, bool) {
+
+	if _, err := os.Stat(cgroupControllersPath); err != nil {
+		return nil, false
+	}
+
+	systemdRun, err := exec.LookPath("systemd-run")
+	if err != nil {
+		return nil, false
+	}
+
+	args := []string{systemdRun, "--user", "--scope", "--quiet"}
+	if os.Geteuid() == 0 {
+		args = []string{systemdRun, "--scope", "--quiet"}
+	}
+
+	if r.memory > 0 {
+		args = append(args, "-p", "MemoryMax="+strconv.FormatInt(r.memory, 10))
+	}
+	if r.cpu > 0 {
+		args = append(args, "-p", "CPUQuota="+strconv.Itoa(int(r.cpu*100))+"%")
+	}
+
+	return append(args, "--"), true
+}
@@ -0,0 +1,88 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLimits(t *testing.T) {
+
+	tests := []struct {
+		name string
+		s    string
+		want *resourceLimits
+		err  error
+	}{
+		{"empty", "", &resourceLimits{}, nil},
+		{"memory", "memory=512M", &resourceLimits{memory: 512 << 20}, nil},
+		{"cpu", "cpu=1.5", &resourceLimits{cpu: 1.5}, nil},
+		{"files", "files=1024", &resourceLimits{files: 1024}, nil},
+		{"all fields", "memory=1G cpu=2 files=64", &resourceLimits{memory: 1 << 30, cpu: 2, files: 64}, nil},
+		{"invalid memory", "memory=lots", nil, ErrInvalidLimits},
+		{"invalid cpu", "cpu=two", nil, ErrInvalidLimits},
+		{"invalid files", "files=1.5", nil, ErrInvalidLimits},
+		{"missing equals", "memory", nil, ErrInvalidLimits},
+		{"unknown field", "disk=1G", nil, ErrInvalidLimits},
+	}
+
+	for _, test := range tests {
+		got, err := parseLimits(test.s)
+		if err != test.err {
+			t.Errorf("%s: parseLimits(%q) error = %v, want %v", test.name, test.s, err, test.err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: parseLimits(%q) = %+v, want %+v", test.name, test.s, got, test.want)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+
+	tests := []struct {
+		s     string
+		want  int64
+		valid bool
+	}{
+		{"0", 0, true},
+		{"100", 100, true},
+		{"2K", 2 << 10, true},
+		{"2k", 2 << 10, true},
+		{"3M", 3 << 20, true},
+		{"4G", 4 << 30, true},
+		{"", 0, false},
+		{"M", 0, false},
+		{"1T", 0, false},
+		{"1.5G", 0, false},
+		{"ten", 0, false},
+	}
+
+	for _, test := range tests {
+		got, err := parseSize(test.s)
+		if (err == nil) != test.valid {
+			t.Errorf("parseSize(%q) error = %v, want valid = %v", test.s, err, test.valid)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseSize(%q) = %d, want %d", test.s, got, test.want)
+		}
+	}
+}
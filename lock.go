@@ -0,0 +1,80 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrLocked means the zeus directory is locked by another zeus instance
+	ErrLocked = errors.New("zeus directory is locked by another instance")
+
+	// ErrReadOnly means the action would modify the project while in read-only mode
+	ErrReadOnly = errors.New("not allowed in read-only mode")
+
+	// path to the lockfile of the zeus directory
+	lockfilePath = "zeus/zeus.lock"
+
+	// handle of the lockfile while the lock is held
+	projectLock *os.File
+
+	// in read-only mode project data, config and scripts are not modified
+	readOnly bool
+)
+
+// take the lock on the zeus directory
+// if another instance holds it, zeus continues in read-only mode
+func acquireProjectLock() {
+
+	f, err := lockProject(lockfilePath)
+	if err != nil {
+		if err == ErrLocked {
+			Log.Warn(ErrLocked, " (pid ", lockHolder(), "), continuing in read-only mode")
+			readOnly = true
+			return
+		}
+		Log.WithError(err).Error("failed to lock zeus directory")
+		return
+	}
+
+	// record the pid of the lock holder
+	err = f.Truncate(0)
+	if err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err != nil {
+		Log.WithError(err).Debug("failed to write pid to lockfile")
+	}
+
+	projectLock = f
+}
+
+// get the pid of the instance holding the lock
+func lockHolder() string {
+	c, err := ioutil.ReadFile(lockfilePath)
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(c))
+}
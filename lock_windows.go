@@ -0,0 +1,93 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// create the lockfile at path exclusively
+// a lockfile left behind by a crashed instance is removed when its process does not exist anymore
+func lockProject(path string) (*os.File, error) {
+
+	f, err := createLockfile(path)
+	if err == nil {
+		return f, nil
+	}
+	if !os.IsExist(err) {
+		return nil, err
+	}
+
+	// a lockfile without a valid pid is held, the holder might still be starting
+	holder := lockHolder()
+	pid, err := strconv.Atoi(holder)
+	if err != nil {
+		return nil, ErrLocked
+	}
+	if _, err = os.FindProcess(pid); err == nil {
+		return nil, ErrLocked
+	}
+
+	// stale lockfile, unless another instance replaced it in the meantime
+	if lockHolder() != holder {
+		return nil, ErrLocked
+	}
+	err = os.Remove(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err = createLockfile(path)
+	if os.IsExist(err) {
+		return nil, ErrLocked
+	}
+	return f, err
+}
+
+// create the lockfile at path with the pid of this instance
+// the pid is written to a temporary file that is linked to path,
+// so the lockfile never becomes visible without a pid
+// returns an error satisfying os.IsExist if path exists
+func createLockfile(path string) (*os.File, error) {
+
+	tmp := path + "." + strconv.Itoa(os.Getpid())
+	err := ioutil.WriteFile(tmp, []byte(strconv.Itoa(os.Getpid())+"\n"), 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	err = os.Link(tmp, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(path, os.O_RDWR, 0600)
+}
+
+// release the lock on the zeus directory
+func releaseProjectLock() {
+	if projectLock != nil {
+		projectLock.Close()
+		os.Remove(lockfilePath)
+		projectLock = nil
+	}
+}
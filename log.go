@@ -25,12 +25,24 @@ import (
 	"time"
 
 	"log"
+
+	"github.com/mattn/go-colorable"
 )
 
 var (
 
+	// standard output and error, ANSI escape sequences are translated on windows consoles
+	stdout = colorable.NewColorableStdout()
+	stderr = colorable.NewColorableStderr()
+
 	// logging instance
-	l = log.New(os.Stdout, "", 0)
+	l = log.New(stdout, "", 0)
+
+	// current output of the logging instance
+	logOutput = stdout
+
+	// handle of the zeus logfile, closed on shutdown
+	logFile *os.File
 
 	// path to the zeus logfile
 	pathLogfile = "zeus/zeus.log"
@@ -52,12 +64,15 @@ func logToFile() (*os.File, error) {
 	if conf.LogToFileColor {
 
 		// set logger output to MultiWriter
-		l.SetOutput(io.MultiWriter(f, os.Stdout))
+		logOutput = io.MultiWriter(&maskingWriter{f}, stdout)
 	} else {
 		// write into strip ansi writer
-		l.SetOutput(io.MultiWriter(os.Stdout, ansistrip.New(f)))
+		logOutput = io.MultiWriter(stdout, &maskingWriter{ansistrip.New(f)})
 	}
 
+	l.SetOutput(logOutput)
+	logFile = f
+
 	f.WriteString(time.Now().Format(timestampFormat) + "\n")
 
 	return f, nil
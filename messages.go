@@ -0,0 +1,228 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// directory for the message catalogs, inside the zeus directory or ~/.zeus
+const localeDir = "locale"
+
+// messageCatalog translates the messages of zeus into the selected language
+// the catalog files map the english message to its translation,
+// messages without a translation are printed in english
+type messageCatalog struct {
+
+	// selected locale, empty for english
+	locale string
+
+	// english message -> translation
+	messages map[string]string
+
+	// set when the catalog must be loaded again
+	stale bool
+
+	sync.RWMutex
+}
+
+var catalog = &messageCatalog{stale: true}
+
+// load the catalog again on the next use
+// must be called when the config changes
+func invalidateMessages() {
+	catalog.Lock()
+	catalog.stale = true
+	catalog.Unlock()
+}
+
+// determine the locale from the config or the environment
+// returns an empty string for english
+func selectedLocale() string {
+
+	if conf != nil && conf.Language != "" {
+		return normalizeLocale(conf.Language)
+	}
+
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return normalizeLocale(value)
+		}
+	}
+
+	return ""
+}
+
+// strip the encoding and modifier from a locale, eg: de_DE.UTF-8@euro -> de_DE
+func normalizeLocale(locale string) string {
+
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	switch locale {
+	case "C", "POSIX", "en", "en_US":
+		return ""
+	}
+
+	return strings.Replace(locale, "-", "_", -1)
+}
+
+// candidate catalog files for a locale, in the order they are merged
+// the language catalog is merged before the regional one, and the global catalogs before the project ones
+func catalogPaths(locale string) (paths []string) {
+
+	names := []string{locale + ".json"}
+	if i := strings.Index(locale, "_"); i > 0 {
+		names = []string{locale[:i] + ".json", locale + ".json"}
+	}
+
+	for _, dir := range []string{homePath(filepath.Join(".zeus", localeDir)), filepath.Join(zeusDir, localeDir)} {
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+
+	return paths
+}
+
+// load the catalogs for the selected locale
+func (m *messageCatalog) load() {
+
+	m.locale = selectedLocale()
+	m.messages = map[string]string{}
+
+	// the config is not loaded yet, try again once it is
+	m.stale = conf == nil
+
+	if m.locale == "" {
+		return
+	}
+
+	for _, path := range catalogPaths(m.locale) {
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var messages map[string]string
+		err = json.Unmarshal(contents, &messages)
+		if err != nil {
+			// do not log here, the hook would end up in here again
+			l.Println("failed to parse message catalog " + path + ": " + err.Error())
+			continue
+		}
+
+		for k, v := range messages {
+			if v != "" {
+				m.messages[k] = v
+			}
+		}
+	}
+}
+
+// lookup the translation of a message
+// messages starting with a translated message followed by a space or colon are translated partially,
+// so messages with appended values can be localized as well
+func (m *messageCatalog) lookup(message string) (string, bool) {
+
+	m.RLock()
+	stale := m.stale
+	m.RUnlock()
+
+	if stale {
+		m.Lock()
+		if m.stale {
+			m.load()
+		}
+		m.Unlock()
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	if len(m.messages) == 0 {
+		return message, false
+	}
+
+	if t, ok := m.messages[message]; ok {
+		return t, true
+	}
+
+	var prefix string
+	for k := range m.messages {
+		if len(k) > len(prefix) && len(k) < len(message) && strings.HasPrefix(message, k) && strings.ContainsRune(" :", rune(message[len(k)])) {
+			prefix = k
+		}
+	}
+	if prefix != "" {
+		return m.messages[prefix] + message[len(prefix):], true
+	}
+
+	return message, false
+}
+
+// translate a message into the selected language
+func msg(message string) string {
+	t, _ := catalog.lookup(message)
+	return t
+}
+
+// localizeHook translates log messages and errors before they are formatted
+type localizeHook struct{}
+
+func (localizeHook) Levels() []logrus.Level {
+	return []logrus.Level{
+		logrus.PanicLevel,
+		logrus.FatalLevel,
+		logrus.ErrorLevel,
+		logrus.WarnLevel,
+		logrus.InfoLevel,
+		logrus.DebugLevel,
+	}
+}
+
+func (localizeHook) Fire(entry *logrus.Entry) error {
+
+	entry.Message = msg(entry.Message)
+
+	if err, ok := entry.Data[logrus.ErrorKey].(error); ok {
+		if t, ok := catalog.lookup(err.Error()); ok {
+
+			// the fields are shared with the entry the message was logged on
+			data := make(logrus.Fields, len(entry.Data))
+			for k, v := range entry.Data {
+				data[k] = v
+			}
+			data[logrus.ErrorKey] = errors.New(t)
+			entry.Data = data
+		}
+	}
+
+	return nil
+}
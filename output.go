@@ -0,0 +1,382 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgutz/ansi"
+)
+
+var (
+	// output of the recent runs
+	outputHistory = newOutputRuns()
+
+	// run the output of the current command chain is recorded in
+	// set by the first command of a run, protected by runMutex like the command counters
+	currentOutputRun *outputRun
+
+	// default number of lines kept in the output history of a run
+	defaultOutputHistorySize = 1000
+
+	// number of runs whose output is kept
+	outputHistoryRuns = 10
+
+	// incomplete lines longer than this are recorded without waiting for the newline
+	maxRecordedLineLength = 64 * 1024
+
+	// matches ANSI escape sequences
+	ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*[a-zA-Z]")
+)
+
+// outputRing is a fixed size ring buffer for lines of command output
+// once it is full, the oldest lines are overwritten
+// so the memory usage stays bounded for long running commands
+type outputRing struct {
+	lines []string
+
+	// index of the next line to write
+	next int
+
+	// total number of lines written since the last reset
+	total int
+
+	mutex *sync.Mutex
+}
+
+// create a new output ring with capacity for size lines
+func newOutputRing(size int) *outputRing {
+	if size < 1 {
+		size = 1
+	}
+	return &outputRing{
+		lines: make([]string, size),
+		mutex: &sync.Mutex{},
+	}
+}
+
+// add a line to the ring
+// thread safe
+func (r *outputRing) add(line string) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	r.total++
+}
+
+// get the buffered lines, oldest first
+// thread safe
+func (r *outputRing) snapshot() []string {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.total < len(r.lines) {
+		return append([]string{}, r.lines[:r.total]...)
+	}
+
+	return append(append([]string{}, r.lines[r.next:]...), r.lines[:r.next]...)
+}
+
+// number of lines that were dropped because the ring was full
+// thread safe
+func (r *outputRing) dropped() int {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.total > len(r.lines) {
+		return r.total - len(r.lines)
+	}
+	return 0
+}
+
+// outputRun is the recorded output of a single run
+type outputRun struct {
+	*outputRing
+
+	id      int
+	name    string
+	started time.Time
+}
+
+// record a line of command output
+// secrets and ANSI escape sequences are removed
+func (r *outputRun) record(line []byte) {
+	r.add(ansiEscape.ReplaceAllString(maskSecrets(strings.TrimRight(string(line), "\r\n")), ""))
+}
+
+// outputRuns keeps the output of the recent runs
+// every run has a ring of its own, so runs executing at the same time neither mix nor reset their output
+type outputRuns struct {
+
+	// oldest first
+	runs []*outputRun
+
+	// id of the last run
+	lastID int
+
+	mutex *sync.Mutex
+}
+
+func newOutputRuns() *outputRuns {
+	return &outputRuns{
+		mutex: &sync.Mutex{},
+	}
+}
+
+// start recording the output of a new run, the output of the oldest run is dropped
+// thread safe
+func (o *outputRuns) start(name string) *outputRun {
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	var size = defaultOutputHistorySize
+	if conf != nil && conf.OutputHistorySize > 0 {
+		size = conf.OutputHistorySize
+	}
+
+	o.lastID++
+	r := &outputRun{
+		outputRing: newOutputRing(size),
+		id:         o.lastID,
+		name:       name,
+		started:    time.Now(),
+	}
+
+	o.runs = append(o.runs, r)
+	if len(o.runs) > outputHistoryRuns {
+		o.runs = o.runs[len(o.runs)-outputHistoryRuns:]
+	}
+
+	return r
+}
+
+// get the run with the id, or the last run if id is 0
+// returns nil if the run is not kept anymore
+// thread safe
+func (o *outputRuns) get(id int) *outputRun {
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if id == 0 && len(o.runs) > 0 {
+		return o.runs[len(o.runs)-1]
+	}
+
+	for _, r := range o.runs {
+		if r.id == id {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// get the kept runs, oldest first
+// thread safe
+func (o *outputRuns) list() []*outputRun {
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return append([]*outputRun{}, o.runs...)
+}
+
+// get the lines of the last run
+func lastRunOutput() []string {
+	if r := outputHistory.get(0); r != nil {
+		return r.snapshot()
+	}
+	return nil
+}
+
+// outputRecorder records the lines written by a command in the output of its run
+// and passes the output on unchanged
+type outputRecorder struct {
+	w   io.Writer
+	run *outputRun
+
+	// incomplete line
+	buf []byte
+
+	mutex *sync.Mutex
+}
+
+func newOutputRecorder(w io.Writer, run *outputRun) *outputRecorder {
+	return &outputRecorder{
+		w:     w,
+		run:   run,
+		mutex: &sync.Mutex{},
+	}
+}
+
+// implement io.Writer
+func (r *outputRecorder) Write(b []byte) (int, error) {
+
+	r.mutex.Lock()
+	r.buf = append(r.buf, b...)
+	for {
+		i := bytes.IndexByte(r.buf, '\n')
+		if i < 0 {
+			break
+		}
+		r.run.record(r.buf[:i])
+		r.buf = r.buf[i+1:]
+	}
+	if len(r.buf) > maxRecordedLineLength {
+		r.run.record(r.buf)
+		r.buf = nil
+	}
+	r.mutex.Unlock()
+
+	return r.w.Write(b)
+}
+
+// Flush records the incomplete line
+func (r *outputRecorder) Flush() {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.buf) > 0 {
+		r.run.record(r.buf)
+		r.buf = nil
+	}
+}
+
+func printOutputUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: output [runs] [run <id>] [tail <n>] [search <regex>]")
+}
+
+// handle output shell command
+func handleOutputCommand(args []string) {
+
+	if len(args) == 2 && args[1] == "runs" {
+		printOutputRuns()
+		return
+	}
+
+	// select a previous run, the last run is the default
+	var id int
+	if len(args) > 2 && args[1] == "run" {
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 1 {
+			printOutputUsageErr()
+			return
+		}
+		id = n
+		args = append(args[:1], args[3:]...)
+	}
+
+	run := outputHistory.get(id)
+	if run == nil {
+		if id == 0 {
+			l.Println("no output recorded.")
+		} else {
+			l.Println("the output of run " + strconv.Itoa(id) + " is not kept anymore.")
+		}
+		return
+	}
+
+	if len(args) < 2 {
+		printOutput(run, run.snapshot(), 0)
+		return
+	}
+
+	if len(args) < 3 {
+		printOutputUsageErr()
+		return
+	}
+
+	switch args[1] {
+	case "tail":
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 1 {
+			printOutputUsageErr()
+			return
+		}
+
+		lines := run.snapshot()
+		offset := 0
+		if len(lines) > n {
+			offset = len(lines) - n
+		}
+		printOutput(run, lines[offset:], offset)
+	case "search":
+		r, err := regexp.Compile(strings.Join(args[2:], " "))
+		if err != nil {
+			Log.WithError(err).Error("invalid regex")
+			return
+		}
+
+		var found int
+		for i, line := range run.snapshot() {
+			if r.MatchString(line) {
+				l.Println(cp.colorPrompt + pad(strconv.Itoa(i+1), 6) + ansi.Reset + line)
+				found++
+			}
+		}
+		l.Println(cp.colorText + "found " + strconv.Itoa(found) + " matching lines" + ansi.Reset)
+	default:
+		printOutputUsageErr()
+	}
+}
+
+// print the kept runs, oldest first
+func printOutputRuns() {
+
+	runs := outputHistory.list()
+	if len(runs) == 0 {
+		l.Println("no output recorded.")
+		return
+	}
+
+	for _, r := range runs {
+		l.Println(cp.colorPrompt + pad("#"+strconv.Itoa(r.id), 6) + ansi.Reset + r.started.Format("15:04:05") + "  " + r.name + cp.colorText + " (" + strconv.Itoa(len(r.snapshot())) + " lines)" + ansi.Reset)
+	}
+}
+
+// print the lines of the output history of a run with line numbers
+func printOutput(run *outputRun, lines []string, offset int) {
+
+	if len(lines) == 0 {
+		l.Println("no output recorded.")
+		return
+	}
+
+	if n := run.dropped(); n > 0 {
+		l.Println(cp.colorText + "(" + strconv.Itoa(n) + " earlier lines dropped)" + ansi.Reset)
+	}
+
+	for i, line := range lines {
+		l.Println(cp.colorPrompt + pad(strconv.Itoa(offset+i+1), 6) + ansi.Reset + line)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+var (
+	// nested zeus packages of a monorepo
+	// package names mapped to package structs
+	packages = make(map[string]*zeusPackage, 0)
+
+	// separator between package namespace and command name
+	packageSeparator = ":"
+)
+
+// zeusPackage is a nested zeus directory inside a monorepo
+// its commands are available in the namespace of the package: <package>:<command>
+type zeusPackage struct {
+
+	// namespace for the commands
+	name string
+
+	// directory of the package, commands will be executed in here
+	dir string
+
+	// path of the nested zeus directory
+	zeusDir string
+
+	// contents of the packages globals script
+	globals []byte
+}
+
+// find nested zeus directories matching the configured patterns
+// and return the scripts inside of them
+func findPackages() (scripts []string) {
+
+	var cLog = Log.WithField("prefix", "findPackages")
+
+	for _, pattern := range strings.Fields(conf.PackagePatterns) {
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			cLog.WithError(err).Error("invalid package pattern: ", pattern)
+			continue
+		}
+
+		for _, match := range matches {
+
+			// skip the projects own zeus directory
+			if filepath.Clean(match) == zeusDir {
+				continue
+			}
+
+			stat, err := os.Stat(match)
+			if err != nil || !stat.IsDir() {
+				continue
+			}
+
+			pkg := &zeusPackage{
+				name:    filepath.Base(filepath.Dir(match)),
+				dir:     filepath.Dir(match),
+				zeusDir: filepath.Clean(match),
+			}
+
+			// the package was found before, when the scripts were collected on startup
+			if existing, ok := packages[pkg.name]; ok && existing.zeusDir == pkg.zeusDir {
+				pkg = existing
+			} else if ok {
+				cLog.WithFields(logrus.Fields{
+					"first":  existing.dir,
+					"second": pkg.dir,
+				}).Fatal("package name ", pkg.name, " is used twice")
+			}
+
+			packages[pkg.name] = pkg
+
+			s, err := pkg.scripts()
+			if err != nil {
+				cLog.WithError(err).Error("failed to walk package: ", pkg.dir)
+				continue
+			}
+			scripts = append(scripts, s...)
+
+			cLog.Debug("found package ", pkg.name, " in ", pkg.dir)
+		}
+	}
+
+	return
+}
+
+// walk the packages zeus directory and collect the scripts
+// the globals script will be loaded for the package
+func (pkg *zeusPackage) scripts() (scripts []string, err error) {
+
+	err = walkFollow(pkg.zeusDir, func(path string, info os.FileInfo, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		// use forward slashes on all platforms
+		path = filepath.ToSlash(path)
+
+		if !strings.HasSuffix(path, f.fileExtension) {
+			return nil
+		}
+
+		if strings.HasPrefix(strings.TrimPrefix(path, pkg.zeusDir+"/"), "globals") {
+
+			g, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			// add newline to prevent parse errors
+			pkg.globals = append(g, []byte("\n")...)
+			return nil
+		}
+
+		scripts = append(scripts, path)
+		return nil
+	})
+
+	return
+}
+
+// get the package a script path belongs to
+// returns nil for scripts of the projects own zeus directory
+func packageForPath(path string) *zeusPackage {
+	for _, pkg := range packages {
+		if strings.HasPrefix(path, pkg.zeusDir+"/") {
+			return pkg
+		}
+	}
+	return nil
+}
+
+// get the command name for the script at path
+// commands of nested packages are prefixed with the package namespace
+func commandNameForPath(path string) string {
+
+	if pkg := packageForPath(path); pkg != nil {
+		return pkg.name + packageSeparator + strings.TrimSuffix(strings.TrimPrefix(path, pkg.zeusDir+"/"), f.fileExtension)
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(path, zeusDir+"/"), f.fileExtension)
+}
+
+// get the script path for a command name
+// names can be qualified with a package namespace to reference commands from other packages
+func commandPathForName(name string) string {
+
+	if i := strings.Index(name, packageSeparator); i > 0 {
+		if pkg, ok := packages[name[:i]]; ok {
+			return pkg.zeusDir + "/" + name[i+1:] + f.fileExtension
+		}
+	}
+
+	return zeusDir + "/" + name + f.fileExtension
+}
+
+// qualify a command name from a command chain with the package namespace
+// names that already contain a namespace are left untouched
+func (pkg *zeusPackage) qualify(name string) string {
+	if pkg == nil || strings.Contains(name, packageSeparator) {
+		return name
+	}
+	return pkg.name + packageSeparator + name
+}
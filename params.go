@@ -0,0 +1,226 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// flag to read the arguments of a command from a file
+const paramsFlag = "--params"
+
+var (
+	// ErrInvalidParams means the parameter file is not a flat mapping
+	ErrInvalidParams = errors.New("invalid parameter file, expected a flat mapping of argument names to values")
+
+	// ErrUnknownParam means the parameter is not an argument of the command
+	ErrUnknownParam = errors.New("unknown parameter")
+
+	// ErrMissingParam means an argument of the command has no value
+	ErrMissingParam = errors.New("missing parameter")
+)
+
+// replace the --params flag with the values of the file, in the order of the declared arguments
+// the remaining arguments override single values in the form name=value
+// commands without declared arguments get their arguments unchanged
+func (c *command) applyParamsFile(args []string) ([]string, error) {
+
+	if len(c.args) == 0 {
+		return args, nil
+	}
+
+	var (
+		path      string
+		overrides []string
+	)
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == paramsFlag:
+			if i+1 == len(args) {
+				return nil, ErrInvalidUsage
+			}
+			i++
+			path = args[i]
+		case strings.HasPrefix(args[i], paramsFlag+"="):
+			path = strings.TrimPrefix(args[i], paramsFlag+"=")
+		default:
+			overrides = append(overrides, args[i])
+		}
+	}
+
+	if path == "" {
+		return args, nil
+	}
+
+	values, err := readParamsFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range overrides {
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New("expected name=value instead of " + o)
+		}
+		values[kv[0]] = kv[1]
+	}
+
+	var declared = make(map[string]bool)
+	for _, a := range c.args {
+		declared[a.name] = true
+	}
+	for name := range values {
+		if !declared[name] {
+			return nil, errors.New(ErrUnknownParam.Error() + ": " + name + ", expected: " + getArgumentString(c.args))
+		}
+	}
+
+	var result []string
+	for _, a := range c.args {
+		v, ok := values[a.name]
+		if !ok {
+			return nil, errors.New(ErrMissingParam.Error() + ": " + a.name)
+		}
+		if !validArgType(v, a.argType) {
+			return nil, errors.New(ErrInvalidArgumentType.Error() + ": " + a.name + " must be " + a.typeName())
+		}
+		result = append(result, v)
+	}
+
+	return result, nil
+}
+
+// read the argument values from a JSON or YAML file
+func readParamsFile(path string) (map[string]string, error) {
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		return parseParamsYAML(contents)
+	default:
+		return parseParamsJSON(contents)
+	}
+}
+
+// parse a JSON object with scalar values
+func parseParamsJSON(contents []byte) (map[string]string, error) {
+
+	var raw map[string]interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(contents))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var values = make(map[string]string, len(raw))
+	for name, v := range raw {
+		switch v := v.(type) {
+		case string:
+			values[name] = v
+		case json.Number:
+			values[name] = v.String()
+		case bool:
+			values[name] = strconv.FormatBool(v)
+		default:
+			return nil, errors.New(ErrInvalidParams.Error() + ": " + name)
+		}
+	}
+
+	return values, nil
+}
+
+// parse the flat subset of YAML used for parameters: one name: value pair per line
+// nested mappings and lists are not supported, arguments are scalars
+func parseParamsYAML(contents []byte) (map[string]string, error) {
+
+	var values = make(map[string]string)
+
+	for i, line := range strings.Split(string(contents), "\n") {
+
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		lineErr := errors.New(ErrInvalidParams.Error() + ", line " + strconv.Itoa(i+1))
+
+		// indented lines belong to nested mappings, dashes start lists
+		if trimmed != line || strings.HasPrefix(trimmed, "-") {
+			return nil, lineErr
+		}
+
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			return nil, lineErr
+		}
+
+		name, err := unquoteYAML(strings.TrimSpace(kv[0]))
+		if err != nil || name == "" {
+			return nil, lineErr
+		}
+
+		value := strings.TrimSpace(kv[1])
+		if value == "" {
+			return nil, lineErr
+		}
+
+		if value[0] != '"' && value[0] != '\'' {
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+		}
+
+		value, err = unquoteYAML(value)
+		if err != nil {
+			return nil, lineErr
+		}
+
+		values[name] = value
+	}
+
+	return values, nil
+}
+
+// remove the quotes of a YAML scalar
+func unquoteYAML(s string) (string, error) {
+
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", ErrInvalidParams
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+
+	return s, nil
+}
@@ -19,8 +19,9 @@
 package main
 
 import (
+	"bufio"
 	"errors"
-	"io/ioutil"
+	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
@@ -66,6 +67,15 @@ type parser struct {
 	zeusFieldArgs        string
 	zeusFieldBuildNumber string
 	zeusFieldDependency  string
+	zeusFieldLimits      string
+	zeusFieldSandbox     string
+	zeusFieldEnv         string
+	zeusFieldResults     string
+	zeusFieldCoverage    string
+	zeusFieldDepends     string
+	zeusFieldFilter      string
+	zeusFieldTTY         string
+	zeusFieldRequires    string
 
 	// separator for build chain commands
 	separator string
@@ -94,6 +104,15 @@ func newParser() *parser {
 		zeusFieldArgs:        "zeus-args",
 		zeusFieldBuildNumber: "zeus-build-number",
 		zeusFieldDependency:  "zeus-dependency",
+		zeusFieldLimits:      "zeus-limits",
+		zeusFieldSandbox:     "zeus-sandbox",
+		zeusFieldEnv:         "zeus-env",
+		zeusFieldResults:     "zeus-results",
+		zeusFieldCoverage:    "zeus-coverage",
+		zeusFieldDepends:     "zeus-depends",
+		zeusFieldFilter:      "zeus-filter",
+		zeusFieldTTY:         "zeus-tty",
+		zeusFieldRequires:    "zeus-requires",
 
 		separator:      "->",
 		jobs:           map[string]*parseJob{},
@@ -110,6 +129,15 @@ type commandData struct {
 	manual         string
 	buildNumber    bool
 	dependency     string
+	limits         string
+	sandbox        string
+	env            string
+	results        string
+	coverage       string
+	depends        string
+	filter         string
+	tty            string
+	requires       string
 }
 
 // argument types
@@ -118,12 +146,72 @@ const (
 	argTypeInt    = "Int"
 	argTypeBool   = "Bool"
 	argTypeFloat  = "Float"
+
+	// string arguments completed from the filesystem
+	// an optional glob restricts the completed files, eg: Path(*.yml|*.yaml)
+	argTypePath = "Path"
+	argTypeDir  = "Dir"
 )
 
 // a commmand argument has a name and a type
 type commandArg struct {
 	name    string
 	argType reflect.Kind
+
+	// argTypePath or argTypeDir for arguments completed from the filesystem
+	path string
+
+	// file name patterns separated by |
+	glob string
+}
+
+// create an argument from its name and the type from the zeus-args header field
+func newCommandArg(name, typ string) (*commandArg, bool) {
+
+	var glob string
+	if i := strings.Index(typ, "("); i > 0 && strings.HasSuffix(typ, ")") {
+		typ, glob = typ[:i], typ[i+1:len(typ)-1]
+		if glob == "" {
+			return nil, false
+		}
+		for _, pattern := range strings.Split(glob, "|") {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, false
+			}
+		}
+	}
+
+	switch typ {
+	case argTypePath, argTypeDir:
+		return &commandArg{
+			name:    name,
+			argType: reflect.String,
+			path:    typ,
+			glob:    glob,
+		}, true
+	}
+
+	k, ok := getArgKind(typ)
+	if !ok || glob != "" {
+		return nil, false
+	}
+
+	return &commandArg{
+		name:    name,
+		argType: k,
+	}, true
+}
+
+// type of the argument for display
+// path arguments use the notation of the zeus-args header field
+func (a *commandArg) typeName() string {
+	if a.path == "" {
+		return a.argType.String()
+	}
+	if a.glob != "" {
+		return a.path + "(" + a.glob + ")"
+	}
+	return a.path
 }
 
 // parse script and return commandData
@@ -136,6 +224,13 @@ type commandArg struct {
 // # manual entry text
 // # ----------------------------------------------------------------------------------- #
 func (p *parser) parseScript(path string, job *parseJob) (*commandData, error) {
+	return p.parseScriptHeader(path, job, false)
+}
+
+// parse the script header
+// fixed is set when the file was already sanitized, so a broken file is only fixed once
+// and a sanitizer that cannot repair the file does not cause an endless loop
+func (p *parser) parseScriptHeader(path string, job *parseJob, fixed bool) (*commandData, error) {
 
 	var (
 		cLog = Log.WithFields(logrus.Fields{
@@ -152,26 +247,54 @@ func (p *parser) parseScript(path string, job *parseJob) (*commandData, error) {
 		d = new(commandData)
 	)
 
-	// get file contents
-	contents, err := ioutil.ReadFile(path)
+	// open file, only the header will be read
+	file, err := os.Open(path)
 	if err != nil {
 		return d, err
 	}
+	defer file.Close()
+
+	var (
+		scanner  = bufio.NewScanner(file)
+		c        = -1
+		reported bool
+	)
+
+	// keep carriage returns, to detect CRLF line endings
+	scanner.Split(scanRawLines)
 
 	// range line by line
-	for c, line := range strings.Split(string(contents), "\n") {
+	for scanner.Scan() {
+
+		line, issue := normalizeLine(scanner.Text(), c == -1)
+		c++
+
+		// bash cannot execute scripts with a byte order mark or CRLF line endings
+		if issue != "" && !reported {
+			if conf.FixParseErrors && !readOnly && !fixed {
+				sanitizeFile(path)
+				return p.parseScriptHeader(path, job, true)
+			}
+			cLog.Warn(issue, " in line ", c+1, ", bash will fail to execute the script. enable FixParseErrors to convert it")
+			reported = true
+		}
 
 		if c == 0 {
 			// first line. make sure theres a shebang
 			if line != p.shebang {
-				if conf.FixParseErrors {
+				if conf.FixParseErrors && !readOnly && !fixed {
 					sanitizeFile(path)
-					return p.parseScript(path, job)
+					return p.parseScriptHeader(path, job, true)
 				}
 				Log.Fatal("first line does not contain a shebang.")
 			}
 		}
 
+		// the header ends with the first line of code
+		if c > 0 && line != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+
 		// check if its a comment. only comments can be used as header fields
 		if strings.HasPrefix(line, "#") {
 
@@ -194,9 +317,9 @@ func (p *parser) parseScript(path string, job *parseJob) (*commandData, error) {
 				helpFieldCount++
 
 				if !validzeusHeaderField.MatchString(line) {
-					if conf.FixParseErrors {
+					if conf.FixParseErrors && !readOnly && !fixed {
 						sanitizeFile(path)
-						return p.parseScript(path, job)
+						return p.parseScriptHeader(path, job, true)
 					}
 					Log.Fatal("invalid zeus-help header field in line ", c, " : ", line)
 				}
@@ -209,9 +332,9 @@ func (p *parser) parseScript(path string, job *parseJob) (*commandData, error) {
 				argsFieldCount++
 
 				if !validzeusHeaderField.MatchString(line) {
-					if conf.FixParseErrors {
+					if conf.FixParseErrors && !readOnly && !fixed {
 						sanitizeFile(path)
-						return p.parseScript(path, job)
+						return p.parseScriptHeader(path, job, true)
 					}
 					Log.Fatal("invalid zeus-args header field in line ", c, " : ", line)
 				}
@@ -219,10 +342,7 @@ func (p *parser) parseScript(path string, job *parseJob) (*commandData, error) {
 				// parse arg types
 				for _, s := range strings.Fields(strings.TrimSpace(trimZeusPrefix(line))) {
 
-					var (
-						k     reflect.Kind
-						slice = strings.Split(s, ":")
-					)
+					var slice = strings.Split(s, ":")
 
 					if len(slice) == 2 {
 
@@ -235,24 +355,13 @@ func (p *parser) parseScript(path string, job *parseJob) (*commandData, error) {
 						}
 
 						// check if its a valid argType and set reflect.Kind
-						switch slice[1] {
-						case argTypeBool:
-							k = reflect.Bool
-						case argTypeFloat:
-							k = reflect.Float64
-						case argTypeString:
-							k = reflect.String
-						case argTypeInt:
-							k = reflect.Int
-						default:
+						a, ok := newCommandArg(slice[0], slice[1])
+						if !ok {
 							cLog.Fatal("invalid or missing argument type: ", slice[1])
 						}
 
 						// append to commandData args
-						d.args = append(d.args, &commandArg{
-							name:    slice[0],
-							argType: k,
-						})
+						d.args = append(d.args, a)
 					} else {
 						if !conf.AllowUntypedArgs {
 							cLog.Fatal("untyped arguments are not allowed: ", s)
@@ -268,14 +377,18 @@ func (p *parser) parseScript(path string, job *parseJob) (*commandData, error) {
 				chainFieldCount++
 
 				if !validzeusHeaderField.MatchString(line) {
-					if conf.FixParseErrors {
+					if conf.FixParseErrors && !readOnly && !fixed {
 						sanitizeFile(path)
-						return p.parseScript(path, job)
+						return p.parseScriptHeader(path, job, true)
 					}
 					Log.Fatal("invalid zeus-chain header field in line ", c, " : ", line)
 				}
 
-				d.parsedCommands = parseCommandChain(line)
+				d.parsedCommands, err = parseCommandChain(line)
+				if err != nil {
+					cLog.WithError(err).Error("invalid zeus-chain header field in line ", c, " : ", line)
+					return nil, err
+				}
 
 				break
 
@@ -289,12 +402,43 @@ func (p *parser) parseScript(path string, job *parseJob) (*commandData, error) {
 			case strings.Contains(line, p.zeusFieldDependency):
 				d.dependency = strings.TrimSpace(trimZeusPrefix(line))
 
+			case strings.Contains(line, p.zeusFieldLimits):
+				d.limits = strings.TrimSpace(trimZeusPrefix(line))
+
+			case strings.Contains(line, p.zeusFieldSandbox):
+				d.sandbox = strings.TrimSpace(trimZeusPrefix(line))
+
+			case strings.Contains(line, p.zeusFieldEnv):
+				d.env = strings.TrimSpace(trimZeusPrefix(line))
+
+			case strings.Contains(line, p.zeusFieldResults):
+				d.results = strings.TrimSpace(trimZeusPrefix(line))
+
+			case strings.Contains(line, p.zeusFieldCoverage):
+				d.coverage = strings.TrimSpace(trimZeusPrefix(line))
+
+			case strings.Contains(line, p.zeusFieldDepends):
+				d.depends = strings.TrimSpace(trimZeusPrefix(line))
+
+			case strings.Contains(line, p.zeusFieldFilter):
+				d.filter = strings.TrimSpace(trimZeusPrefix(line))
+
+			case strings.Contains(line, p.zeusFieldTTY):
+				d.tty = strings.TrimSpace(trimZeusPrefix(line))
+
+			case strings.Contains(line, p.zeusFieldRequires):
+				d.requires = strings.TrimSpace(trimZeusPrefix(line))
+
 			default:
 				continue
 			}
 		}
 	}
 
+	if err := scanner.Err(); err != nil {
+		return d, err
+	}
+
 	// check for duplicate fields
 	if argsFieldCount > 1 || chainFieldCount > 1 || helpFieldCount > 1 {
 		cLog.WithFields(logrus.Fields{
@@ -309,19 +453,38 @@ func (p *parser) parseScript(path string, job *parseJob) (*commandData, error) {
 	return d, nil
 }
 
+// get the reflect.Kind for an argument type name
+func getArgKind(argType string) (reflect.Kind, bool) {
+	switch argType {
+	case argTypeBool:
+		return reflect.Bool, true
+	case argTypeFloat:
+		return reflect.Float64, true
+	case argTypeString:
+		return reflect.String, true
+	case argTypeInt:
+		return reflect.Int, true
+	default:
+		return reflect.Invalid, false
+	}
+}
+
 // parse the command chain string
-func parseCommandChain(line string) (parsedCommands [][]string) {
+// the chain is split at parser separators outside of quotes
+// returns an error for unterminated quotes or empty commands, the whole chain is invalid then
+func parseCommandChain(line string) (parsedCommands [][]string, err error) {
 
-	var (
-		// trim whitespace and zeus prefix
-		// then get commands seperated by parser separator
-		cmds = strings.Split(strings.TrimSpace(trimZeusPrefix(line)), p.separator)
+	// trim whitespace and zeus prefix
+	// then get commands seperated by parser separator
+	cmds, err := splitCommandChain(strings.TrimSpace(trimZeusPrefix(line)), p.separator)
+	if err != nil {
+		return nil, err
+	}
 
-		cLog = Log.WithFields(logrus.Fields{
-			"prefix": "parseCommandChain",
-			"cmds":   cmds,
-		})
-	)
+	cLog := Log.WithFields(logrus.Fields{
+		"prefix": "parseCommandChain",
+		"cmds":   cmds,
+	})
 
 	cLog.Debug("starting to parse")
 
@@ -336,10 +499,14 @@ func parseCommandChain(line string) (parsedCommands [][]string) {
 		for _, name := range cmds {
 
 			// get arguments for commands
-			var args = strings.Fields(name)
+			args, err := splitCommandLine(name)
+			if err != nil {
+				cLog.WithError(err).Error("invalid command in chain: ", name)
+				return nil, err
+			}
 
 			if len(args) == 0 {
-				Log.Fatal(ErrEmptyName)
+				return nil, ErrEmptyName
 			}
 
 			parsedCommands = append(parsedCommands, args)
@@ -0,0 +1,244 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mgutz/ansi"
+)
+
+var (
+	// ErrScriptNotApproved means a script changed since it was last approved
+	ErrScriptNotApproved = errors.New("script changed since the last approval")
+
+	// path to the checksums of the approved scripts
+	pinsFilePath = "zeus/zeus_pins.json"
+
+	// approved script checksums
+	pins = &scriptPins{
+		Scripts: make(map[string]string),
+	}
+)
+
+// scriptPins maps script paths to the sha256 checksum of their approved contents
+type scriptPins struct {
+	Scripts map[string]string
+
+	sync.RWMutex
+}
+
+// load the approved checksums from disk
+func loadPins() {
+
+	pins.Lock()
+	defer pins.Unlock()
+
+	err := readWithFallback(pinsFilePath, func(b []byte) error {
+		s := make(map[string]string)
+		err := json.Unmarshal(b, &s)
+		if err == nil {
+			pins.Scripts = s
+		}
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		Log.WithError(err).Error("failed to read script pins")
+	}
+}
+
+// write the approved checksums to disk
+func (p *scriptPins) update() error {
+
+	if readOnly {
+		return ErrReadOnly
+	}
+
+	p.RLock()
+	b, err := json.MarshalIndent(p.Scripts, "", "	")
+	p.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(pinsFilePath, append(b, '\n'), 0644)
+}
+
+// hash the script at path with sha256
+func checksumScript(path string) (string, error) {
+
+	c, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.Sum256(c)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// check if the script at path matches its approved checksum
+func (p *scriptPins) approved(path string) (bool, error) {
+
+	sum, err := checksumScript(path)
+	if err != nil {
+		return false, err
+	}
+
+	p.RLock()
+	defer p.RUnlock()
+
+	return p.Scripts[path] == sum, nil
+}
+
+// approve the current contents of the scripts
+func (p *scriptPins) approve(paths ...string) error {
+
+	sums := make(map[string]string, len(paths))
+	for _, path := range paths {
+		sum, err := checksumScript(path)
+		if err != nil {
+			return err
+		}
+		sums[path] = sum
+	}
+
+	p.Lock()
+	for path, sum := range sums {
+		p.Scripts[path] = sum
+	}
+	p.Unlock()
+
+	return p.update()
+}
+
+// scripts executed by the command: its own script and the globals it uses
+func (c *command) pinnedScripts() []string {
+
+	scripts := []string{c.path}
+
+	if c.pkg != nil {
+		if len(c.pkg.globals) > 0 {
+			scripts = append(scripts, c.pkg.zeusDir+"/globals"+f.fileExtension)
+		}
+	} else if len(globalsContent) > 0 {
+		scripts = append(scripts, globalsScriptPath)
+	}
+
+	return scripts
+}
+
+// make sure all scripts of the command are approved
+// on a terminal the user is asked to approve changed scripts
+func (c *command) checkPins() error {
+
+	var changed []string
+	for _, path := range c.pinnedScripts() {
+		ok, err := pins.approved(path)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			changed = append(changed, path)
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	Log.Warn("not approved: ", strings.Join(changed, ", "))
+
+	if readOnly || !confirm("approve and run "+c.name+"?") {
+		return ErrScriptNotApproved
+	}
+
+	return pins.approve(changed...)
+}
+
+func printPinsUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: pins [approve <command|all>]")
+}
+
+// handle pins shell command
+func handlePinsCommand(args []string) {
+
+	scripts, err := projectScripts()
+	if err != nil {
+		Log.WithError(err).Error("failed to collect scripts")
+		return
+	}
+	if len(globalsContent) > 0 {
+		scripts = append(scripts, globalsScriptPath)
+	}
+	for _, pkg := range packages {
+		if len(pkg.globals) > 0 {
+			scripts = append(scripts, pkg.zeusDir+"/globals"+f.fileExtension)
+		}
+	}
+	scripts = append(scripts, discoveredScripts()...)
+	scripts = append(scripts, projectExecutables()...)
+	sort.Strings(scripts)
+
+	if len(args) < 2 {
+		for _, path := range scripts {
+			status := cp.colorPrompt + "approved"
+			ok, err := pins.approved(path)
+			if err != nil {
+				status = ansi.Red + err.Error()
+			} else if !ok {
+				status = ansi.Red + "changed"
+			}
+			l.Println(cp.colorText + pad(path, 40) + status + ansi.Reset)
+		}
+		return
+	}
+
+	if len(args) != 3 || args[1] != "approve" {
+		printPinsUsageErr()
+		return
+	}
+
+	if args[2] != "all" {
+		commandMutex.Lock()
+		cmd, ok := commands[args[2]]
+		commandMutex.Unlock()
+		if !ok {
+			Log.WithError(ErrUnknownCommand).Error(args[2])
+			return
+		}
+		scripts = cmd.pinnedScripts()
+	}
+
+	err = pins.approve(scripts...)
+	if err != nil {
+		Log.WithError(err).Error("failed to approve scripts")
+		return
+	}
+
+	l.Println(cp.colorText + "approved " + strings.Join(scripts, ", ") + ansi.Reset)
+}
@@ -0,0 +1,475 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chzyer/readline"
+)
+
+// ErrPluginVeto means a plugin refused the execution of a command
+var ErrPluginVeto = errors.New("execution refused by plugin")
+
+const (
+	// executables on the PATH with this prefix are loaded as plugins
+	pluginPrefix = "zeus-"
+
+	// project directory for plugins
+	pluginDir = "zeus/plugins"
+
+	// version of the plugin protocol, sent with every request
+	pluginProtocolVersion = 1
+
+	// maximum duration of plugin requests, except for builtins
+	pluginTimeout = 10 * time.Second
+)
+
+// plugin request types
+const (
+	pluginDescribe = "describe"
+	pluginBuiltin  = "builtin"
+	pluginComplete = "complete"
+	pluginBefore   = "before"
+	pluginAfter    = "after"
+	pluginNotify   = "notify"
+)
+
+// pluginRequest is written as JSON to the stdin of the plugin
+type pluginRequest struct {
+	Version int    `json:"version"`
+	Type    string `json:"type"`
+	Project string `json:"project"`
+
+	// builtin and complete requests
+	Builtin string `json:"builtin,omitempty"`
+
+	// before and after hooks
+	Command  string `json:"command,omitempty"`
+	ExitCode int    `json:"exitCode,omitempty"`
+	Duration string `json:"duration,omitempty"`
+
+	Args []string `json:"args,omitempty"`
+
+	// notifications
+	Level   string `json:"level,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// pluginResponse is read as JSON from the stdout of the plugin
+type pluginResponse struct {
+
+	// describe
+	Builtins      map[string]string `json:"builtins"`
+	Completions   bool              `json:"completions"`
+	Hooks         bool              `json:"hooks"`
+	Notifications bool              `json:"notifications"`
+
+	// builtin
+	Output string `json:"output"`
+
+	// complete
+	Candidates []string `json:"candidates"`
+
+	// any request, refuses the execution for before hooks
+	Error string `json:"error"`
+}
+
+// plugin is an external executable extending zeus
+type plugin struct {
+	name string
+	path string
+
+	builtins      map[string]string
+	completions   bool
+	hooks         bool
+	notifications bool
+}
+
+var (
+	// loaded plugins, in the order they were discovered
+	plugins []*plugin
+
+	// builtin name -> plugin providing it
+	pluginBuiltins = map[string]*plugin{}
+)
+
+// name of a plugin executable without prefix and extension
+func pluginName(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return strings.TrimPrefix(name, pluginPrefix)
+}
+
+// check if the file can be executed
+func isExecutableFile(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(info.Name()), ".exe")
+	}
+	return info.Mode()&0111 != 0
+}
+
+// collect the plugin executables of the project
+// they are part of the repository, so they must be approved and signed like the scripts
+func projectPlugins() (paths []string) {
+
+	files, err := ioutil.ReadDir(pluginDir)
+	if err != nil {
+		return nil
+	}
+
+	for _, info := range files {
+		if isExecutableFile(info) {
+			paths = append(paths, filepath.ToSlash(filepath.Join(pluginDir, info.Name())))
+		}
+	}
+
+	return paths
+}
+
+// collect the plugin executables
+// plugins from the config take precedence over the project plugins, which take precedence over the PATH
+func discoverPlugins() (paths []string) {
+
+	var seen = map[string]bool{}
+
+	add := func(path string) {
+		name := pluginName(path)
+		if !seen[name] {
+			seen[name] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, path := range strings.Fields(conf.Plugins) {
+		add(path)
+	}
+
+	for _, path := range projectPlugins() {
+		add(path)
+	}
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, info := range files {
+			if strings.HasPrefix(info.Name(), pluginPrefix) && isExecutableFile(info) {
+				add(filepath.Join(dir, info.Name()))
+			}
+		}
+	}
+
+	return paths
+}
+
+// load all plugins and register their builtins
+// plugins are executables, so nothing is loaded in inspection mode
+// the project plugins are only loaded once the command set was approved
+func loadPlugins() {
+
+	if inspectMode {
+		return
+	}
+
+	var (
+		cLog    = Log.WithField("prefix", "loadPlugins")
+		project = projectPlugins()
+		trusted = len(project) == 0 || trustCommandSet()
+	)
+
+	if !trusted {
+		cLog.Warn("the project plugins are disabled until the command set is approved")
+	}
+
+	for _, path := range discoverPlugins() {
+
+		if !trusted && isProjectPlugin(path, project) {
+			continue
+		}
+
+		var p = &plugin{name: pluginName(path), path: path}
+
+		res, err := p.call(&pluginRequest{Type: pluginDescribe}, pluginTimeout)
+		if err != nil {
+			cLog.WithError(err).Error("failed to load plugin " + path)
+			continue
+		}
+
+		p.builtins = map[string]string{}
+		p.completions = res.Completions
+		p.hooks = res.Hooks
+		p.notifications = res.Notifications
+
+		for name, description := range res.Builtins {
+
+			if _, ok := builtins[name]; ok {
+				cLog.Warn("plugin ", p.name, ": builtin ", name, " conflicts with a zeus builtin")
+				continue
+			}
+			if _, ok := commands[name]; ok {
+				cLog.Warn("plugin ", p.name, ": builtin ", name, " conflicts with a command")
+				continue
+			}
+			if other, ok := pluginBuiltins[name]; ok {
+				cLog.Warn("plugin ", p.name, ": builtin ", name, " is already provided by plugin ", other.name)
+				continue
+			}
+
+			p.builtins[name] = description
+			pluginBuiltins[name] = p
+
+			if p.completions {
+				completer.SetChildren(append(completer.GetChildren(), readline.PcItem(name, readline.PcItemDynamic(p.completer(name)))))
+			} else {
+				completer.SetChildren(append(completer.GetChildren(), readline.PcItem(name)))
+			}
+		}
+
+		plugins = append(plugins, p)
+		cLog.Debug("loaded plugin ", p.name, " from ", path)
+	}
+}
+
+// check if path is one of the project plugins
+func isProjectPlugin(path string, project []string) bool {
+	for _, p := range project {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// send a request to the plugin and read its response
+// the output of the plugin on stderr is passed through
+// a timeout of 0 lets the plugin run until it exits, it can be interrupted with Ctrl-C
+func (p *plugin) call(req *pluginRequest, timeout time.Duration) (*pluginResponse, error) {
+
+	if err := checkExecutionAllowed("plugin " + p.name); err != nil {
+		return nil, err
+	}
+
+	req.Version = pluginProtocolVersion
+	req.Project = workingDir
+
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out bytes.Buffer
+		cmd = exec.Command(p.path)
+	)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &out
+	cmd.Stderr = commandStderr
+	setProcessGroup(cmd)
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout > 0 {
+		t := time.AfterFunc(timeout, func() {
+			killProcessGroup(cmd.Process)
+		})
+		defer t.Stop()
+	} else {
+		processLock.Lock()
+		processMap["plugin "+p.name] = cmd.Process
+		processLock.Unlock()
+
+		defer func() {
+			processLock.Lock()
+			delete(processMap, "plugin "+p.name)
+			processLock.Unlock()
+		}()
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	var res = new(pluginResponse)
+	err = json.Unmarshal(out.Bytes(), res)
+	if err != nil {
+		return nil, errors.New("invalid response: " + err.Error())
+	}
+
+	if res.Error != "" {
+		return res, errors.New(res.Error)
+	}
+
+	return res, nil
+}
+
+// execute a builtin provided by the plugin
+func (p *plugin) runBuiltin(args []string) error {
+
+	res, err := p.call(&pluginRequest{
+		Type:    pluginBuiltin,
+		Builtin: args[0],
+		Args:    args[1:],
+	}, 0)
+	if res != nil && res.Output != "" {
+		l.Print(cp.colorText + strings.TrimSuffix(res.Output, "\n") + "\n")
+	}
+	if err != nil {
+		Log.WithError(err).Error("plugin ", p.name, ": ", args[0], " failed")
+		return err
+	}
+
+	return nil
+}
+
+// argument completion for a builtin of the plugin
+func (p *plugin) completer(builtin string) readline.DynamicCompleteFunc {
+	return func(line string) []string {
+
+		args, err := splitCommandLine(line)
+		if err != nil || len(args) == 0 {
+			return nil
+		}
+
+		res, err := p.call(&pluginRequest{
+			Type:    pluginComplete,
+			Builtin: builtin,
+			Args:    args[1:],
+		}, pluginTimeout)
+		if err != nil {
+			Log.WithError(err).Debug("plugin ", p.name, ": completion failed")
+			return nil
+		}
+
+		return res.Candidates
+	}
+}
+
+// run the before hooks of all plugins
+// the first plugin responding with an error prevents the execution of the command
+func pluginsBefore(command string, args []string) error {
+
+	for _, p := range plugins {
+		if !p.hooks {
+			continue
+		}
+
+		_, err := p.call(&pluginRequest{
+			Type:    pluginBefore,
+			Command: command,
+			Args:    args,
+		}, pluginTimeout)
+		if err != nil {
+			Log.WithError(err).Error("plugin ", p.name, " refused to execute ", command)
+			return ErrPluginVeto
+		}
+	}
+
+	return nil
+}
+
+// run the after hooks of all plugins
+func pluginsAfter(command string, args []string, err error, duration time.Duration) {
+
+	for _, p := range plugins {
+		if !p.hooks {
+			continue
+		}
+
+		_, hookErr := p.call(&pluginRequest{
+			Type:     pluginAfter,
+			Command:  command,
+			Args:     args,
+			ExitCode: exitCode(err),
+			Duration: duration.String(),
+		}, pluginTimeout)
+		if hookErr != nil {
+			Log.WithError(hookErr).Error("plugin ", p.name, ": after hook failed")
+		}
+	}
+}
+
+// send a notification to all plugins acting as notification sinks
+// the plugins are notified in parallel, the call returns when all of them are done
+func notifyPlugins(level, message string) {
+
+	var wg sync.WaitGroup
+
+	for _, p := range plugins {
+		if !p.notifications {
+			continue
+		}
+
+		wg.Add(1)
+		go func(p *plugin) {
+			defer wg.Done()
+
+			_, err := p.call(&pluginRequest{
+				Type:    pluginNotify,
+				Level:   level,
+				Message: message,
+			}, pluginTimeout)
+			if err != nil {
+				Log.WithError(err).Debug("plugin ", p.name, ": notification failed")
+			}
+		}(p)
+	}
+
+	wg.Wait()
+}
+
+// print the builtins provided by plugins
+func printPluginBuiltins() {
+
+	if len(pluginBuiltins) == 0 {
+		return
+	}
+
+	var names = make([]string, 0, len(pluginBuiltins))
+	for name := range pluginBuiltins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	l.Println(cp.colorText + "plugin builtins:")
+	for _, name := range names {
+		p := pluginBuiltins[name]
+		l.Println(cp.colorCommandName + pad(name, 15) + cp.colorText + " (" + p.name + ": " + p.builtins[name] + ")")
+	}
+	l.Println("")
+}
@@ -0,0 +1,89 @@
+//go:build !windows
+// +build !windows
+
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+
+	"github.com/mattn/go-isatty"
+)
+
+func init() {
+	// reclaiming the terminal from a background process group raises SIGTTOU
+	signal.Ignore(syscall.SIGTTOU)
+}
+
+// check if stdin is attached to a terminal
+func stdinIsTerminal() bool {
+	return isatty.IsTerminal(os.Stdin.Fd())
+}
+
+// run the command in its own process group, so its children can be signaled together
+// when stdin is a terminal the group is moved into the foreground,
+// keyboard signals like Ctrl-C are then delivered to the whole group by the terminal
+func setProcessGroup(cmd *exec.Cmd) {
+
+	attr := &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+
+	if cmd.Stdin == os.Stdin && stdinIsTerminal() {
+		attr.Foreground = true
+		attr.Ctty = int(os.Stdin.Fd())
+	}
+
+	cmd.SysProcAttr = attr
+}
+
+// move the process group of zeus back into the foreground after a command exited
+func restoreForeground() {
+
+	if !stdinIsTerminal() {
+		return
+	}
+
+	pgrp := int32(syscall.Getpgrp())
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdin.Fd(), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&pgrp)))
+	if errno != 0 {
+		Log.WithError(errno).Debug("failed to restore foreground process group")
+	}
+}
+
+// send sig to all processes in the process group of proc
+func signalProcessGroup(proc *os.Process, sig os.Signal) error {
+
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return proc.Signal(sig)
+	}
+
+	return syscall.Kill(-proc.Pid, s)
+}
+
+// kill all processes in the process group of proc
+func killProcessGroup(proc *os.Process) error {
+	return syscall.Kill(-proc.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,46 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// run the command in a new process group
+// console Ctrl-C events are not delivered to it, zeus terminates the command instead
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+	}
+}
+
+// the console stays attached to zeus on windows, nothing to restore
+func restoreForeground() {}
+
+// windows cannot deliver signals to other processes, the process is terminated
+func signalProcessGroup(proc *os.Process, sig os.Signal) error {
+	return proc.Kill()
+}
+
+// terminate proc
+func killProcessGroup(proc *os.Process) error {
+	return proc.Kill()
+}
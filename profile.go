@@ -0,0 +1,206 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// commandline flag to profile zeus itself
+	profileSelfFlag = "--profile-self"
+
+	// output paths for the self profiling
+	profileCPUPath     = "zeus/profile_cpu.pprof"
+	profileMemPath     = "zeus/profile_mem.pprof"
+	profileSummaryPath = "zeus/profile_summary.txt"
+
+	// active self profiler, nil when profiling is disabled
+	selfProfile *profiler
+)
+
+// profiler records where zeus spends time on startup
+type profiler struct {
+	start   time.Time
+	cpuFile *os.File
+
+	// phase names in the order they appeared
+	phases []string
+
+	// accumulated durations for the phases
+	durations map[string]time.Duration
+
+	mutex *sync.Mutex
+}
+
+// handle the --profile-self commandline flag
+// starts the CPU profile and removes the flag from os.Args
+func handleProfileFlag() {
+
+	var (
+		args  = []string{os.Args[0]}
+		found bool
+	)
+
+	for _, a := range os.Args[1:] {
+		if a == profileSelfFlag {
+			found = true
+			continue
+		}
+		args = append(args, a)
+	}
+
+	if !found {
+		return
+	}
+
+	os.Args = args
+
+	selfProfile = &profiler{
+		start:     time.Now(),
+		durations: make(map[string]time.Duration, 0),
+		mutex:     &sync.Mutex{},
+	}
+
+	// the zeus directory might not exist yet
+	f, err := os.Create(profileCPUPath)
+	if err != nil {
+		Log.WithError(err).Error("failed to create CPU profile, profiling without it")
+		return
+	}
+
+	err = pprof.StartCPUProfile(f)
+	if err != nil {
+		Log.WithError(err).Error("failed to start CPU profile")
+		f.Close()
+		return
+	}
+
+	selfProfile.cpuFile = f
+}
+
+// start measuring a phase, call the returned function when the phase is done
+// durations for phases with the same name are accumulated
+// thread safe
+func profilePhase(name string) func() {
+
+	if selfProfile == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		selfProfile.mutex.Lock()
+		if _, ok := selfProfile.durations[name]; !ok {
+			selfProfile.phases = append(selfProfile.phases, name)
+		}
+		selfProfile.durations[name] += time.Now().Sub(start)
+		selfProfile.mutex.Unlock()
+	}
+}
+
+// stop profiling, write the memory profile and print a summary
+func stopSelfProfile() {
+
+	if selfProfile == nil {
+		return
+	}
+
+	var cLog = Log.WithField("prefix", "stopSelfProfile")
+
+	if selfProfile.cpuFile != nil {
+		pprof.StopCPUProfile()
+		selfProfile.cpuFile.Close()
+	}
+
+	f, err := os.Create(profileMemPath)
+	if err != nil {
+		cLog.WithError(err).Error("failed to create memory profile")
+	} else {
+		runtime.GC()
+		err = pprof.WriteHeapProfile(f)
+		if err != nil {
+			cLog.WithError(err).Error("failed to write memory profile")
+		}
+		f.Close()
+	}
+
+	summary := selfProfile.summary()
+
+	l.Println(cp.colorText + summary)
+
+	err = ioutil.WriteFile(profileSummaryPath, []byte(summary), 0700)
+	if err != nil {
+		cLog.WithError(err).Error("failed to write profile summary")
+	}
+
+	l.Println(cp.colorText + "profiles written to " + cp.colorPrompt + profileCPUPath + cp.colorText + " and " + cp.colorPrompt + profileMemPath + cp.colorText)
+
+	selfProfile = nil
+}
+
+// create the human readable summary
+func (p *profiler) summary() string {
+
+	var (
+		s     bytes.Buffer
+		total = time.Now().Sub(p.start)
+		m     runtime.MemStats
+	)
+
+	runtime.ReadMemStats(&m)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	s.WriteString("self profile:\n")
+	for _, name := range p.phases {
+		d := p.durations[name]
+		s.WriteString("├~» " + pad(name, 20) + pad(d.String(), 16) + strconv.FormatFloat(float64(d)/float64(total)*100, 'f', 1, 64) + "%\n")
+	}
+	s.WriteString("├~» " + pad("total", 20) + total.String() + "\n")
+	s.WriteString("├~» " + pad("heap in use", 20) + formatBytes(m.HeapInuse) + "\n")
+	s.WriteString("├~» " + pad("total allocated", 20) + formatBytes(m.TotalAlloc) + "\n")
+	s.WriteString("├~» " + pad("allocations", 20) + strconv.FormatUint(m.Mallocs, 10) + "\n")
+	s.WriteString("├~» " + pad("goroutines", 20) + strconv.Itoa(runtime.NumGoroutine()) + "\n")
+	s.WriteString("└~» " + pad("GC runs", 20) + strconv.FormatUint(uint64(m.NumGC), 10) + "\n")
+
+	return s.String()
+}
+
+// format a number of bytes human readable
+func formatBytes(b uint64) string {
+	switch {
+	case b >= 1<<30:
+		return strconv.FormatFloat(float64(b)/(1<<30), 'f', 2, 64) + " GB"
+	case b >= 1<<20:
+		return strconv.FormatFloat(float64(b)/(1<<20), 'f', 2, 64) + " MB"
+	case b >= 1<<10:
+		return strconv.FormatFloat(float64(b)/(1<<10), 'f', 2, 64) + " KB"
+	default:
+		return strconv.FormatUint(b, 10) + " B"
+	}
+}
@@ -0,0 +1,68 @@
+//go:build darwin
+// +build darwin
+
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// get the name of the slave device, not defined in the syscall package
+const ioctlTIOCPTYGNAME = 0x40807453
+
+// open a new pseudo terminal
+// the file descriptors are not switched to blocking mode, so closing the master interrupts reads
+func openPTY() (master, slave *os.File, err error) {
+
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var name [128]byte
+
+	err = ioctl(master, syscall.TIOCPTYGRANT, 0)
+	if err == nil {
+		err = ioctl(master, syscall.TIOCPTYUNLK, 0)
+	}
+	if err == nil {
+		err = ioctl(master, ioctlTIOCPTYGNAME, uintptr(unsafe.Pointer(&name[0])))
+	}
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	if i := bytes.IndexByte(name[:], 0); i >= 0 {
+		slave, err = os.OpenFile(string(name[:i]), os.O_RDWR|syscall.O_NOCTTY, 0)
+	} else {
+		err = ErrPTYUnsupported
+	}
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	return master, slave, nil
+}
@@ -0,0 +1,39 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"os/exec"
+)
+
+// ptySession runs a command on a pseudo terminal
+type ptySession struct{}
+
+// pseudo terminals are only allocated on linux and macOS
+func newPTYSession(cmd *exec.Cmd, out io.Writer, foreground bool) (*ptySession, error) {
+	return nil, ErrPTYUnsupported
+}
+
+func (s *ptySession) started() {}
+
+func (s *ptySession) close() {}
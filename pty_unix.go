@@ -0,0 +1,193 @@
+//go:build linux || darwin
+// +build linux darwin
+
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/chzyer/readline"
+)
+
+// time to wait for the remaining output after the command exited
+// processes started in the background by the command can keep the pseudo terminal open
+const ptyDrainTimeout = time.Second
+
+// ptySession runs a command on a pseudo terminal
+type ptySession struct {
+	master *os.File
+	slave  *os.File
+
+	// terminal of the user for foreground runs
+	tty   *os.File
+	state *readline.State
+
+	resize chan os.Signal
+
+	// closed when the output was copied
+	drained chan struct{}
+}
+
+// window size as used by TIOCGWINSZ and TIOCSWINSZ
+type winsize struct {
+	rows, cols, x, y uint16
+}
+
+// issue an ioctl without switching the file to blocking mode
+func ioctl(f *os.File, req, arg uintptr) error {
+
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// copy the window size of the terminal from to the pseudo terminal to
+func copyWindowSize(from, to *os.File) error {
+	var ws winsize
+	err := ioctl(from, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	if err != nil {
+		return err
+	}
+	return ioctl(to, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
+
+// prepare cmd to run on a new pseudo terminal, its output is copied to out
+// foreground runs are connected to the terminal of the user, which is switched to raw mode
+// and its window size changes are passed on
+func newPTYSession(cmd *exec.Cmd, out io.Writer, foreground bool) (*ptySession, error) {
+
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ptySession{
+		master: master,
+		slave:  slave,
+	}
+
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+
+	// new session with the pseudo terminal as controlling terminal
+	// the session leader is also the leader of the process group, so signalProcessGroup works as usual
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid:  true,
+		Setctty: true,
+		Ctty:    0,
+	}
+
+	if foreground && readline.IsTerminal(int(os.Stdin.Fd())) {
+
+		s.tty, err = os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+
+		if err := copyWindowSize(s.tty, master); err != nil {
+			Log.WithError(err).Debug("failed to set the window size of the pseudo terminal")
+		}
+
+		s.state, err = readline.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+
+		s.resize = make(chan os.Signal, 1)
+		signal.Notify(s.resize, syscall.SIGWINCH)
+		go func() {
+			for range s.resize {
+				copyWindowSize(s.tty, master)
+			}
+		}()
+
+		// ends when the terminal is closed
+		go io.Copy(master, s.tty)
+
+	} else if !readline.IsTerminal(int(os.Stdout.Fd())) || copyWindowSize(os.Stdout, master) != nil {
+
+		// without a terminal the common default size is used, curses interfaces need one
+		ws := winsize{rows: 24, cols: 80}
+		ioctl(master, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+	}
+
+	s.drained = make(chan struct{})
+	go func() {
+		// returns an error once the last process using the pseudo terminal exited
+		io.Copy(out, master)
+		close(s.drained)
+	}()
+
+	return s, nil
+}
+
+// release the parents handle of the slave after the command was started
+// the output copy ends when the command closed it as well
+func (s *ptySession) started() {
+	s.slave.Close()
+}
+
+// wait for the remaining output and give the terminal back to zeus
+func (s *ptySession) close() {
+
+	s.slave.Close()
+
+	if s.drained != nil {
+		select {
+		case <-s.drained:
+		case <-time.After(ptyDrainTimeout):
+		}
+	}
+	s.master.Close()
+
+	if s.resize != nil {
+		signal.Stop(s.resize)
+		close(s.resize)
+	}
+	if s.state != nil {
+		readline.Restore(int(os.Stdin.Fd()), s.state)
+	}
+	if s.tty != nil {
+		s.tty.Close()
+	}
+}
@@ -0,0 +1,366 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mgutz/ansi"
+)
+
+var (
+	// ErrRemovedFromQueue means a queued run was removed before it started
+	ErrRemovedFromQueue = errors.New("removed from the queue")
+
+	// ErrQueuedRunPanicked means a queued run crashed, the crash was reported and the queue keeps working
+	ErrQueuedRunPanicked = errors.New("queued run crashed")
+)
+
+// priorities of the triggered runs, higher priorities run first
+// clients of the daemon are waiting for the result, events are not
+const (
+	queuePriorityEvent  = 0
+	queuePriorityDaemon = 10
+)
+
+// sources of the triggered runs
+const (
+	queueSourceEvent  = "event"
+	queueSourceDaemon = "daemon"
+)
+
+// number of completed entries kept for the queue builtin
+const queueHistorySize = 20
+
+// states of a queue entry
+const (
+	queuePending = "pending"
+	queueRunning = "running"
+	queueDone    = "done"
+	queueFailed  = "failed"
+	queueRemoved = "removed"
+)
+
+// queueEntry is a triggered run waiting in the queue
+type queueEntry struct {
+	id       int
+	source   string
+	chain    string
+	priority int
+	state    string
+	err      error
+
+	queued   time.Time
+	started  time.Time
+	finished time.Time
+
+	run  func() error
+	done chan struct{}
+}
+
+// runQueue executes triggered runs one at a time, ordered by priority
+type runQueue struct {
+	pending   []*queueEntry
+	running   *queueEntry
+	completed []*queueEntry
+	nextID    int
+
+	wakeup chan struct{}
+	start  sync.Once
+
+	sync.Mutex
+}
+
+var runs = &runQueue{
+	wakeup: make(chan struct{}, 1),
+}
+
+// add a run to the queue
+// events firing again for a chain that is still pending are not queued twice,
+// the pending entry is returned instead
+func (q *runQueue) enqueue(source, chain string, priority int, run func() error) *queueEntry {
+
+	q.start.Do(func() {
+		go q.work()
+	})
+
+	q.Lock()
+	defer q.Unlock()
+
+	for _, e := range q.pending {
+		if source == queueSourceEvent && e.source == source && e.chain == chain {
+			if priority > e.priority {
+				e.priority = priority
+				q.sort()
+			}
+			return e
+		}
+	}
+
+	q.nextID++
+	e := &queueEntry{
+		id:       q.nextID,
+		source:   source,
+		chain:    chain,
+		priority: priority,
+		state:    queuePending,
+		queued:   time.Now(),
+		run:      run,
+		done:     make(chan struct{}),
+	}
+	q.pending = append(q.pending, e)
+	q.sort()
+
+	// queued runs keep the session alive until they completed
+	beginActivity()
+
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+
+	return e
+}
+
+// order the pending entries by priority, and by the time they were queued
+// must be called with the lock held
+func (q *runQueue) sort() {
+	sort.SliceStable(q.pending, func(i, j int) bool {
+		if q.pending[i].priority != q.pending[j].priority {
+			return q.pending[i].priority > q.pending[j].priority
+		}
+		return q.pending[i].id < q.pending[j].id
+	})
+}
+
+// execute the queued runs until zeus exits
+func (q *runQueue) work() {
+	for {
+		q.Lock()
+		if len(q.pending) == 0 {
+			q.Unlock()
+			<-q.wakeup
+			continue
+		}
+
+		e := q.pending[0]
+		q.pending = q.pending[1:]
+		e.state = queueRunning
+		e.started = time.Now()
+		q.running = e
+		q.Unlock()
+
+		var err error
+		inBackground(func() {
+			err = execute(e)
+		})
+
+		q.Lock()
+		e.err = err
+		e.finished = time.Now()
+		if err != nil {
+			e.state = queueFailed
+		} else {
+			e.state = queueDone
+		}
+		q.running = nil
+		q.complete(e)
+		q.Unlock()
+	}
+}
+
+// run the entry
+// a panic is reported and fails the entry, so the worker keeps running and the waiting callers are released
+func execute(e *queueEntry) (err error) {
+	err = ErrQueuedRunPanicked
+	defer recoverAndContinue()
+	return e.run()
+}
+
+// move the entry to the completed entries and release the waiting callers
+// must be called with the lock held
+func (q *runQueue) complete(e *queueEntry) {
+
+	q.completed = append(q.completed, e)
+	if len(q.completed) > queueHistorySize {
+		q.completed = q.completed[len(q.completed)-queueHistorySize:]
+	}
+
+	close(e.done)
+	endActivity()
+}
+
+// wait until the run finished and return its error
+func (e *queueEntry) wait() error {
+	<-e.done
+	return e.err
+}
+
+// remove a pending entry from the queue
+func (q *runQueue) remove(id int) bool {
+
+	q.Lock()
+	defer q.Unlock()
+
+	for i, e := range q.pending {
+		if e.id == id {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			e.state = queueRemoved
+			e.err = ErrRemovedFromQueue
+			e.finished = time.Now()
+			q.complete(e)
+			return true
+		}
+	}
+
+	return false
+}
+
+// remove all pending entries from the queue
+func (q *runQueue) clear() int {
+
+	q.Lock()
+	defer q.Unlock()
+
+	n := len(q.pending)
+	for _, e := range q.pending {
+		e.state = queueRemoved
+		e.err = ErrRemovedFromQueue
+		e.finished = time.Now()
+		q.complete(e)
+	}
+	q.pending = nil
+
+	return n
+}
+
+// change the priority of a pending entry
+func (q *runQueue) setPriority(id, priority int) bool {
+
+	q.Lock()
+	defer q.Unlock()
+
+	for _, e := range q.pending {
+		if e.id == id {
+			e.priority = priority
+			q.sort()
+			return true
+		}
+	}
+
+	return false
+}
+
+// queue the command chain of an event
+func queueEvent(chain string) {
+	runs.enqueue(queueSourceEvent, chain, queuePriorityEvent, func() error {
+		err := executeCommand(chain)
+
+		// reset counters
+		numCommands = 0
+		currentCommand = 0
+
+		return err
+	})
+}
+
+func printQueueUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: queue [clear] [remove <id>] [priority <id> <priority>]")
+}
+
+// handle queue shell command
+// the output is written to out, clients of the daemon get the queue of the daemon
+func handleQueueCommand(args []string, out *log.Logger) {
+
+	if len(args) == 1 {
+		printQueue(out)
+		return
+	}
+
+	switch {
+	case args[1] == "clear" && len(args) == 2:
+		out.Println("removed " + strconv.Itoa(runs.clear()) + " pending runs")
+
+	case args[1] == "remove" && len(args) == 3:
+		id, err := strconv.Atoi(args[2])
+		if err != nil || !runs.remove(id) {
+			out.Println(ansi.Red + "no pending run with id " + args[2] + ansi.Reset)
+		}
+
+	case args[1] == "priority" && len(args) == 4:
+		id, err := strconv.Atoi(args[2])
+		if err != nil {
+			printQueueUsageErr()
+			return
+		}
+		priority, err := strconv.Atoi(args[3])
+		if err != nil {
+			printQueueUsageErr()
+			return
+		}
+		if !runs.setPriority(id, priority) {
+			out.Println(ansi.Red + "no pending run with id " + args[2] + ansi.Reset)
+		}
+
+	default:
+		printQueueUsageErr()
+	}
+}
+
+// print the pending, running and completed entries of the queue
+func printQueue(out *log.Logger) {
+
+	runs.Lock()
+	defer runs.Unlock()
+
+	if runs.running == nil && len(runs.pending) == 0 && len(runs.completed) == 0 {
+		out.Println("the queue is empty.")
+		return
+	}
+
+	printEntry := func(e *queueEntry, color, info string) {
+		out.Println(color + pad(e.state, 9) + cp.colorText + pad("#"+strconv.Itoa(e.id), 6) + pad(e.source, 8) + pad("prio "+strconv.Itoa(e.priority), 9) + cp.colorCommandName + e.chain + cp.colorText + " " + info + ansi.Reset)
+	}
+
+	for i := len(runs.completed) - 1; i >= 0; i-- {
+		e := runs.completed[i]
+		switch e.state {
+		case queueDone:
+			printEntry(e, ansi.Green, "in "+e.finished.Sub(e.started).Round(time.Millisecond).String())
+		case queueFailed:
+			printEntry(e, ansi.Red, e.err.Error())
+		default:
+			printEntry(e, cp.colorText, "")
+		}
+	}
+
+	if e := runs.running; e != nil {
+		printEntry(e, ansi.Yellow, "since "+time.Since(e.started).Round(time.Second).String())
+	}
+
+	for _, e := range runs.pending {
+		printEntry(e, cp.colorText, "waiting "+time.Since(e.queued).Round(time.Second).String())
+	}
+}
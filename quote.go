@@ -0,0 +1,200 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrUnterminatedQuote means a quoted string in a command line was not closed
+var ErrUnterminatedQuote = errors.New("unterminated quote")
+
+// split a command line into arguments like a POSIX shell
+// single quotes preserve everything, double quotes and backslashes escape whitespace and quotes
+// on windows a backslash only escapes quotes, see escapedByBackslash
+// example:
+// git commit -m 'what the hell' -> ["git", "commit", "-m", "what the hell"]
+func splitCommandLine(line string) ([]string, error) {
+
+	var (
+		args    []string
+		current bytes.Buffer
+		inArg   bool
+		quote   rune
+		escaped bool
+		runes   = []rune(line)
+	)
+
+	for i, r := range runes {
+
+		// the rune after r, 0 at the end of the line
+		var next rune
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && escapedByBackslash(next):
+				escaped = true
+			default:
+				current.WriteRune(r)
+			}
+
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+
+		case r == '\\' && escapedByBackslash(next):
+			escaped = true
+			inArg = true
+
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return args, ErrUnterminatedQuote
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// split a command chain at every separator that is not quoted or escaped
+// the quotes are kept, the parts are split into arguments with splitCommandLine
+// example:
+// build -> git commit -m 'a -> b' -> ["build ", " git commit -m 'a -> b'"]
+func splitCommandChain(line, separator string) ([]string, error) {
+
+	var (
+		parts   []string
+		start   int
+		quote   byte
+		escaped bool
+	)
+
+	for i := 0; i < len(line); i++ {
+
+		b := line[i]
+
+		// the byte after b, 0 at the end of the line
+		var next byte
+		if i+1 < len(line) {
+			next = line[i+1]
+		}
+
+		switch {
+		case escaped:
+			escaped = false
+
+		case quote == '\'':
+			if b == '\'' {
+				quote = 0
+			}
+
+		case b == '\\' && escapedByBackslash(rune(next)):
+			escaped = true
+
+		case quote == '"':
+			if b == '"' {
+				quote = 0
+			}
+
+		case b == '\'' || b == '"':
+			quote = b
+
+		case separator != "" && strings.HasPrefix(line[i:], separator):
+			parts = append(parts, line[start:i])
+			i += len(separator) - 1
+			start = i + 1
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, ErrUnterminatedQuote
+	}
+
+	return append(parts, line[start:]), nil
+}
+
+// quote s for use as a single word in a bash script
+// strings without special characters are returned unchanged
+func shellQuote(s string) string {
+
+	if s == "" {
+		return "''"
+	}
+
+	if strings.IndexFunc(s, isShellSpecial) < 0 {
+		return s
+	}
+
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// quote all args and join them with spaces
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// check if r needs quoting in a shell word
+// letters and digits of all scripts are safe, so unicode paths stay readable
+func isShellSpecial(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case r > 127:
+		return unicode.IsSpace(r)
+	}
+	return !strings.ContainsRune("-_./:,+@%=", r)
+}
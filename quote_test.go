@@ -0,0 +1,156 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+
+	tests := []struct {
+		name string
+		line string
+		want []string
+		err  error
+	}{
+		{"empty", "", nil, nil},
+		{"whitespace only", " \t ", nil, nil},
+		{"words", "git commit -m hi", []string{"git", "commit", "-m", "hi"}, nil},
+		{"repeated whitespace", "  a \t b  ", []string{"a", "b"}, nil},
+		{"single quotes", "git commit -m 'what the hell'", []string{"git", "commit", "-m", "what the hell"}, nil},
+		{"double quotes", `echo "a b"`, []string{"echo", "a b"}, nil},
+		{"empty quotes", `echo '' ""`, []string{"echo", "", ""}, nil},
+		{"quotes inside a word", `a'b c'd`, []string{"ab cd"}, nil},
+		{"double quote in single quotes", `echo 'say "hi"'`, []string{"echo", `say "hi"`}, nil},
+		{"apostrophe in double quotes", `echo "it's"`, []string{"echo", "it's"}, nil},
+		{"unicode", "echo 'grüße welt'", []string{"echo", "grüße welt"}, nil},
+		{"unterminated single quote", "echo 'a", []string{"echo"}, ErrUnterminatedQuote},
+		{"unterminated double quote", `echo "a`, []string{"echo"}, ErrUnterminatedQuote},
+	}
+
+	for _, test := range tests {
+		got, err := splitCommandLine(test.line)
+		if err != test.err {
+			t.Errorf("%s: splitCommandLine(%q) error = %v, want %v", test.name, test.line, err, test.err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: splitCommandLine(%q) = %q, want %q", test.name, test.line, got, test.want)
+		}
+	}
+}
+
+func TestSplitCommandLineBackslash(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		line    string
+		want    []string
+		windows []string
+	}{
+		{"escaped space", `a\ b`, []string{"a b"}, []string{`a\`, "b"}},
+		{"escaped quote", `echo \"hi\"`, []string{"echo", `"hi"`}, []string{"echo", `"hi"`}},
+		{"escaped quote in double quotes", `echo "a \" b"`, []string{"echo", `a " b`}, []string{"echo", `a " b`}},
+		{"windows path", `cd C:\Users\me`, []string{"cd", "C:Usersme"}, []string{"cd", `C:\Users\me`}},
+	}
+
+	for _, test := range tests {
+
+		want := test.want
+		if runtime.GOOS == "windows" {
+			want = test.windows
+		}
+
+		got, err := splitCommandLine(test.line)
+		if err != nil {
+			t.Errorf("%s: splitCommandLine(%q) error = %v", test.name, test.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: splitCommandLine(%q) = %q, want %q", test.name, test.line, got, want)
+		}
+	}
+}
+
+func TestSplitCommandChain(t *testing.T) {
+
+	tests := []struct {
+		name string
+		line string
+		want []string
+		err  error
+	}{
+		{"single command", "build", []string{"build"}, nil},
+		{"chain", "build -> test", []string{"build ", " test"}, nil},
+		{"no spaces", "a->b->c", []string{"a", "b", "c"}, nil},
+		{"single quoted separator", "build -> git commit -m 'a -> b'", []string{"build ", " git commit -m 'a -> b'"}, nil},
+		{"double quoted separator", `echo "a -> b"`, []string{`echo "a -> b"`}, nil},
+		{"unterminated quote", "echo 'a -> b", nil, ErrUnterminatedQuote},
+	}
+
+	for _, test := range tests {
+		got, err := splitCommandChain(test.line, "->")
+		if err != test.err {
+			t.Errorf("%s: splitCommandChain(%q) error = %v, want %v", test.name, test.line, err, test.err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: splitCommandChain(%q) = %q, want %q", test.name, test.line, got, test.want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"empty", "", "''"},
+		{"plain", "hello", "hello"},
+		{"safe punctuation", "a-b_c./d:e,f+g@h%i=j", "a-b_c./d:e,f+g@h%i=j"},
+		{"unicode letters", "grüße", "grüße"},
+		{"space", "a b", "'a b'"},
+		{"unicode space", "a\u00a0b", "'a\u00a0b'"},
+		{"apostrophe", "it's", `'it'\''s'`},
+		{"dollar", "$HOME", "'$HOME'"},
+		{"glob", "*.go", "'*.go'"},
+		{"semicolon", "a;b", "'a;b'"},
+	}
+
+	for _, test := range tests {
+		got := shellQuote(test.s)
+		if got != test.want {
+			t.Errorf("%s: shellQuote(%q) = %q, want %q", test.name, test.s, got, test.want)
+			continue
+		}
+
+		// the quoted word must split back into the original string
+		if runtime.GOOS != "windows" {
+			args, err := splitCommandLine(got)
+			if err != nil || len(args) != 1 || args[0] != test.s {
+				t.Errorf("%s: splitCommandLine(%q) = %q, %v, want [%q]", test.name, got, args, err, test.s)
+			}
+		}
+	}
+}
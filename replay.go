@@ -0,0 +1,168 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownRun means there is no snapshot for the run id
+var ErrUnknownRun = errors.New("no snapshot found for run")
+
+// environment of the run that is replayed, added to the environment of the commands
+// later entries override the earlier ones, so the recorded values win
+var replayEnv []string
+
+// find and read the snapshot of the run with id
+func loadSnapshot(id string) (*runSnapshot, error) {
+
+	files, err := filepath.Glob(filepath.Join(snapshotsDir, "*", id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrUnknownRun
+	}
+
+	b, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var s runSnapshot
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// get the recorded environment for replaying a run
+// secrets are masked in the snapshot, their current values are used instead
+func (s *runSnapshot) environment() []string {
+
+	var names []string
+	for name := range s.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var env []string
+	for _, name := range names {
+
+		value := s.Env[name]
+
+		// every run has its own artifact directory
+		if name == artifactsVar {
+			continue
+		}
+
+		if strings.Contains(value, secretMask) {
+			current, ok := os.LookupEnv(name)
+			if !ok {
+				Log.Warn("the secret " + name + " of the replayed run is not set")
+				continue
+			}
+			value = current
+		}
+
+		env = append(env, name+"="+value)
+	}
+
+	return env
+}
+
+// print what changed since the run, the replay can behave differently because of it
+func (s *runSnapshot) warnChanges() {
+
+	if rev := gitRevision(workingDir); s.GitRevision != "" && rev != s.GitRevision {
+		Log.Warn("the run was executed at revision " + s.GitRevision + ", the current revision is " + rev)
+	}
+	if len(s.GitChanges) > 0 {
+		Log.Warn("the run was executed with uncommitted changes: " + strings.Join(s.GitChanges, ", "))
+	}
+	if s.ZeusVersion != version {
+		Log.Warn("the run was executed with zeus " + s.ZeusVersion + ", this is zeus " + version)
+	}
+
+	var tools []string
+	for tool := range s.Tools {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+
+	for _, tool := range tools {
+		if v := toolVersion(tool); v != s.Tools[tool] {
+			Log.Warn(tool + " changed since the run: " + s.Tools[tool] + " -> " + v)
+		}
+	}
+}
+
+// handle replay shell command
+// executes a previous run again with the command, the arguments and the environment of its snapshot
+func handleReplayCommand(args []string) error {
+
+	if len(args) != 2 {
+		printReplayUsageErr()
+		return ErrInvalidUsage
+	}
+
+	s, err := loadSnapshot(args[1])
+	if err != nil {
+		Log.WithError(err).Error("failed to load the snapshot of " + args[1])
+		return err
+	}
+
+	commandMutex.Lock()
+	cmd, ok := commands[s.Command]
+	commandMutex.Unlock()
+	if !ok {
+		Log.WithError(ErrUnknownCommand).Error(s.Command)
+		return ErrUnknownCommand
+	}
+
+	s.warnChanges()
+
+	Log.Info("replaying " + s.Command + " " + strings.Join(s.Args, " ") + " from " + s.Time.Format("2006-01-02 15:04:05"))
+
+	replayEnv = s.environment()
+	defer func() {
+		replayEnv = nil
+	}()
+
+	numCommands = getTotalCommandCount(cmd)
+	defer func() {
+		numCommands = 0
+		currentCommand = 0
+	}()
+
+	return cmd.Run(s.Args)
+}
+
+func printReplayUsageErr() {
+	l.Println(ErrInvalidUsage)
+	l.Println("usage: replay <run>")
+}
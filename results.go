@@ -0,0 +1,365 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mgutz/ansi"
+)
+
+// ErrUnknownResultFormat means the results header field names an unsupported format
+var ErrUnknownResultFormat = errors.New("unknown test result format, expected: go-json, tap or pytest")
+
+// supported test result formats
+const (
+	resultFormatGoJSON = "go-json"
+	resultFormatTAP    = "tap"
+	resultFormatPytest = "pytest"
+)
+
+// number of slowest tests in the summary
+const slowestTests = 5
+
+// test states
+const (
+	testPassed  = "pass"
+	testFailed  = "fail"
+	testSkipped = "skip"
+)
+
+var (
+	// TAP test lines: ok 1 - name # SKIP reason
+	tapLine = regexp.MustCompile(`^(not ok|ok)\b\s*\d*\s*-?\s*(.*?)(\s+#\s*(\w+).*)?$`)
+
+	// verbose pytest lines: tests/test_app.py::test_name PASSED [ 50%]
+	pytestLine = regexp.MustCompile(`^(\S+::\S+)\s+(PASSED|FAILED|SKIPPED|ERROR|XFAIL|XPASS)\b`)
+)
+
+// testResult is the outcome of a single test
+type testResult struct {
+	suite    string
+	name     string
+	state    string
+	duration time.Duration
+	output   string
+}
+
+// check if the result format is supported
+func validResultFormat(format string) error {
+	switch format {
+	case resultFormatGoJSON, resultFormatTAP, resultFormatPytest:
+		return nil
+	}
+	return ErrUnknownResultFormat
+}
+
+// parse the output of a test command in the given format
+func parseTestResults(format string, output []byte) ([]*testResult, error) {
+	switch format {
+	case resultFormatGoJSON:
+		return parseGoTestJSON(output), nil
+	case resultFormatTAP:
+		return parseTAP(output), nil
+	case resultFormatPytest:
+		return parsePytest(output), nil
+	}
+	return nil, ErrUnknownResultFormat
+}
+
+// parse the output of go test -json
+func parseGoTestJSON(output []byte) (results []*testResult) {
+
+	var (
+		s   = bufio.NewScanner(bytes.NewReader(output))
+		out = make(map[string]*bytes.Buffer)
+	)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for s.Scan() {
+
+		var e struct {
+			Action  string
+			Package string
+			Test    string
+			Elapsed float64
+			Output  string
+		}
+		if json.Unmarshal(s.Bytes(), &e) != nil || e.Test == "" {
+			continue
+		}
+
+		key := e.Package + "." + e.Test
+		switch e.Action {
+		case "output":
+			if out[key] == nil {
+				out[key] = new(bytes.Buffer)
+			}
+			out[key].WriteString(e.Output)
+		case "pass", "fail", "skip":
+			r := &testResult{
+				suite:    e.Package,
+				name:     e.Test,
+				state:    e.Action,
+				duration: time.Duration(e.Elapsed * float64(time.Second)),
+			}
+			if b, ok := out[key]; ok {
+				r.output = b.String()
+			}
+			results = append(results, r)
+		}
+	}
+
+	return
+}
+
+// parse the output of a TAP producer
+func parseTAP(output []byte) (results []*testResult) {
+
+	s := bufio.NewScanner(bytes.NewReader(output))
+	for s.Scan() {
+
+		m := tapLine.FindStringSubmatch(strings.TrimSpace(s.Text()))
+		if m == nil {
+			continue
+		}
+
+		r := &testResult{
+			name:  m[2],
+			state: testPassed,
+		}
+		switch {
+		case strings.EqualFold(m[4], "skip"):
+			r.state = testSkipped
+		case strings.EqualFold(m[4], "todo"):
+			// failing todo tests do not fail the suite
+		case m[1] == "not ok":
+			r.state = testFailed
+		}
+		results = append(results, r)
+	}
+
+	return
+}
+
+// parse the verbose output of pytest
+func parsePytest(output []byte) (results []*testResult) {
+
+	s := bufio.NewScanner(bytes.NewReader(output))
+	for s.Scan() {
+
+		m := pytestLine.FindStringSubmatch(s.Text())
+		if m == nil {
+			continue
+		}
+
+		var (
+			parts = strings.SplitN(m[1], "::", 2)
+			r     = &testResult{suite: parts[0], name: parts[1], state: testPassed}
+		)
+		switch m[2] {
+		case "FAILED", "ERROR", "XPASS":
+			r.state = testFailed
+		case "SKIPPED", "XFAIL":
+			r.state = testSkipped
+		}
+		results = append(results, r)
+	}
+
+	return
+}
+
+// print the number of passed, failed and skipped tests, the failures and the slowest tests
+func printTestSummary(results []*testResult) {
+
+	var (
+		counts  = make(map[string]int)
+		failed  []*testResult
+		slowest = make([]*testResult, len(results))
+	)
+
+	for _, r := range results {
+		counts[r.state]++
+		if r.state == testFailed {
+			failed = append(failed, r)
+		}
+	}
+
+	color := ansi.Green
+	if len(failed) > 0 {
+		color = ansi.Red
+	}
+	l.Println(color + strconv.Itoa(counts[testPassed]) + " passed, " + strconv.Itoa(counts[testFailed]) + " failed, " + strconv.Itoa(counts[testSkipped]) + " skipped" + ansi.Reset)
+
+	for _, r := range failed {
+		l.Println(ansi.Red + "  FAIL " + testName(r) + ansi.Reset)
+	}
+
+	copy(slowest, results)
+	sort.SliceStable(slowest, func(i, j int) bool {
+		return slowest[i].duration > slowest[j].duration
+	})
+
+	for i, r := range slowest {
+		if i == slowestTests || r.duration == 0 {
+			break
+		}
+		if i == 0 {
+			l.Println(cp.colorText + "slowest:" + ansi.Reset)
+		}
+		l.Println(cp.colorText + "  " + pad(r.duration.String(), 14) + testName(r) + ansi.Reset)
+	}
+}
+
+// name including the suite
+func testName(r *testResult) string {
+	if r.suite == "" {
+		return r.name
+	}
+	return r.suite + " " + r.name
+}
+
+// JUnit XML elements
+type (
+	junitSuites struct {
+		XMLName xml.Name     `xml:"testsuites"`
+		Suites  []junitSuite `xml:"testsuite"`
+	}
+	junitSuite struct {
+		Name     string      `xml:"name,attr"`
+		Tests    int         `xml:"tests,attr"`
+		Failures int         `xml:"failures,attr"`
+		Skipped  int         `xml:"skipped,attr"`
+		Time     string      `xml:"time,attr"`
+		Cases    []junitCase `xml:"testcase"`
+	}
+	junitCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitMessage `xml:"failure,omitempty"`
+		Skipped   *junitMessage `xml:"skipped,omitempty"`
+	}
+	junitMessage struct {
+		Message string `xml:"message,attr,omitempty"`
+		Body    string `xml:",chardata"`
+	}
+)
+
+// write the results as JUnit XML into the configured directory
+func writeJUnit(name string, results []*testResult) (string, error) {
+
+	var (
+		suites  = make(map[string]*junitSuite)
+		names   []string
+		seconds = func(d time.Duration) string {
+			return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+		}
+		durations = make(map[string]time.Duration)
+	)
+
+	for _, r := range results {
+
+		suiteName := r.suite
+		if suiteName == "" {
+			suiteName = name
+		}
+
+		s, ok := suites[suiteName]
+		if !ok {
+			s = &junitSuite{Name: suiteName}
+			suites[suiteName] = s
+			names = append(names, suiteName)
+		}
+
+		c := junitCase{
+			Name:      r.name,
+			ClassName: suiteName,
+			Time:      seconds(r.duration),
+		}
+		switch r.state {
+		case testFailed:
+			s.Failures++
+			c.Failure = &junitMessage{Message: "failed", Body: r.output}
+		case testSkipped:
+			s.Skipped++
+			c.Skipped = &junitMessage{}
+		}
+
+		s.Tests++
+		s.Cases = append(s.Cases, c)
+		durations[suiteName] += r.duration
+	}
+
+	var doc junitSuites
+	for _, n := range names {
+		suites[n].Time = seconds(durations[n])
+		doc.Suites = append(doc.Suites, *suites[n])
+	}
+
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(conf.JUnitDir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(conf.JUnitDir, name+".xml")
+	return path, writeFileAtomic(path, append([]byte(xml.Header), append(b, '\n')...), 0644)
+}
+
+// parse the captured output of a command, print the summary and write the JUnit report
+func (c *command) reportTestResults(output []byte) {
+
+	results, err := parseTestResults(c.results, output)
+	if err != nil {
+		Log.WithError(err).Error("failed to parse test results of " + c.name)
+		return
+	}
+
+	if len(results) == 0 {
+		Log.Warn("no " + c.results + " test results found in the output of " + c.name)
+		return
+	}
+
+	printTestSummary(results)
+
+	if conf.JUnitDir != "" {
+		path, err := writeJUnit(c.name, results)
+		if err != nil {
+			Log.WithError(err).Error("failed to write JUnit report")
+			return
+		}
+		l.Println(cp.colorText + "JUnit report written to " + path + ansi.Reset)
+	}
+}
@@ -0,0 +1,401 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ansistrip "c0de/ansistrip"
+	"github.com/mgutz/ansi"
+)
+
+const (
+	// every run of a command gets its own directory for artifacts
+	artifactsDir = "zeus/artifacts"
+
+	// per command logs when RunLogs is enabled
+	runLogsDir = "zeus/logs"
+
+	// name of the artifact directory and the log file of a run
+	// sorts by time and contains no characters that are invalid in windows paths
+	runIDFormat = "20060102-150405.000"
+
+	// exposes the absolute path of the artifact directory to the commands
+	artifactsVar = "ZEUS_ARTIFACTS"
+)
+
+// ErrInvalidRetention means the retention config fields could not be parsed
+var ErrInvalidRetention = errors.New("invalid retention policy")
+
+// runOutput is the artifact directory and the log of a single run
+type runOutput struct {
+	id        string
+	artifacts string
+	log       *os.File
+
+	// writers in front of the log, the incomplete lines are flushed on close
+	logWriters []*lineMaskingWriter
+}
+
+// retainedRun is an artifact directory or a log file of a previous run
+type retainedRun struct {
+	path    string
+	command string
+	time    time.Time
+	size    int64
+}
+
+// retentionPolicy decides which runs are kept
+// zero values disable the corresponding rule
+type retentionPolicy struct {
+	runs    int
+	maxSize int64
+	maxAge  time.Duration
+}
+
+// commands can contain slashes and colons, the directory names must not
+func runDirName(command string) string {
+	return strings.NewReplacer("/", "_", ":", "_", `\`, "_").Replace(command)
+}
+
+// create the artifact directory and the log file for a run of command
+// nothing is created when another instance holds the project lock
+func newRunOutput(command string) (*runOutput, error) {
+
+	o := &runOutput{
+		id: time.Now().Format(runIDFormat),
+	}
+	if readOnly {
+		return o, nil
+	}
+
+	id := o.id
+
+	dir, err := filepath.Abs(filepath.Join(artifactsDir, runDirName(command), id))
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	o.artifacts = dir
+
+	if conf.RunLogs {
+		logDir := filepath.Join(runLogsDir, runDirName(command))
+
+		err = os.MkdirAll(logDir, 0700)
+		if err != nil {
+			return nil, err
+		}
+
+		o.log, err = os.OpenFile(filepath.Join(logDir, id+".log"), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+// wrap w to additionally write into the log of the run
+// the log gets the output without colors and with masked secrets
+func (o *runOutput) wrap(w io.Writer) io.Writer {
+	if o.log == nil {
+		return w
+	}
+	lw := newLineMaskingWriter(ansistrip.New(o.log))
+	o.logWriters = append(o.logWriters, lw)
+	return io.MultiWriter(w, lw)
+}
+
+// environment variables for the command
+func (o *runOutput) env() []string {
+	if o.artifacts == "" {
+		return nil
+	}
+	return []string{artifactsVar + "=" + o.artifacts}
+}
+
+// close the log, remove the artifact directory if the command did not write anything into it
+// and apply the retention policy
+func (o *runOutput) close() {
+
+	if o.log != nil {
+		for _, lw := range o.logWriters {
+			lw.Flush()
+		}
+		o.log.Close()
+	}
+
+	if o.artifacts != "" {
+		// fails for directories that are not empty
+		if os.Remove(o.artifacts) == nil {
+			os.Remove(filepath.Dir(o.artifacts))
+		}
+	}
+
+	if readOnly {
+		return
+	}
+
+	_, err := gcRuns(false)
+	if err != nil {
+		Log.WithError(err).Error("failed to apply the retention policy")
+	}
+}
+
+// parse the retention config fields
+func newRetentionPolicy() (*retentionPolicy, error) {
+
+	p := &retentionPolicy{
+		runs: conf.RetainRuns,
+	}
+	if p.runs < 0 {
+		return nil, ErrInvalidRetention
+	}
+
+	if conf.RetainMaxSize != "" {
+		size, err := parseSize(conf.RetainMaxSize)
+		if err != nil || size <= 0 {
+			return nil, ErrInvalidRetention
+		}
+		p.maxSize = size
+	}
+
+	if conf.RetainMaxAge != "" {
+		age, err := parseAge(conf.RetainMaxAge)
+		if err != nil || age <= 0 {
+			return nil, ErrInvalidRetention
+		}
+		p.maxAge = age
+	}
+
+	return p, nil
+}
+
+// parse a duration that can also be specified in days, eg: 7d
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func (p *retentionPolicy) empty() bool {
+	return p.runs == 0 && p.maxSize == 0 && p.maxAge == 0
+}
+
+// collect the artifact directories and the logs of all runs below root
+func collectRuns(root string) ([]retainedRun, error) {
+
+	var runs []retainedRun
+
+	commands, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, c := range commands {
+		if !c.IsDir() {
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(filepath.Join(root, c.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			r := retainedRun{
+				path:    filepath.Join(root, c.Name(), e.Name()),
+				command: c.Name(),
+				time:    e.ModTime(),
+				size:    e.Size(),
+			}
+
+			// the name contains the start of the run, the modification time is only a fallback
+			if t, err := time.ParseInLocation(runIDFormat, strings.TrimSuffix(strings.TrimSuffix(e.Name(), ".log"), ".json"), time.Local); err == nil {
+				r.time = t
+			}
+
+			if e.IsDir() {
+				r.size = dirSize(r.path)
+			}
+
+			runs = append(runs, r)
+		}
+	}
+
+	return runs, nil
+}
+
+// summarize the size of all files below dir
+func dirSize(dir string) (size int64) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return
+}
+
+// select the runs that violate the policy
+// artifacts and logs are handled separately, the size limit applies to each of them
+func (p *retentionPolicy) expired(runs []retainedRun) []retainedRun {
+
+	var (
+		expired []retainedRun
+		kept    []retainedRun
+		cutoff  = time.Now().Add(-p.maxAge)
+		counts  = make(map[string]int)
+	)
+
+	// newest first
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].time.After(runs[j].time)
+	})
+
+	for _, r := range runs {
+		counts[r.command]++
+		if (p.runs > 0 && counts[r.command] > p.runs) || (p.maxAge > 0 && r.time.Before(cutoff)) {
+			expired = append(expired, r)
+			continue
+		}
+		kept = append(kept, r)
+	}
+
+	if p.maxSize > 0 {
+		var total int64
+		for _, r := range kept {
+			total += r.size
+		}
+
+		// delete the oldest runs until the total size fits
+		for i := len(kept) - 1; i >= 0 && total > p.maxSize; i-- {
+			total -= kept[i].size
+			expired = append(expired, kept[i])
+		}
+	}
+
+	return expired
+}
+
+// apply the retention policy to the artifacts and the logs
+// returns the removed runs, or the runs that would be removed for a dry run
+func gcRuns(dryRun bool) ([]retainedRun, error) {
+
+	p, err := newRetentionPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if p.empty() {
+		return nil, nil
+	}
+
+	var removed []retainedRun
+	for _, root := range []string{artifactsDir, runLogsDir, snapshotsDir} {
+
+		runs, err := collectRuns(root)
+		if err != nil {
+			return removed, err
+		}
+
+		for _, r := range p.expired(runs) {
+			if !dryRun {
+				err = os.RemoveAll(r.path)
+				if err != nil {
+					return removed, err
+				}
+
+				// fails if there are runs left
+				os.Remove(filepath.Dir(r.path))
+			}
+			removed = append(removed, r)
+		}
+	}
+
+	return removed, nil
+}
+
+// handle gc shell command
+// applies the retention policy on demand
+func handleGCCommand(args []string) error {
+
+	var dryRun bool
+	for _, a := range args[1:] {
+		if a != "--dry-run" {
+			printGCUsageErr()
+			return ErrInvalidUsage
+		}
+		dryRun = true
+	}
+
+	if readOnly && !dryRun {
+		Log.WithError(ErrReadOnly).Error("not removing any runs")
+		return ErrReadOnly
+	}
+
+	removed, err := gcRuns(dryRun)
+	if err != nil {
+		Log.WithError(err).Error("failed to apply the retention policy")
+		return err
+	}
+
+	if len(removed) == 0 {
+		l.Println(cp.colorText + "nothing to remove" + ansi.Reset)
+		return nil
+	}
+
+	var freed int64
+	for _, r := range removed {
+		freed += r.size
+		l.Println(cp.colorText + pad(r.command, 20) + cp.colorPrompt + pad(r.time.Format("2006-01-02 15:04:05"), 22) + cp.colorText + filepath.ToSlash(r.path) + ansi.Reset)
+	}
+
+	verb := "removed "
+	if dryRun {
+		verb = "would remove "
+	}
+	l.Println(cp.colorText + verb + strconv.Itoa(len(removed)) + " runs, " + formatBytes(uint64(freed)) + ansi.Reset)
+
+	return nil
+}
+
+func printGCUsageErr() {
+	l.Println(ErrInvalidUsage)
+	l.Println("usage: gc [--dry-run]")
+}
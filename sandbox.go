@@ -0,0 +1,202 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrInvalidSandbox means the sandbox header field could not be parsed
+	ErrInvalidSandbox = errors.New("invalid sandbox, expected: inputs=<path,...> outputs=<path,...> network=<true|false>")
+
+	// ErrNoSandbox means no sandbox backend is available on this system
+	ErrNoSandbox = errors.New("no sandbox backend available")
+
+	// directories with the credentials of the users, like ~/.ssh or ~/.aws
+	// they are hidden from sandboxed commands together with the home directory of the current user
+	sandboxHiddenPaths = []string{"/home", "/root", "/Users"}
+)
+
+// sandboxPolicy restricts the filesystem and network access of a command
+type sandboxPolicy struct {
+
+	// paths the command can read, the whole project if empty
+	inputs []string
+
+	// paths the command can write to
+	outputs []string
+
+	// allow network access
+	network bool
+}
+
+// parse a sandbox policy in the form: inputs=src,go.mod outputs=bin network=false
+func parseSandbox(s string) (*sandboxPolicy, error) {
+
+	var p = new(sandboxPolicy)
+
+	for _, field := range strings.Fields(s) {
+
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, ErrInvalidSandbox
+		}
+
+		switch kv[0] {
+		case "inputs":
+			p.inputs = strings.Split(kv[1], ",")
+		case "outputs":
+			p.outputs = strings.Split(kv[1], ",")
+		case "network":
+			switch kv[1] {
+			case "true":
+				p.network = true
+			case "false":
+				p.network = false
+			default:
+				return nil, ErrInvalidSandbox
+			}
+		default:
+			return nil, ErrInvalidSandbox
+		}
+	}
+
+	return p, nil
+}
+
+// get the sandbox policy for a command
+// returns nil if sandboxing is disabled
+// commands without a sandbox header field can read the project, but not write to it or use the network
+func (c *command) sandboxPolicy() (*sandboxPolicy, error) {
+
+	if !conf.Sandbox {
+		return nil, nil
+	}
+
+	return parseSandbox(c.sandbox)
+}
+
+// wrap cmd so it runs inside the sandbox
+// paths of the policy are relative to dir, the script is always readable
+func (p *sandboxPolicy) wrap(cmd *exec.Cmd, dir, script string) (*exec.Cmd, error) {
+
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// resolve symlinks, the backends operate on real paths
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+
+	var (
+		inputs  []string
+		outputs []string
+	)
+
+	for _, in := range p.inputs {
+		inputs = append(inputs, sandboxPath(root, in))
+	}
+	if len(inputs) > 0 {
+		inputs = append(inputs, sandboxPath(root, script))
+	}
+
+	for _, out := range p.outputs {
+
+		// outputs must exist to be mounted writable
+		path := sandboxPath(root, out)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err = os.MkdirAll(path, 0755); err != nil {
+				return nil, err
+			}
+		}
+		outputs = append(outputs, path)
+	}
+
+	args, err := sandboxCommand(&sandboxMounts{
+		root:    root,
+		inputs:  inputs,
+		outputs: outputs,
+		hidden:  sandboxHidden(),
+		network: p.network,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	args = append(append(args, cmd.Path), cmd.Args[1:]...)
+
+	return exec.Command(args[0], args[1:]...), nil
+}
+
+// sandboxMounts are the resolved absolute paths of a sandbox policy
+// the hidden paths are not readable, except for the project, the inputs and the outputs inside of them
+type sandboxMounts struct {
+	root    string
+	inputs  []string
+	outputs []string
+	hidden  []string
+	network bool
+}
+
+// get the existing paths that are hidden from sandboxed commands
+func sandboxHidden() (hidden []string) {
+
+	paths := sandboxHiddenPaths
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append([]string{home}, paths...)
+	}
+
+	var seen = map[string]bool{}
+	for _, path := range paths {
+
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			path = real
+		} else {
+			continue
+		}
+
+		if !seen[path] {
+			seen[path] = true
+			hidden = append(hidden, path)
+		}
+	}
+
+	return
+}
+
+// resolve path relative to the project root
+func sandboxPath(root, path string) string {
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+
+	return filepath.Clean(path)
+}
@@ -0,0 +1,65 @@
+//go:build darwin
+// +build darwin
+
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// get the command prefix for running a command with sandbox-exec
+// everything outside of /tmp and the outputs is read-only,
+// the contents of the hidden paths can only be read inside of the project or the inputs
+func sandboxCommand(m *sandboxMounts) ([]string, error) {
+
+	sandboxExec, err := exec.LookPath("sandbox-exec")
+	if err != nil {
+		return nil, ErrNoSandbox
+	}
+
+	// later rules take precedence
+	profile := "(version 1)(allow default)(deny file-write*)"
+	profile += `(allow file-write* (subpath "/private/tmp") (subpath "/private/var/folders") (literal "/dev/null") (literal "/dev/tty"))`
+
+	for _, h := range m.hidden {
+		profile += "(deny file-read-data (subpath " + strconv.Quote(h) + "))"
+	}
+
+	if len(m.inputs) > 0 {
+		profile += "(deny file-read* (subpath " + strconv.Quote(m.root) + "))"
+		profile += "(allow file-read* (literal " + strconv.Quote(m.root) + ")"
+		for _, in := range m.inputs {
+			profile += " (subpath " + strconv.Quote(in) + ")"
+		}
+		profile += ")"
+	} else {
+		profile += "(allow file-read* (subpath " + strconv.Quote(m.root) + "))"
+	}
+	for _, out := range m.outputs {
+		profile += "(allow file-read* file-write* (subpath " + strconv.Quote(out) + "))"
+	}
+	if !m.network {
+		profile += "(deny network*)"
+	}
+
+	return []string{sandboxExec, "-p", profile}, nil
+}
@@ -0,0 +1,87 @@
+//go:build linux
+// +build linux
+
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os/exec"
+)
+
+// get the command prefix for running a command with bubblewrap or nsjail
+// the system is mounted read-only, with a private /tmp and empty home directories
+// the mounts are applied in order, so the project and the inputs are mounted again inside of the hidden paths
+func sandboxCommand(m *sandboxMounts) ([]string, error) {
+
+	if bwrap, err := exec.LookPath("bwrap"); err == nil {
+
+		args := []string{bwrap, "--die-with-parent", "--ro-bind", "/", "/", "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp"}
+
+		for _, h := range m.hidden {
+			args = append(args, "--tmpfs", h)
+		}
+
+		// hide the project and only expose the inputs
+		if len(m.inputs) > 0 {
+			args = append(args, "--tmpfs", m.root)
+			for _, in := range m.inputs {
+				args = append(args, "--ro-bind", in, in)
+			}
+		} else {
+			args = append(args, "--ro-bind", m.root, m.root)
+		}
+		for _, out := range m.outputs {
+			args = append(args, "--bind", out, out)
+		}
+		if !m.network {
+			args = append(args, "--unshare-net")
+		}
+
+		return append(args, "--chdir", m.root, "--"), nil
+	}
+
+	if nsjail, err := exec.LookPath("nsjail"); err == nil {
+
+		args := []string{nsjail, "-Mo", "--quiet", "--time_limit", "0", "--rlimit_as", "max", "--rlimit_fsize", "max", "--rlimit_nofile", "max", "-R", "/", "-B", "/dev", "-T", "/tmp"}
+
+		for _, h := range m.hidden {
+			args = append(args, "-T", h)
+		}
+
+		if len(m.inputs) > 0 {
+			args = append(args, "-T", m.root)
+			for _, in := range m.inputs {
+				args = append(args, "-R", in)
+			}
+		} else {
+			args = append(args, "-R", m.root)
+		}
+		for _, out := range m.outputs {
+			args = append(args, "-B", out)
+		}
+		if m.network {
+			args = append(args, "--disable_clone_newnet")
+		}
+
+		return append(args, "--cwd", m.root, "--"), nil
+	}
+
+	return nil, ErrNoSandbox
+}
@@ -0,0 +1,57 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSandbox(t *testing.T) {
+
+	tests := []struct {
+		name string
+		s    string
+		want *sandboxPolicy
+		err  error
+	}{
+		{"empty", "", &sandboxPolicy{}, nil},
+		{"inputs", "inputs=src,go.mod", &sandboxPolicy{inputs: []string{"src", "go.mod"}}, nil},
+		{"outputs", "outputs=bin", &sandboxPolicy{outputs: []string{"bin"}}, nil},
+		{"network", "network=true", &sandboxPolicy{network: true}, nil},
+		{"no network", "network=false", &sandboxPolicy{}, nil},
+		{"all fields", "inputs=src outputs=bin,dist network=true", &sandboxPolicy{inputs: []string{"src"}, outputs: []string{"bin", "dist"}, network: true}, nil},
+		{"extra whitespace", "  inputs=src \t outputs=bin  ", &sandboxPolicy{inputs: []string{"src"}, outputs: []string{"bin"}}, nil},
+		{"invalid network", "network=yes", nil, ErrInvalidSandbox},
+		{"missing value", "inputs=", nil, ErrInvalidSandbox},
+		{"missing equals", "inputs", nil, ErrInvalidSandbox},
+		{"unknown field", "writes=bin", nil, ErrInvalidSandbox},
+	}
+
+	for _, test := range tests {
+		got, err := parseSandbox(test.s)
+		if err != test.err {
+			t.Errorf("%s: parseSandbox(%q) error = %v, want %v", test.name, test.s, err, test.err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: parseSandbox(%q) = %+v, want %+v", test.name, test.s, got, test.want)
+		}
+	}
+}
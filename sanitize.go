@@ -45,6 +45,9 @@ func sanitizeFile(path string) {
 		cLog.WithError(err).Fatal("failed to read file")
 	}
 
+	// remove byte order mark and CRLF line endings
+	contents = normalizeScript(contents)
+
 	// split line by line
 	for c, line := range strings.Split(string(contents), "\n") {
 
@@ -0,0 +1,203 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// replacement for secret values
+const secretMask = "***"
+
+// values shorter than this are not masked, they would mask too much unrelated output
+const minSecretLength = 4
+
+// secretMasker redacts secrets from output
+type secretMasker struct {
+
+	// literal values of registered secrets, longest first
+	values []string
+
+	// patterns from the config
+	patterns []*regexp.Regexp
+
+	// set when the secrets must be collected again
+	stale bool
+
+	sync.Mutex
+}
+
+var secrets = &secretMasker{stale: true}
+
+// collect the secrets again on the next use
+// must be called when the globals or the config change
+func invalidateSecrets() {
+	secrets.Lock()
+	secrets.stale = true
+	secrets.Unlock()
+}
+
+// collect the values of globals and environment variables with secret names,
+// and compile the configured patterns
+func (s *secretMasker) collect() {
+
+	s.values = s.values[:0]
+	s.patterns = s.patterns[:0]
+	s.stale = false
+
+	if conf == nil || !conf.MaskSecrets {
+		return
+	}
+
+	add := func(name, value string) {
+		if secretName.MatchString(name) && len(value) >= minSecretLength {
+			s.values = append(s.values, value)
+		}
+	}
+
+	if projectData != nil {
+		for name, g := range projectData.Globals {
+			add(name, g.Value)
+		}
+	}
+
+	for name, value := range generatedGlobals {
+		add(name, value)
+	}
+
+	for _, kv := range runEnviron() {
+		if i := strings.Index(kv, "="); i > 0 {
+			add(kv[:i], kv[i+1:])
+		}
+	}
+
+	// replace longer values first, in case one secret contains another
+	sort.Slice(s.values, func(i, j int) bool {
+		return len(s.values[i]) > len(s.values[j])
+	})
+
+	for _, p := range strings.Fields(conf.SecretPatterns) {
+		r, err := regexp.Compile(p)
+		if err != nil {
+			Log.WithError(err).Error("invalid secret pattern: ", p)
+			continue
+		}
+		s.patterns = append(s.patterns, r)
+	}
+}
+
+// replace all secrets in text with the mask
+func maskSecrets(text string) string {
+
+	secrets.Lock()
+	defer secrets.Unlock()
+
+	if secrets.stale {
+		secrets.collect()
+	}
+
+	for _, v := range secrets.values {
+		text = strings.Replace(text, v, secretMask, -1)
+	}
+	for _, r := range secrets.patterns {
+		text = r.ReplaceAllString(text, secretMask)
+	}
+
+	return text
+}
+
+// maskingWriter redacts secrets before writing to the underlying writer
+// every call to Write must contain complete lines
+type maskingWriter struct {
+	w io.Writer
+}
+
+// Write masks the secrets in b and writes it to the underlying writer
+func (m *maskingWriter) Write(b []byte) (int, error) {
+	_, err := io.WriteString(m.w, maskSecrets(string(b)))
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// lineMaskingWriter passes complete lines to a maskingWriter
+// the output of a process arrives in arbitrary chunks, a secret split across two writes would not be masked
+type lineMaskingWriter struct {
+	w *maskingWriter
+
+	// incomplete line
+	buf []byte
+
+	mutex *sync.Mutex
+}
+
+func newLineMaskingWriter(w io.Writer) *lineMaskingWriter {
+	return &lineMaskingWriter{
+		w:     &maskingWriter{w},
+		mutex: &sync.Mutex{},
+	}
+}
+
+// Write buffers b and writes the complete lines
+func (m *lineMaskingWriter) Write(b []byte) (int, error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.buf = append(m.buf, b...)
+
+	// very long lines are written without waiting for the newline
+	i := bytes.LastIndexByte(m.buf, '\n')
+	if i < 0 && len(m.buf) <= maxRecordedLineLength {
+		return len(b), nil
+	}
+	if i < 0 {
+		i = len(m.buf) - 1
+	}
+
+	_, err := m.w.Write(m.buf[:i+1])
+	m.buf = append(m.buf[:0], m.buf[i+1:]...)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// Flush writes the incomplete line
+func (m *lineMaskingWriter) Flush() error {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.buf) == 0 {
+		return nil
+	}
+
+	_, err := m.w.Write(m.buf)
+	m.buf = nil
+
+	return err
+}
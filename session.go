@@ -0,0 +1,171 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInvalidShutdownAt means the ShutdownAt config field is not a time of day
+var ErrInvalidShutdownAt = errors.New("invalid ShutdownAt, expected a time of day like 23:00")
+
+// interval for checking the session limits again while runs are active
+const activeRunsCheckInterval = time.Second
+
+var (
+	// unix time in nanoseconds of the last start or end of a run
+	lastActivity int64
+
+	// number of runs that are executing or waiting in the run queue
+	// the session never expires while there are active runs
+	activeRuns int64
+)
+
+// remember that a command was executed, resets the idle timer of the session
+func markActivity() {
+	atomic.StoreInt64(&lastActivity, time.Now().UnixNano())
+}
+
+// remember that a run started or was queued, keeps the session alive until endActivity is called
+func beginActivity() {
+	atomic.AddInt64(&activeRuns, 1)
+	markActivity()
+}
+
+// remember that a run ended, the idle timer starts again
+func endActivity() {
+	markActivity()
+	atomic.AddInt64(&activeRuns, -1)
+}
+
+// sessionLimits stop the watch mode and the daemon when they are forgotten
+// after being idle for too long or at a fixed time of day
+type sessionLimits struct {
+
+	// stop after no command was executed for this duration
+	idle time.Duration
+
+	// stop at this point in time
+	stopAt time.Time
+}
+
+// create the session limits from the config
+// returns nil if there are no limits configured
+func newSessionLimits() (*sessionLimits, error) {
+
+	var s = new(sessionLimits)
+
+	if conf.IdleShutdown != "" {
+		idle, err := parseAge(conf.IdleShutdown)
+		if err != nil {
+			return nil, err
+		}
+		s.idle = idle
+	}
+
+	if conf.ShutdownAt != "" {
+		stopAt, err := nextTimeOfDay(conf.ShutdownAt, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		s.stopAt = stopAt
+	}
+
+	if s.idle == 0 && s.stopAt.IsZero() {
+		return nil, nil
+	}
+
+	// the session itself counts as activity
+	markActivity()
+
+	return s, nil
+}
+
+// next occurrence of a time of day in the form 15:04 after now
+func nextTimeOfDay(s string, now time.Time) (time.Time, error) {
+
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return time.Time{}, ErrInvalidShutdownAt
+	}
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next, nil
+}
+
+// time at which the session has to be stopped
+func (s *sessionLimits) deadline() time.Time {
+
+	var d time.Time
+
+	// check again later, the session does not end during a run
+	if atomic.LoadInt64(&activeRuns) > 0 {
+		return time.Now().Add(activeRunsCheckInterval)
+	}
+
+	if s.idle > 0 {
+		d = time.Unix(0, atomic.LoadInt64(&lastActivity)).Add(s.idle)
+	}
+	if !s.stopAt.IsZero() && (d.IsZero() || s.stopAt.Before(d)) {
+		d = s.stopAt
+	}
+
+	return d
+}
+
+// reason to stop the session now, empty if it can continue
+func (s *sessionLimits) expired() string {
+
+	if s == nil {
+		return ""
+	}
+
+	// never stop in the middle of a run, the limits are checked again when it ended
+	if atomic.LoadInt64(&activeRuns) > 0 {
+		return ""
+	}
+
+	now := time.Now()
+
+	if !s.stopAt.IsZero() && !now.Before(s.stopAt) {
+		return "reached ShutdownAt " + conf.ShutdownAt
+	}
+	if s.idle > 0 && !now.Before(time.Unix(0, atomic.LoadInt64(&lastActivity)).Add(s.idle)) {
+		return "idle for " + s.idle.String()
+	}
+
+	return ""
+}
+
+// fires when the deadline of the session is reached
+// nil if there are no limits, receiving from it blocks forever
+func (s *sessionLimits) timer() <-chan time.Time {
+
+	if s == nil {
+		return nil
+	}
+
+	return time.After(time.Until(s.deadline()))
+}
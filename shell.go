@@ -22,7 +22,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -37,6 +36,9 @@ var (
 
 	// global readline instance
 	rl *readline.Instance
+
+	// path for the interactive shell history
+	historyFilePath = "zeus/zeus_history"
 )
 
 // readline loop for interactive mode
@@ -55,14 +57,14 @@ func readlineLoop() error {
 		err             error
 	)
 
-	if conf.HistoryFile {
-		historyFileName = workingDir + "/zeus/zeus_history"
+	if conf.HistoryFile && !inspectMode {
+		historyFileName = workingDir + "/" + historyFilePath
 	}
 
 	// prepare readline
 	rl, err = readline.NewEx(&readline.Config{
 		Prompt:          printPrompt(),
-		AutoComplete:    completer,
+		AutoComplete:    commandIndex,
 		HistoryLimit:    conf.HistoryLimit,
 		HistoryFile:     historyFileName,
 		Listener:        listener,
@@ -86,8 +88,7 @@ func readlineLoop() error {
 			if err == readline.ErrInterrupt {
 
 				if conf.ExitOnInterrupt {
-					clearProcessMap()
-					os.Exit(0)
+					shutdown(0)
 				} else {
 					Log.Info("ExitOnInterrupt is disabled, type 'exit' if you want to leave.")
 					continue
@@ -104,6 +105,8 @@ func readlineLoop() error {
 // handle input line read by the readline instance
 func handleLine(line string) {
 
+	defer recoverAndContinue()
+
 	// trim
 	line = strings.TrimSpace(line)
 
@@ -113,8 +116,7 @@ func handleLine(line string) {
 	switch line {
 	case exitCommand:
 		l.Println(cp.colorText + "Bye.")
-		clearProcessMap()
-		os.Exit(0)
+		shutdown(0)
 
 	case helpCommand:
 
@@ -136,15 +138,9 @@ func handleLine(line string) {
 
 	case "zeus": // prevent spawning a new interactive shell
 
-	case globalsCommand:
-		listGlobals()
-
 	case configCommand:
 		printConfiguration()
 
-	case dataCommand:
-		printProjectData()
-
 	case versionCommand:
 		l.Println(version)
 
@@ -160,8 +156,12 @@ func handleLine(line string) {
 
 	default:
 
-		// split the input line
-		args := strings.Fields(line)
+		// split the input line, quotes can be used for arguments containing spaces
+		args, err := splitCommandLine(line)
+		if err != nil {
+			Log.WithError(err).Error("invalid input")
+			return
+		}
 
 		// skip if empty
 		if len(args) == 0 {
@@ -192,8 +192,81 @@ func handleLine(line string) {
 			handleAuthorCommand(args)
 		case keysCommand:
 			handleKeysCommand(args)
+		case bumpCommand:
+			handleBumpCommand(args)
+		case globalsCommand:
+			handleGlobalsCommand(args)
+		case dataCommand:
+			handleDataCommand(args)
+		case workspaceCommand:
+			handleWorkspaceCommand(args)
+		case daemonCommand:
+			handleDaemonCommand(args)
+
+		case outputCommand:
+			handleOutputCommand(args)
+
+		case validateCommand:
+			handleValidateCommand()
+
+		case pinsCommand:
+			handlePinsCommand(args)
+
+		case signaturesCommand:
+			handleSignaturesCommand(args)
+
+		case benchCommand:
+			handleBenchmarkCommand(args)
+
+		case coverageCommand:
+			handleCoverageCommand(args)
+
+		case watchCommand:
+			handleWatchCommand(args)
+
+		case doctorCommand:
+			handleDoctorCommand()
+
+		case queueCommand:
+			handleQueueCommand(args, l)
+
+		case gcCommand:
+			handleGCCommand(args)
+
+		case snapshotCommand:
+			handleSnapshotCommand(args)
+
+		case replayCommand:
+			runMutex.Lock()
+			handleReplayCommand(args)
+			runMutex.Unlock()
+
+		case cleanCommand:
+			runMutex.Lock()
+			handleCleanCommand(args)
+			runMutex.Unlock()
+
+		case busCommand:
+			handleBusCommand(args)
+
+		case usageCommand:
+			handleUsageCommand(args)
+
+		case uiCommand:
+			// readline owns the terminal while the shell is running
+			Log.Info("the dashboard is started from the command line: zeus ui")
 
 		default:
+			// check if its a builtin of a plugin
+			if plugin, ok := pluginBuiltins[commandName]; ok {
+				plugin.runBuiltin(args)
+				return
+			}
+
+			// commands of the shell run in the foreground, one at a time with the queued runs
+			runMutex.Lock()
+			defer runMutex.Unlock()
+
 			// check if its a commandchain
 			if strings.Contains(line, p.separator) {
 				executeCommandChain(line)
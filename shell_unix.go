@@ -0,0 +1,50 @@
+//go:build !windows
+// +build !windows
+
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import "os/exec"
+
+// scripts are started directly, the shebang selects the interpreter
+func checkShell() error {
+	return nil
+}
+
+// create a command that passes args to the shell interpreter
+func shellCommand(args ...string) (*exec.Cmd, error) {
+	return exec.Command(p.interpreter, args...), nil
+}
+
+// create a command that executes the script at path
+// the script is started directly, the shebang selects the interpreter
+func scriptCommand(path string, args ...string) (*exec.Cmd, error) {
+	return exec.Command(path, args...), nil
+}
+
+// a backslash escapes any character of a command line, like in a POSIX shell
+func escapedByBackslash(r rune) bool {
+	return true
+}
+
+// create a command for a program that is not a zeus command
+func systemCommand(name string, args ...string) *exec.Cmd {
+	return exec.Command(name, args...)
+}
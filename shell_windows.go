@@ -0,0 +1,64 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// ErrNoShell means no bash interpreter was found in the PATH
+var ErrNoShell = errors.New("bash not found in PATH, install Git for Windows or enable WSL")
+
+// make sure a bash interpreter is installed
+// there is no PowerShell or cmd backend, zeus scripts are bash scripts
+func checkShell() error {
+	_, err := exec.LookPath("bash")
+	if err != nil {
+		return ErrNoShell
+	}
+	return nil
+}
+
+// windows has no shebang support, zeus scripts are run with the bash interpreter from the PATH
+// Git for Windows and WSL both provide one
+func shellCommand(args ...string) (*exec.Cmd, error) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		return nil, ErrNoShell
+	}
+	return exec.Command(bash, args...), nil
+}
+
+// create a command that executes the script at path with the bash interpreter
+func scriptCommand(path string, args ...string) (*exec.Cmd, error) {
+	return shellCommand(append([]string{path}, args...)...)
+}
+
+// backslashes separate the elements of windows paths, so C:\Users stays intact
+// they only escape quotes in a command line
+func escapedByBackslash(r rune) bool {
+	return r == '"' || r == '\''
+}
+
+// create a command for a program that is not a zeus command
+// cmd.exe is used, so its builtins like dir can be passed to the shell as well
+func systemCommand(name string, args ...string) *exec.Cmd {
+	return exec.Command("cmd", append([]string{"/C", name}, args...)...)
+}
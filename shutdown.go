@@ -0,0 +1,124 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	// set once the shutdown sequence started, it runs only once
+	// calls during the shutdown return immediately instead of waiting for it,
+	// so a fatal error inside of the sequence does not deadlock
+	shuttingDown int32
+
+	// default grace period for running commands to exit on shutdown
+	defaultShutdownTimeout = 5 * time.Second
+
+	// signal sent to running commands on shutdown
+	terminateSignal = syscall.SIGTERM
+)
+
+// stop zeus gracefully and exit with code
+func shutdown(code int) {
+	cleanup()
+	os.Exit(code)
+}
+
+// shutdown sequence, also registered as exit handler for fatal log messages
+// stops the watchers, terminates running commands and persists the project data
+// errors are logged, a fatal log message would run the shutdown sequence again
+func cleanup() {
+
+	if !atomic.CompareAndSwapInt32(&shuttingDown, 0, 1) {
+		return
+	}
+
+	Log.Debug("shutting down")
+
+	// stop all event watchers without removing the events from the project data
+	watches.close()
+
+	terminateProcesses()
+
+	// write incomplete lines
+	flushOutput()
+
+	// flush the profiles when zeus exits before the startup completed
+	stopSelfProfile()
+
+	if projectData != nil {
+		if err := projectData.save(); err != nil {
+			Log.WithError(err).Error("failed to write zeus data")
+		}
+	}
+
+	releaseProjectLock()
+
+	if logFile != nil {
+		logFile.Sync()
+		logFile.Close()
+	}
+}
+
+// send SIGTERM to all running commands and wait for them to exit
+// commands that are still running after the grace period are killed
+func terminateProcesses() {
+
+	processLock.Lock()
+	if len(processMap) == 0 {
+		processLock.Unlock()
+		return
+	}
+
+	for name, p := range processMap {
+		if p != nil {
+			l.Println(printPrompt() + "stopping " + name)
+			err := signalProcessGroup(p, terminateSignal)
+			if err != nil {
+				Log.WithError(err).Debug("failed to signal " + name)
+			}
+		}
+	}
+	processLock.Unlock()
+
+	var timeout = defaultShutdownTimeout
+	if conf != nil && conf.ShutdownTimeout > 0 {
+		timeout = time.Duration(conf.ShutdownTimeout) * time.Second
+	}
+
+	// commands are removed from the processMap once they exited
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+
+		processLock.Lock()
+		n := len(processMap)
+		processLock.Unlock()
+
+		if n == 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	clearProcessMap()
+}
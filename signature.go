@@ -0,0 +1,238 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mgutz/ansi"
+)
+
+var (
+	// ErrInvalidSignature means the signature of the script manifest could not be verified with a trusted key
+	ErrInvalidSignature = errors.New("signature not made by a trusted key")
+
+	// ErrManifestMismatch means the scripts do not match the signed manifest
+	ErrManifestMismatch = errors.New("scripts do not match the signed manifest")
+
+	// ErrUnknownSignatureTool means the configured signature verification is not supported
+	ErrUnknownSignatureTool = errors.New("unknown signature verification, expected: gpg or minisign")
+
+	// checksums of all scripts, signed by the maintainers
+	manifestPath = "zeus/zeus_scripts.sha256"
+)
+
+// path of the detached signature for the manifest
+func signaturePath() string {
+	if conf.SignatureVerification == "minisign" {
+		return manifestPath + ".minisig"
+	}
+	return manifestPath + ".sig"
+}
+
+// create the manifest contents: one line with checksum and path per script, like sha256sum
+func buildManifest() ([]byte, error) {
+
+	scripts, err := projectScripts()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(globalsScriptPath); err == nil {
+		scripts = append(scripts, globalsScriptPath)
+	}
+	for _, pkg := range packages {
+		if len(pkg.globals) > 0 {
+			scripts = append(scripts, pkg.zeusDir+"/globals"+f.fileExtension)
+		}
+	}
+	scripts = append(scripts, discoveredScripts()...)
+	scripts = append(scripts, projectExecutables()...)
+	sort.Strings(scripts)
+
+	var b bytes.Buffer
+	for _, path := range scripts {
+		sum, err := checksumScript(path)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(sum + "  " + path + "\n")
+	}
+
+	return b.Bytes(), nil
+}
+
+// verify the signature of the manifest with the trusted keys
+// and make sure the scripts match the manifest
+func verifySignatures() error {
+
+	manifest, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	switch conf.SignatureVerification {
+	case "gpg":
+		err = verifyGPG()
+	case "minisign":
+		err = verifyMinisign()
+	default:
+		err = ErrUnknownSignatureTool
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := buildManifest()
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(manifest, current) {
+		for _, line := range manifestDiff(manifest, current) {
+			Log.Warn(line)
+		}
+		return ErrManifestMismatch
+	}
+
+	return nil
+}
+
+// list the scripts that differ between the signed and the current manifest
+func manifestDiff(signed, current []byte) (diff []string) {
+
+	parse := func(b []byte) map[string]string {
+		m := make(map[string]string)
+		s := bufio.NewScanner(bytes.NewReader(b))
+		for s.Scan() {
+			fields := strings.Fields(s.Text())
+			if len(fields) == 2 {
+				m[fields[1]] = fields[0]
+			}
+		}
+		return m
+	}
+
+	var (
+		a = parse(signed)
+		b = parse(current)
+	)
+
+	for path, sum := range b {
+		if old, ok := a[path]; !ok {
+			diff = append(diff, "not signed: "+path)
+		} else if old != sum {
+			diff = append(diff, "modified: "+path)
+		}
+	}
+	for path := range a {
+		if _, ok := b[path]; !ok {
+			diff = append(diff, "missing: "+path)
+		}
+	}
+	sort.Strings(diff)
+
+	return
+}
+
+// verify the manifest with gpg
+// the signing key fingerprint must be one of the trusted keys
+func verifyGPG() error {
+
+	out, err := systemCommand("gpg", "--batch", "--status-fd", "1", "--verify", signaturePath(), manifestPath).Output()
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 3 || fields[1] != "VALIDSIG" {
+			continue
+		}
+		for _, key := range strings.Fields(conf.TrustedKeys) {
+			if strings.EqualFold(strings.Replace(key, " ", "", -1), fields[2]) {
+				return nil
+			}
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// verify the manifest with minisign, trying every trusted public key
+func verifyMinisign() error {
+
+	for _, key := range strings.Fields(conf.TrustedKeys) {
+		err := systemCommand("minisign", "-V", "-q", "-P", key, "-m", manifestPath, "-x", signaturePath()).Run()
+		if err == nil {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+func printSignaturesUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: signatures [manifest]")
+}
+
+// handle signatures shell command
+func handleSignaturesCommand(args []string) {
+
+	if len(args) < 2 {
+		err := verifySignatures()
+		if err != nil {
+			Log.WithError(err).Error("signature verification failed")
+			return
+		}
+		l.Println(cp.colorText + "all scripts match the signed manifest" + ansi.Reset)
+		return
+	}
+
+	if len(args) != 2 || args[1] != "manifest" {
+		printSignaturesUsageErr()
+		return
+	}
+
+	if readOnly {
+		Log.WithError(ErrReadOnly).Error("failed to write manifest")
+		return
+	}
+
+	manifest, err := buildManifest()
+	if err != nil {
+		Log.WithError(err).Error("failed to create manifest")
+		return
+	}
+
+	err = writeFileAtomic(manifestPath, manifest, 0644)
+	if err != nil {
+		Log.WithError(err).Error("failed to write manifest")
+		return
+	}
+
+	l.Println(cp.colorText + "wrote " + manifestPath + ", sign it with: " + cp.colorPrompt + "gpg --detach-sign " + manifestPath + cp.colorText + " or " + cp.colorPrompt + "minisign -Sm " + manifestPath + ansi.Reset)
+}
@@ -0,0 +1,52 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManifestDiff(t *testing.T) {
+
+	const signed = "aaa zeus/build.sh\nbbb zeus/test.sh\nccc zeus/globals.sh\n"
+
+	tests := []struct {
+		name    string
+		signed  string
+		current string
+		want    []string
+	}{
+		{"unchanged", signed, signed, nil},
+		{"reordered", signed, "ccc zeus/globals.sh\naaa zeus/build.sh\nbbb zeus/test.sh\n", nil},
+		{"modified", signed, "aaa zeus/build.sh\nxxx zeus/test.sh\nccc zeus/globals.sh\n", []string{"modified: zeus/test.sh"}},
+		{"added", signed, signed + "ddd zeus/deploy.sh\n", []string{"not signed: zeus/deploy.sh"}},
+		{"removed", signed, "aaa zeus/build.sh\nccc zeus/globals.sh\n", []string{"missing: zeus/test.sh"}},
+		{"all changes sorted", signed, "xxx zeus/build.sh\nccc zeus/globals.sh\nddd zeus/deploy.sh\n", []string{"missing: zeus/test.sh", "modified: zeus/build.sh", "not signed: zeus/deploy.sh"}},
+		{"empty signed manifest", "", "aaa zeus/build.sh\n", []string{"not signed: zeus/build.sh"}},
+		{"malformed lines are ignored", signed + "garbage\n\n", signed + "one two three\n", nil},
+		{"crlf", "aaa zeus/build.sh\r\n", "aaa zeus/build.sh\n", nil},
+	}
+
+	for _, test := range tests {
+		if got := manifestDiff([]byte(test.signed), []byte(test.current)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: manifestDiff = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
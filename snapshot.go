@@ -0,0 +1,269 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgutz/ansi"
+)
+
+// snapshots of the execution environment of the runs
+const snapshotsDir = "zeus/snapshots"
+
+// ErrNoSnapshots means there are not enough snapshots of a command to compare
+var ErrNoSnapshots = errors.New("not enough snapshots to compare")
+
+// versions of the tools for the entries of the requires field
+// the tools are executed once per session
+var toolVersions = struct {
+	sync.Mutex
+	values map[string]string
+}{
+	values: make(map[string]string),
+}
+
+// runSnapshot describes the environment a command was executed in
+type runSnapshot struct {
+	Command     string            `json:"command"`
+	Args        []string          `json:"args"`
+	Run         string            `json:"run"`
+	Time        time.Time         `json:"time"`
+	ZeusVersion string            `json:"zeusVersion"`
+	GitRevision string            `json:"gitRevision"`
+	GitChanges  []string          `json:"gitChanges"`
+	OS          string            `json:"os"`
+	Arch        string            `json:"arch"`
+	Directory   string            `json:"directory"`
+	Tools       map[string]string `json:"tools"`
+	Env         map[string]string `json:"env"`
+}
+
+// write the snapshot for a run of c that is about to start
+// secrets in the environment are masked
+func writeSnapshot(c *command, args []string, cmd *exec.Cmd, id string) error {
+
+	dir, err := filepath.Abs(cmd.Dir)
+	if err != nil {
+		return err
+	}
+
+	s := &runSnapshot{
+		Command:     c.name,
+		Args:        args,
+		Run:         id,
+		Time:        time.Now(),
+		ZeusVersion: version,
+		GitRevision: gitRevision(dir),
+		GitChanges:  gitChanges(dir),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		Directory:   dir,
+		Tools:       make(map[string]string),
+		Env:         make(map[string]string),
+	}
+
+	for _, tool := range strings.Fields(c.requires) {
+		s.Tools[tool] = toolVersion(tool)
+	}
+
+	for _, v := range cmd.Env {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) == 2 {
+			s.Env[kv[0]] = maskSecrets(kv[1])
+		}
+	}
+
+	b, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(snapshotsDir, runDirName(c.name), id+".json")
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(b, '\n'), 0600)
+}
+
+// get the modified and untracked files of the git repository at dir
+func gitChanges(dir string) []string {
+
+	cmd := systemCommand("git", "status", "--porcelain")
+	cmd.Dir = dir
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	return splitLines(out)
+}
+
+// get the version of a tool for an entry of the requires field
+// the entry is the name of the tool, optionally followed by the flag that prints the version: go:version
+// tools without a flag are executed with --version
+func toolVersion(entry string) string {
+
+	toolVersions.Lock()
+	defer toolVersions.Unlock()
+
+	if v, ok := toolVersions.values[entry]; ok {
+		return v
+	}
+
+	tool, flag := entry, "--version"
+	if i := strings.Index(entry, ":"); i > 0 {
+		tool, flag = entry[:i], entry[i+1:]
+	}
+
+	v := "unknown"
+	if _, err := exec.LookPath(tool); err != nil {
+		v = "not found"
+	} else if out, err := systemCommand(tool, flag).CombinedOutput(); err == nil {
+		for _, line := range splitLines(out) {
+			if line = strings.TrimSpace(line); line != "" {
+				v = line
+				break
+			}
+		}
+	}
+
+	toolVersions.values[entry] = v
+
+	return v
+}
+
+// list the snapshot files of a command, oldest first
+func commandSnapshots(command string) ([]string, error) {
+
+	files, err := filepath.Glob(filepath.Join(snapshotsDir, runDirName(command), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	// the names start with the time of the run
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// handle snapshot shell command
+// lists the snapshots of a command or compares two of them
+func handleSnapshotCommand(args []string) error {
+
+	if len(args) == 2 {
+		files, err := commandSnapshots(args[1])
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			l.Println(cp.colorText + strings.TrimSuffix(filepath.Base(f), ".json") + ansi.Reset)
+		}
+		return nil
+	}
+
+	if len(args) < 3 || args[1] != "diff" || (len(args) != 3 && len(args) != 5) {
+		printSnapshotUsageErr()
+		return ErrInvalidUsage
+	}
+
+	var (
+		command  = args[2]
+		from, to string
+	)
+
+	if len(args) == 5 {
+		dir := filepath.Join(snapshotsDir, runDirName(command))
+		from, to = filepath.Join(dir, args[3]+".json"), filepath.Join(dir, args[4]+".json")
+	} else {
+		// compare the last two runs
+		files, err := commandSnapshots(command)
+		if err != nil {
+			return err
+		}
+		if len(files) < 2 {
+			Log.WithError(ErrNoSnapshots).Error("enable RunSnapshots and run " + command + " twice")
+			return ErrNoSnapshots
+		}
+		from, to = files[len(files)-2], files[len(files)-1]
+	}
+
+	old, err := comparableSnapshot(from)
+	if err != nil {
+		Log.WithError(err).Error("failed to read snapshot")
+		return err
+	}
+	new, err := comparableSnapshot(to)
+	if err != nil {
+		Log.WithError(err).Error("failed to read snapshot")
+		return err
+	}
+
+	changes := diffHunks(lineDiff(splitLines(old), splitLines(new)))
+	if len(changes) == 0 {
+		l.Println(cp.colorText + "the snapshots do not differ" + ansi.Reset)
+		return nil
+	}
+
+	if conf.ShowDiffs == diffSideBySide {
+		printSideBySideDiff(filepath.ToSlash(from), filepath.ToSlash(to), changes)
+	} else {
+		printUnifiedDiff(filepath.ToSlash(from), filepath.ToSlash(to), changes)
+	}
+
+	return nil
+}
+
+// read the snapshot at path without the fields that differ for every run
+func comparableSnapshot(path string) ([]byte, error) {
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s map[string]interface{}
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return nil, err
+	}
+	delete(s, "run")
+	delete(s, "time")
+
+	return json.MarshalIndent(s, "", "    ")
+}
+
+func printSnapshotUsageErr() {
+	l.Println(ErrInvalidUsage)
+	l.Println("usage: snapshot <command> | snapshot diff <command> [<run> <run>]")
+}
@@ -0,0 +1,184 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUndefinedVariable means a script references a variable that is not defined
+	ErrUndefinedVariable = errors.New("undefined variable")
+
+	// variable references: $name and ${name}
+	// the operator group catches default values like ${name:-value}, these are not undefined
+	variableReference = regexp.MustCompile(`\$(\{)?([a-zA-Z_][a-zA-Z0-9_]*)(:?[-=?+])?`)
+
+	// variable assignments, including export, local, declare and readonly
+	variableAssignment = regexp.MustCompile(`(?:^|[\s;(&|])(?:(?:export|local|declare|readonly|typeset)\s+(?:-[a-zA-Z]+\s+)*)?([a-zA-Z_][a-zA-Z0-9_]*)(?:\[[^\]]*\])?\+?=`)
+
+	// variables defined by loops, read and getopts
+	variableBinding = regexp.MustCompile(`\b(?:for|select|read(?:\s+-[a-zA-Z]+(?:\s+[^-\s]\S*)?)*|getopts\s+\S+)\s+([a-zA-Z_][a-zA-Z0-9_]*)`)
+
+	// variables declared without assignment
+	variableDeclaration = regexp.MustCompile(`\b(?:export|local|declare|readonly|typeset)\s+(?:-[a-zA-Z]+\s+)*([a-zA-Z_][a-zA-Z0-9_ ]*)`)
+
+	// variables set by bash itself
+	shellVariables = map[string]bool{
+		"BASH": true, "BASHPID": true, "BASH_REMATCH": true, "BASH_SOURCE": true, "BASH_VERSION": true,
+		"BASH_LINENO": true, "EUID": true, "FUNCNAME": true, "GROUPS": true, "HOSTNAME": true,
+		"HOSTTYPE": true, "IFS": true, "LINENO": true, "OLDPWD": true, "OPTARG": true, "OPTIND": true,
+		"OSTYPE": true, "PIPESTATUS": true, "PPID": true, "PWD": true, "RANDOM": true, "REPLY": true,
+		"SECONDS": true, "SHELLOPTS": true, "UID": true,
+	}
+)
+
+// check the script for references to variables that are not defined
+// a variable counts as defined, if it is part of the environment,
+// set by bash, or assigned anywhere in the prelude (globals and arguments) or the script
+// references with a default value like ${name:-value} are ignored
+// only the references of the script are reported, with line numbers of the script
+func checkUndefinedVariables(script, prelude string, env []string) error {
+
+	var (
+		defined   = map[string]bool{}
+		undefined = map[string]int{}
+		lines     = stripUnexpanded(strings.Split(script, "\n"))
+	)
+
+	for _, e := range env {
+		if i := strings.Index(e, "="); i > 0 {
+			defined[e[:i]] = true
+		}
+	}
+
+	for _, line := range append(stripUnexpanded(strings.Split(prelude, "\n")), lines...) {
+		for _, m := range variableAssignment.FindAllStringSubmatch(line, -1) {
+			defined[m[1]] = true
+		}
+		for _, m := range variableBinding.FindAllStringSubmatch(line, -1) {
+			defined[m[1]] = true
+		}
+		for _, m := range variableDeclaration.FindAllStringSubmatch(line, -1) {
+			for _, name := range strings.Fields(m[1]) {
+				defined[name] = true
+			}
+		}
+	}
+
+	for i, line := range lines {
+		for _, m := range variableReference.FindAllStringSubmatch(line, -1) {
+
+			name := m[2]
+
+			// ${name:-default} and similar
+			if m[1] == "{" && m[3] != "" {
+				continue
+			}
+
+			if defined[name] || shellVariables[name] {
+				continue
+			}
+
+			if _, ok := undefined[name]; !ok {
+				undefined[name] = i + 1
+			}
+		}
+	}
+
+	if len(undefined) == 0 {
+		return nil
+	}
+
+	var names []string
+	for name := range undefined {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		Log.Error(ErrUndefinedVariable, ": ", name, " (first referenced in line ", strconv.Itoa(undefined[name]), ")")
+	}
+
+	return ErrUndefinedVariable
+}
+
+// remove the comments and single quoted strings of the lines, bash does not expand variables in them
+func stripUnexpanded(lines []string) []string {
+
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines[i] = ""
+			continue
+		}
+		lines[i] = stripSingleQuoted(line)
+	}
+
+	return lines
+}
+
+// remove the single quoted strings of a line
+// apostrophes inside of double quotes and escaped ones do not start a single quoted string
+func stripSingleQuoted(line string) string {
+
+	var (
+		b       strings.Builder
+		quote   byte
+		escaped bool
+	)
+
+	for i := 0; i < len(line); i++ {
+
+		c := line[i]
+
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+			continue
+
+		case escaped:
+			escaped = false
+
+		case c == '\\':
+			escaped = true
+
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+
+		case c == '"':
+			quote = c
+
+		case c == '\'':
+			quote = c
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
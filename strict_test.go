@@ -0,0 +1,98 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCheckUndefinedVariables(t *testing.T) {
+
+	// the undefined variables are logged
+	defer func(out io.Writer) {
+		Log.Out = out
+	}(Log.Out)
+	Log.Out = ioutil.Discard
+
+	tests := []struct {
+		name    string
+		script  string
+		prelude string
+		env     []string
+		want    error
+	}{
+		{"no variables", "echo hi", "", nil, nil},
+		{"assigned", "a=1\necho $a", "", nil, nil},
+		{"assigned later", "f() { echo $a; }\na=1\nf", "", nil, nil},
+		{"braces", "a=1\necho ${a}", "", nil, nil},
+		{"undefined", "echo $a", "", nil, ErrUndefinedVariable},
+		{"undefined in braces", "echo ${a}", "", nil, ErrUndefinedVariable},
+		{"default value", "echo ${a:-x} ${b-y} ${c:=z} ${d:?missing} ${e:+set}", "", nil, nil},
+		{"environment", "echo $HOME", "", []string{"HOME=/root"}, nil},
+		{"shell variable", "echo $RANDOM $PWD $REPLY", "", nil, nil},
+		{"export", "export a=1\necho $a", "", nil, nil},
+		{"local", "f() { local a=1; echo $a; }", "", nil, nil},
+		{"declare without value", "declare -a a b\necho $a $b", "", nil, nil},
+		{"append", "a+=x\necho $a", "", nil, nil},
+		{"array element", "a[0]=x\necho $a", "", nil, nil},
+		{"for loop", "for f in *; do echo $f; done", "", nil, nil},
+		{"read", "read -r line\necho $line", "", nil, nil},
+		{"read with prompt", "read -p 'name: ' name\necho $name", "", nil, nil},
+		{"getopts", "while getopts ab opt; do echo $opt; done", "", nil, nil},
+		{"comment", "# echo $a", "", nil, nil},
+		{"single quotes", "echo '$a'", "", nil, nil},
+		{"apostrophe in double quotes", `echo "it's $a"`, "", nil, ErrUndefinedVariable},
+		{"apostrophe in double quotes defined", "a=1\necho \"it's $a\"", "", nil, nil},
+		{"escaped apostrophe", `echo \'$a\'`, "", nil, ErrUndefinedVariable},
+		{"defined in the prelude", "echo $a", "a=1", nil, nil},
+		{"referenced in the prelude", "echo hi", "echo $a", nil, nil},
+	}
+
+	for _, test := range tests {
+		if err := checkUndefinedVariables(test.script, test.prelude, test.env); err != test.want {
+			t.Errorf("%s: checkUndefinedVariables(%q) = %v, want %v", test.name, test.script, err, test.want)
+		}
+	}
+}
+
+func TestStripSingleQuoted(t *testing.T) {
+
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"no quotes", "echo $a", "echo $a"},
+		{"single quotes", "echo '$a' $b", "echo  $b"},
+		{"two strings", "echo 'a' $b 'c'", "echo  $b "},
+		{"double quotes", `echo "$a"`, `echo "$a"`},
+		{"apostrophe in double quotes", `echo "it's $a"`, `echo "it's $a"`},
+		{"escaped apostrophe", `echo \'$a\'`, `echo \'$a\'`},
+		{"double quotes in single quotes", `echo '"$a"' $b`, "echo  $b"},
+		{"unterminated", "echo 'a $b", "echo "},
+	}
+
+	for _, test := range tests {
+		if got := stripSingleQuoted(test.line); got != test.want {
+			t.Errorf("%s: stripSingleQuoted(%q) = %q, want %q", test.name, test.line, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/chzyer/readline"
+)
+
+var (
+	// serializes the runs of the interactive shell with the runs of the queue and the key bindings
+	runMutex sync.Mutex
+
+	// set while a run is executed that must not read from the terminal
+	// protected by runMutex
+	backgroundRun bool
+
+	// input of the daemon client the current run belongs to
+	// protected by runMutex
+	clientStdin io.Reader
+
+	// environment and working directory of the daemon client the current run belongs to
+	// protected by runMutex
+	clientEnv []string
+	clientDir string
+)
+
+// execute fn as a run that does not get the terminal
+// runs of events and daemon clients are executed while the user types into the prompt,
+// key bindings are executed while readline is still reading from the terminal
+func inBackground(fn func()) {
+
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
+	backgroundRun = true
+	defer func() {
+		backgroundRun = false
+	}()
+
+	fn()
+}
+
+// get the stdin for a command
+// only foreground runs read from the terminal, runs of daemon clients read the input of the client
+// and the other background runs get no input
+func runStdin() io.Reader {
+	if clientStdin != nil {
+		return clientStdin
+	}
+	if backgroundRun {
+		return nil
+	}
+	return commandStdin
+}
+
+// get the environment a command inherits
+// runs of daemon clients inherit the environment of the client instead of the daemon
+func runEnviron() []string {
+	if clientEnv != nil {
+		return clientEnv
+	}
+	return os.Environ()
+}
+
+// save the terminal settings if stdin is a terminal
+// the returned function restores them, because interactive commands like ssh or editors change them
+// and do not always reset them when they crash
+func saveTerminal(stdin io.Reader) func() {
+
+	var fd = int(os.Stdin.Fd())
+	if stdin != os.Stdin || !readline.IsTerminal(fd) {
+		return func() {}
+	}
+
+	state, err := readline.GetState(fd)
+	if err != nil {
+		Log.WithError(err).Debug("failed to save the terminal settings")
+		return func() {}
+	}
+
+	return func() {
+		err := readline.Restore(fd, state)
+		if err != nil {
+			Log.WithError(err).Debug("failed to restore the terminal settings")
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// collect the files of the project that are executed besides the command scripts
+// they must be approved and signed like the scripts
+func projectExecutables() (paths []string) {
+
+	if _, err := os.Stat(configScriptPath); err == nil {
+		paths = append(paths, configScriptPath)
+	}
+
+	return append(paths, projectPlugins()...)
+}
+
+// collect the scripts of the project that are new or changed since they were approved
+func untrustedScripts() (added, changed []string, err error) {
+
+	scripts, err := projectScripts()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := os.Stat(globalsScriptPath); err == nil {
+		scripts = append(scripts, globalsScriptPath)
+	}
+	scripts = append(scripts, discoveredScripts()...)
+	scripts = append(scripts, projectExecutables()...)
+	sort.Strings(scripts)
+
+	for _, path := range scripts {
+
+		ok, err := pins.approved(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			continue
+		}
+
+		pins.RLock()
+		_, known := pins.Scripts[path]
+		pins.RUnlock()
+
+		if known {
+			changed = append(changed, path)
+		} else {
+			added = append(added, path)
+		}
+	}
+
+	return
+}
+
+// check if the command set of the project is trusted to run automatically
+// after a new clone, a pull or an import the new and changed scripts are listed
+// and must be approved once, before events or hooks can run anything
+func trustCommandSet() bool {
+
+	added, changed, err := untrustedScripts()
+	if err != nil {
+		Log.WithError(err).Error("failed to check the project scripts")
+		return false
+	}
+
+	if len(added) == 0 && len(changed) == 0 {
+		return true
+	}
+
+	Log.Warn("the command set changed since it was last approved")
+	for _, path := range added {
+		Log.Warn("  new:     ", path)
+	}
+	for _, path := range changed {
+		Log.Warn("  changed: ", path)
+	}
+
+	if !confirm("approve the command set and allow events, hooks, the project plugins and the config script to run?") {
+		Log.Warn("events, hooks, the project plugins and the config script are disabled, review the scripts and run 'pins approve all' to enable them")
+		return false
+	}
+
+	err = pins.approve(append(added, changed...)...)
+	if err != nil {
+		Log.WithError(err).Error("failed to save the approval")
+	}
+
+	return true
+}
+
+// ask the user a yes or no question
+// returns false if the answer is not yes or there is no terminal to ask on
+func confirm(question string) bool {
+
+	var (
+		answer string
+		err    error
+	)
+
+	question = msg(question) + " " + msg("[y/N]") + " "
+
+	if rl != nil {
+		rl.SetPrompt(question)
+		answer, err = rl.Readline()
+		rl.SetPrompt(printPrompt())
+	} else {
+		if !isatty.IsTerminal(os.Stdin.Fd()) {
+			return false
+		}
+		l.Print(question)
+		answer, err = bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == strings.ToLower(msg("y"))
+}
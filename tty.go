@@ -0,0 +1,59 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrInvalidTTY means the zeus-tty header field is not a boolean
+var ErrInvalidTTY = errors.New("invalid tty option, expected: true or false")
+
+// ErrPTYUnsupported means pseudo terminals can not be allocated on this platform
+var ErrPTYUnsupported = errors.New("pseudo terminals are not supported on this platform")
+
+// terminal handling of a command
+const (
+	// stdin is the terminal for foreground runs, the output is read from pipes
+	ttyInherit = iota
+
+	// the command runs on a pseudo terminal
+	ttyOn
+
+	// the command gets no input and its output is read from pipes
+	ttyOff
+)
+
+// parse the zeus-tty header field
+func parseTTY(s string) (int, error) {
+
+	if s == "" {
+		return ttyInherit, nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return ttyInherit, ErrInvalidTTY
+	}
+	if b {
+		return ttyOn, nil
+	}
+	return ttyOff, nil
+}
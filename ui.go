@@ -0,0 +1,350 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chzyer/readline"
+	"github.com/mgutz/ansi"
+)
+
+// ErrNoTerminal means the dashboard needs an interactive terminal
+var ErrNoTerminal = errors.New("the dashboard requires a terminal")
+
+const (
+	// screen refresh interval
+	uiRefreshInterval = 250 * time.Millisecond
+
+	// maximum width of the left column
+	uiSidebarWidth = 32
+)
+
+// dashboard is the state of the full screen terminal UI
+type dashboard struct {
+
+	// sorted command names
+	names []string
+
+	// index of the selected command
+	cursor int
+
+	// name of the command that is currently executed, empty if idle
+	running string
+
+	// result of the last execution
+	status string
+
+	sync.Mutex
+}
+
+// handle ui command
+// runs the dashboard until the user quits
+func handleUICommand() error {
+
+	if !readline.IsTerminal(int(os.Stdin.Fd())) || !readline.IsTerminal(int(os.Stdout.Fd())) {
+		return ErrNoTerminal
+	}
+
+	state, err := readline.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+
+	// capture all output in the output history instead of printing it
+	commandStdout = newColorWriter(ioutil.Discard, "")
+	commandStderr = newColorWriter(ioutil.Discard, "")
+	commandStdin = nil
+	l.SetOutput(commandStdout)
+	Log.Out = &maskingWriter{commandStderr}
+
+	// alternate screen, hide cursor
+	print("\033[?1049h\033[?25l")
+
+	defer func() {
+		print("\033[?25h\033[?1049l")
+		readline.Restore(int(os.Stdin.Fd()), state)
+
+		commandStdout = oWriter
+		commandStderr = cWriter
+		commandStdin = os.Stdin
+		l.SetOutput(logOutput)
+		Log.Out = &maskingWriter{stderr}
+	}()
+
+	d := &dashboard{}
+	commandMutex.Lock()
+	for name := range commands {
+		d.names = append(d.names, name)
+	}
+	commandMutex.Unlock()
+	sort.Strings(d.names)
+
+	var (
+		keys   = make(chan []byte)
+		ticker = time.NewTicker(uiRefreshInterval)
+	)
+	defer ticker.Stop()
+
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- append([]byte{}, buf[:n]...)
+		}
+	}()
+
+	d.draw()
+
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok || !d.handleKey(key) {
+				return nil
+			}
+		case <-ticker.C:
+		}
+		d.draw()
+	}
+}
+
+// handle a keypress, returns false if the dashboard should be closed
+func (d *dashboard) handleKey(key []byte) bool {
+
+	d.Lock()
+	defer d.Unlock()
+
+	switch {
+	case bytes.Equal(key, []byte("q")):
+		if d.running != "" {
+			d.status = "wait for " + d.running + " to finish, or interrupt it with x"
+			return true
+		}
+		return false
+	case bytes.Equal(key, []byte("k")), bytes.Equal(key, []byte("\033[A")):
+		if d.cursor > 0 {
+			d.cursor--
+		}
+	case bytes.Equal(key, []byte("j")), bytes.Equal(key, []byte("\033[B")):
+		if d.cursor < len(d.names)-1 {
+			d.cursor++
+		}
+	case bytes.Equal(key, []byte("\r")), bytes.Equal(key, []byte("\n")):
+		if d.running == "" && len(d.names) > 0 {
+			d.running = d.names[d.cursor]
+			d.status = ""
+			go d.run(d.running)
+		}
+	case bytes.Equal(key, []byte("x")), bytes.Equal(key, []byte{3}):
+		processLock.Lock()
+		for _, proc := range processMap {
+			signalProcessGroup(proc, os.Interrupt)
+		}
+		processLock.Unlock()
+	}
+
+	return true
+}
+
+// execute the command and record the result
+func (d *dashboard) run(name string) {
+
+	defer recoverAndContinue()
+
+	commandMutex.Lock()
+	cmd, ok := commands[name]
+	commandMutex.Unlock()
+
+	var err = ErrUnknownCommand
+	if ok {
+		numCommands = getTotalCommandCount(cmd)
+		start := time.Now()
+		err = cmd.Run([]string{})
+		if err == nil {
+			d.setStatus(name + " finished in " + time.Since(start).String())
+		}
+	}
+	if err != nil {
+		d.setStatus(name + " failed: " + err.Error())
+	}
+
+	numCommands = 0
+	currentCommand = 0
+
+	d.Lock()
+	d.running = ""
+	d.Unlock()
+}
+
+func (d *dashboard) setStatus(s string) {
+	d.Lock()
+	d.status = s
+	d.Unlock()
+}
+
+// render the dashboard
+func (d *dashboard) draw() {
+
+	d.Lock()
+	defer d.Unlock()
+
+	width, height, err := readline.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 20 || height < 10 {
+		return
+	}
+
+	var (
+		left  = uiSidebarWidth
+		right int
+		body  = height - 2
+	)
+	if left > width/3 {
+		left = width / 3
+	}
+	right = width - left - 1
+
+	// left column: commands, jobs and milestones
+	var sidebar []string
+	commandRows := body * 3 / 5
+
+	sidebar = append(sidebar, uiHeader("Commands", left))
+	offset := 0
+	if d.cursor >= commandRows-1 {
+		offset = d.cursor - commandRows + 2
+	}
+	for i := offset; i < len(d.names) && len(sidebar) < commandRows; i++ {
+		line := uiFit(" "+d.names[i], left)
+		switch {
+		case d.names[i] == d.running:
+			line = ansi.Black + "\033[42m" + line + ansi.Reset
+		case i == d.cursor:
+			line = "\033[7m" + line + ansi.Reset
+		}
+		sidebar = append(sidebar, line)
+	}
+	for len(sidebar) < commandRows {
+		sidebar = append(sidebar, uiFit("", left))
+	}
+
+	sidebar = append(sidebar, uiHeader("Jobs", left))
+	processLock.Lock()
+	var jobs []string
+	for name, proc := range processMap {
+		jobs = append(jobs, uiFit(" "+name+" ("+strconv.Itoa(proc.Pid)+")", left))
+	}
+	processLock.Unlock()
+	sort.Strings(jobs)
+	if len(jobs) == 0 {
+		jobs = append(jobs, uiFit(" idle", left))
+	}
+	sidebar = append(sidebar, jobs...)
+
+	sidebar = append(sidebar, uiHeader("Milestones", left))
+	if projectData != nil {
+		for _, m := range projectData.Milestones {
+			sidebar = append(sidebar, uiFit(" "+m.Name+" "+strconv.Itoa(m.PercentComplete)+"%", left))
+		}
+	}
+
+	// right column: live output and recent history
+	var (
+		main       []string
+		outputRows = body * 7 / 10
+		history    = recentHistory()
+		output     = lastRunOutput()
+	)
+
+	main = append(main, uiHeader("Output", right))
+	if len(output) > outputRows-1 {
+		output = output[len(output)-outputRows+1:]
+	}
+	for _, line := range output {
+		main = append(main, uiFit(" "+line, right))
+	}
+	for len(main) < outputRows {
+		main = append(main, uiFit("", right))
+	}
+
+	main = append(main, uiHeader("History", right))
+	if len(history) > body-outputRows-1 {
+		history = history[len(history)-(body-outputRows-1):]
+	}
+	for _, line := range history {
+		main = append(main, uiFit(" "+line, right))
+	}
+
+	// compose the screen
+	var b bytes.Buffer
+	b.WriteString("\033[H")
+
+	title := " ZEUS - " + filepath.Base(workingDir)
+	if d.running != "" {
+		title += " - running " + d.running
+	} else if d.status != "" {
+		title += " - " + d.status
+	}
+	b.WriteString("\033[7m" + uiFit(title, width) + ansi.Reset + "\r\n")
+
+	for i := 0; i < body; i++ {
+		b.WriteString(uiRow(sidebar, i, left) + "│" + uiRow(main, i, right) + "\r\n")
+	}
+
+	b.WriteString("\033[7m" + uiFit(" ↑/k ↓/j select   enter run   x interrupt   q quit", width) + ansi.Reset)
+
+	os.Stdout.Write(b.Bytes())
+}
+
+// get row i of a column, or an empty row
+func uiRow(column []string, i, width int) string {
+	if i < len(column) {
+		return column[i]
+	}
+	return uiFit("", width)
+}
+
+// render a pane header
+func uiHeader(title string, width int) string {
+	return ansi.LightCyan + uiFit(" "+title, width) + ansi.Reset
+}
+
+// cut or pad s to exactly width runes
+func uiFit(s string, width int) string {
+
+	s = strings.Replace(s, "\t", "    ", -1)
+
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+
+	return string([]rune(s)[:width])
+}
@@ -0,0 +1,329 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgutz/ansi"
+)
+
+// directory for the resource usage history of the commands
+const usageDir = "zeus/usage"
+
+const (
+	// number of runs kept in the usage history of a command
+	usageHistorySize = 50
+
+	// interval for sampling the memory of the process tree
+	rssSampleInterval = 250 * time.Millisecond
+)
+
+// resourceUsage of a single run of a command
+type resourceUsage struct {
+	Time     time.Time     `json:"time"`
+	Wall     time.Duration `json:"wall"`
+	User     time.Duration `json:"user"`
+	System   time.Duration `json:"system"`
+	MaxRSS   int64         `json:"maxRss"`
+	ExitCode int           `json:"exitCode"`
+}
+
+// collect the resource usage of a finished process
+// the peak memory is the maximum of the rusage and the sampled memory of the process tree
+func newResourceUsage(state *os.ProcessState, wall time.Duration, sampled int64) *resourceUsage {
+
+	u := &resourceUsage{
+		Time:   time.Now(),
+		Wall:   wall,
+		MaxRSS: sampled,
+	}
+
+	if state != nil {
+		u.User = state.UserTime()
+		u.System = state.SystemTime()
+		if rss := maxRSS(state); rss > u.MaxRSS {
+			u.MaxRSS = rss
+		}
+		u.ExitCode = state.ExitCode()
+	}
+
+	return u
+}
+
+// format the usage for the summary of a run
+func (u *resourceUsage) String() string {
+
+	s := "user " + u.User.Round(time.Millisecond).String() + ", sys " + u.System.Round(time.Millisecond).String()
+	if u.MaxRSS > 0 {
+		s += ", max rss " + formatBytes(uint64(u.MaxRSS))
+	}
+
+	return s
+}
+
+// rssSampler tracks the peak resident memory of a process tree
+// rusage only knows the largest single process, concurrent children add up
+type rssSampler struct {
+	peak int64
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// start sampling the memory of the process tree below pid
+// stops by itself on platforms without a way to inspect the tree
+func startRSSSampler(pid int) *rssSampler {
+
+	s := &rssSampler{
+		done: make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(rssSampleInterval)
+		defer ticker.Stop()
+
+		for {
+			rss, ok := treeRSS(pid)
+			if !ok {
+				return
+			}
+			if rss > s.peak {
+				s.peak = rss
+			}
+
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return s
+}
+
+// stop sampling and return the peak memory of the tree
+func (s *rssSampler) stop() int64 {
+	close(s.done)
+	s.wg.Wait()
+	return s.peak
+}
+
+func usagePath(command string) string {
+	return filepath.Join(usageDir, runDirName(command)+".jsonl")
+}
+
+// append the usage of a run to the history of the command
+// only the latest runs are kept
+func recordUsage(command string, u *resourceUsage) error {
+
+	if readOnly {
+		return nil
+	}
+
+	history, err := loadUsage(command)
+	if err != nil {
+		return err
+	}
+
+	history = append(history, u)
+	if len(history) > usageHistorySize {
+		history = history[len(history)-usageHistorySize:]
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range history {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(usageDir, 0700); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(usagePath(command), buf.Bytes(), 0600)
+}
+
+// read the usage history of a command, the latest run last
+func loadUsage(command string) ([]*resourceUsage, error) {
+
+	f, err := os.Open(usagePath(command))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		history []*resourceUsage
+		scanner = bufio.NewScanner(f)
+	)
+	for scanner.Scan() {
+		u := new(resourceUsage)
+		if err := json.Unmarshal(scanner.Bytes(), u); err != nil {
+			return nil, err
+		}
+		history = append(history, u)
+	}
+
+	return history, scanner.Err()
+}
+
+// handle usage shell command
+// prints the usage history of a command, or the last run of all commands
+func handleUsageCommand(args []string) error {
+
+	switch len(args) {
+	case 1:
+		return printLastUsage()
+	case 2:
+		history, err := loadUsage(args[1])
+		if err != nil {
+			Log.WithError(err).Error("failed to read the usage history of " + args[1])
+			return err
+		}
+		if len(history) == 0 {
+			l.Println(cp.colorText + "no usage recorded for " + args[1] + ansi.Reset)
+			return nil
+		}
+		for _, u := range history {
+			printUsageRow(u.Time.Format("2006-01-02 15:04:05"), u)
+		}
+		printUsageTrend(history)
+		return nil
+	default:
+		printUsageUsageErr()
+		return ErrInvalidUsage
+	}
+}
+
+// print the last run of every command with a usage history
+func printLastUsage() error {
+
+	files, err := ioutil.ReadDir(usageDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			l.Println(cp.colorText + "no usage recorded yet" + ansi.Reset)
+			return nil
+		}
+		return err
+	}
+
+	var names []string
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".jsonl") {
+			names = append(names, strings.TrimSuffix(f.Name(), ".jsonl"))
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		history, err := loadUsage(name)
+		if err != nil {
+			Log.WithError(err).Error("failed to read the usage history of " + name)
+			continue
+		}
+		if len(history) > 0 {
+			printUsageRow(name, history[len(history)-1])
+		}
+	}
+
+	return nil
+}
+
+func printUsageRow(label string, u *resourceUsage) {
+
+	status := ansi.Green + "ok"
+	if u.ExitCode != 0 {
+		status = ansi.Red + "exit " + strconv.Itoa(u.ExitCode)
+	}
+
+	l.Println(cp.colorText + pad(label, 22) +
+		cp.colorPrompt + pad(u.Wall.Round(time.Millisecond).String(), 12) +
+		cp.colorText + pad("user "+u.User.Round(time.Millisecond).String(), 16) +
+		pad("sys "+u.System.Round(time.Millisecond).String(), 16) +
+		pad(formatBytes(uint64(u.MaxRSS)), 12) +
+		status + ansi.Reset)
+}
+
+// compare the last run to the average of the previous runs
+func printUsageTrend(history []*resourceUsage) {
+
+	if len(history) < 2 {
+		return
+	}
+
+	var (
+		last     = history[len(history)-1]
+		previous = history[:len(history)-1]
+		wall     time.Duration
+		cpu      time.Duration
+		rss      int64
+	)
+	for _, u := range previous {
+		wall += u.Wall
+		cpu += u.User + u.System
+		rss += u.MaxRSS
+	}
+
+	n := int64(len(previous))
+
+	l.Println(cp.colorText + "last run compared to the average of " + strconv.Itoa(len(previous)) + " previous runs: " +
+		"wall " + formatChange(float64(last.Wall), float64(wall)/float64(n)) +
+		cp.colorText + ", cpu " + formatChange(float64(last.User+last.System), float64(cpu)/float64(n)) +
+		cp.colorText + ", max rss " + formatChange(float64(last.MaxRSS), float64(rss)/float64(n)) + ansi.Reset)
+}
+
+// format the relative change from avg to v, increases are red
+func formatChange(v, avg float64) string {
+
+	if avg == 0 {
+		return "n/a"
+	}
+
+	change := (v - avg) / avg * 100
+	s := strconv.FormatFloat(change, 'f', 1, 64) + "%"
+	if change > 0 {
+		return ansi.Red + "+" + s
+	}
+
+	return ansi.Green + s
+}
+
+func printUsageUsageErr() {
+	l.Println(ErrInvalidUsage)
+	l.Println("usage: usage [command]")
+}
@@ -19,16 +19,19 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/mgutz/ansi"
 )
@@ -36,7 +39,24 @@ import (
 var (
 
 	// color all output to Stderr red
-	cWriter = newColorWriter(os.Stderr, ansi.Red)
+	cWriter = newColorWriter(stderr, ansi.Red)
+
+	// stream all output to Stdout line by line
+	oWriter = newColorWriter(stdout, "")
+
+	// output for executed commands, redirected to the client when running as daemon
+	commandStdout io.Writer = oWriter
+	commandStderr io.Writer = cWriter
+
+	// input for executed commands, nil while the dashboard owns the terminal
+	commandStdin io.Reader = os.Stdin
+
+	// default limit for buffering incomplete lines of command output
+	defaultOutputBufferSize = 64 * 1024
+
+	// incomplete lines are written when no more output arrived for this duration,
+	// so prompts without a newline become visible
+	partialLineTimeout = 100 * time.Millisecond
 
 	// prompt for the interactive
 	zeusPrompt  = "zeus"
@@ -44,31 +64,155 @@ var (
 )
 
 // create a new color writer instance
+// an empty color string disables coloring
 func newColorWriter(w io.Writer, color string) *colorWriter {
 	return &colorWriter{
 		color: color,
 		w:     w,
+		mutex: &sync.Mutex{},
 	}
 }
 
-// colorWriter wraps an io.Writer and writes all data prefixed with specified ANSI string
+// flusher is implemented by writers that buffer data
+type flusher interface {
+	Flush() error
+}
+
+// colorWriter wraps an io.Writer and streams the data line by line, each line prefixed with the specified ANSI string
+// lines end with a newline or a carriage return, for progress bars that redraw the line
+// incomplete lines are buffered until they are completed, the buffer limit is reached or the command is idle,
+// so the memory usage stays bounded no matter how much output a command produces
 type colorWriter struct {
 	color string
 	w     io.Writer
+
+	// incomplete line
+	buf []byte
+
+	// writes the incomplete line when the command is idle
+	idle *time.Timer
+
+	// processed output for a single write
+	out []byte
+
+	mutex *sync.Mutex
+}
+
+// get the limit for buffering incomplete lines
+func outputBufferSize() int {
+	if conf != nil && conf.OutputBufferSize > 0 {
+		return conf.OutputBufferSize
+	}
+	return defaultOutputBufferSize
 }
 
 // implement io.Writer
 func (c *colorWriter) Write(b []byte) (n int, err error) {
 
-	var coloredBuffer = append([]byte(c.color), b...)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	_, err = c.w.Write(append(coloredBuffer, []byte(ansi.Reset)...))
-	if err != nil {
-		Log.WithError(err).Error("error writing")
-	}
+	var limit = outputBufferSize()
 
 	// we need to lie about the written bytelength, otherwise a runtime error will happen
-	return len(b), err
+	n = len(b)
+	c.out = c.out[:0]
+
+	for len(b) > 0 {
+
+		i := bytes.IndexAny(b, "\r\n")
+		if i >= 0 && b[i] == '\r' && i+1 < len(b) && b[i+1] == '\n' {
+			i++
+		}
+		if i < 0 {
+
+			// incomplete line, keep it until the rest arrives
+			c.buf = append(c.buf, b...)
+			if len(c.buf) >= limit {
+				c.addLine(c.buf)
+				c.buf = c.buf[:0]
+			}
+			break
+		}
+
+		line := b[:i+1]
+		if len(c.buf) > 0 {
+			c.buf = append(c.buf, line...)
+			line = c.buf
+		}
+
+		c.addLine(line)
+		c.buf = c.buf[:0]
+		b = b[i+1:]
+	}
+
+	if len(c.out) > 0 {
+		_, err = c.w.Write(c.out)
+		if err != nil {
+			Log.WithError(err).Error("error writing")
+		}
+	}
+
+	// dont hold on to the memory of huge writes
+	if cap(c.out) > limit {
+		c.out = nil
+	}
+
+	if len(c.buf) > 0 {
+		if c.idle == nil {
+			c.idle = time.AfterFunc(partialLineTimeout, func() {
+				c.Flush()
+			})
+		} else {
+			c.idle.Reset(partialLineTimeout)
+		}
+	} else if c.idle != nil {
+		c.idle.Stop()
+	}
+
+	return n, err
+}
+
+// add a line to the output of the current write
+func (c *colorWriter) addLine(line []byte) {
+	line = []byte(maskSecrets(string(line)))
+	if c.color == "" {
+		c.out = append(c.out, line...)
+		return
+	}
+	c.out = append(c.out, c.color...)
+	c.out = append(c.out, line...)
+	c.out = append(c.out, ansi.Reset...)
+}
+
+// Flush writes the incomplete line
+func (c *colorWriter) Flush() error {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.buf) == 0 {
+		return nil
+	}
+
+	c.out = c.out[:0]
+	c.addLine(c.buf)
+	c.buf = c.buf[:0]
+
+	_, err := c.w.Write(c.out)
+	return err
+}
+
+// flush the buffered command output
+func flushOutput() {
+	for _, w := range []io.Writer{commandStdout, commandStderr} {
+		if fl, ok := w.(flusher); ok {
+			err := fl.Flush()
+			if err != nil {
+				Log.WithError(err).Error("failed to flush output")
+			}
+		}
+	}
 }
 
 // dump the currently executed script in case of an error
@@ -81,7 +225,7 @@ func dumpScript(script string) {
 	}
 	defer f.Close()
 
-	f.WriteString(script)
+	f.WriteString(maskSecrets(script))
 	Log.Info("script dumped")
 }
 
@@ -108,33 +252,66 @@ func printScript(script string) {
 }
 
 // handle OS SIGNALS for a clean exit and clean up all spawned processes
-func handleSignals() {
+// interrupts are forwarded to the process groups of the running commands,
+// when no command is running and zeus is not in interactive mode, zeus exits
+// all other signals trigger the shutdown sequence
+func handleSignals(interactive bool) {
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGSEGV, syscall.SIGHUP, syscall.SIGQUIT)
 
-	// var signalLock sync.Mutex
 	go func() {
+		for sig := range c {
 
-		sig := <-c
+			Log.Info("received SIGNAL: ", sig)
 
-		Log.Info("received SIGNAL: ", sig)
+			if sig != os.Interrupt {
+				shutdown(1)
+			}
 
-		// lock the mutex
-		signalMutex.Lock()
+			signalMutex.Lock()
+			processLock.Lock()
+
+			if len(processMap) == 0 {
+				processLock.Unlock()
+
+				// stop a watch loop waiting for changes
+				if idleInterrupt != nil {
+					select {
+					case idleInterrupt <- struct{}{}:
+					default:
+					}
+					signalMutex.Unlock()
+					continue
+				}
+
+				if !interactive {
+					shutdown(1)
+				}
+				signalMutex.Unlock()
+				continue
+			}
 
-		// kill all spawned procs
-		clearProcessMap()
+			for name, p := range processMap {
+				if p != nil {
+					err := signalProcessGroup(p, sig)
+					if err != nil {
+						Log.WithError(err).Debug("failed to signal " + name)
+					}
+				}
+			}
 
-		// return to interactive shell
-		return
+			processLock.Unlock()
+			signalMutex.Unlock()
+		}
 	}()
 }
 
 // clean up the mess when we leave
 func clearProcessMap() {
 
-	// l.Println("processMap:", processMap)
+	processLock.Lock()
+	defer processLock.Unlock()
 
 	// range processes
 	for name, p := range processMap {
@@ -142,8 +319,8 @@ func clearProcessMap() {
 
 			l.Println(printPrompt() + "killing " + name)
 
-			// kill it
-			err := p.Kill()
+			// kill it, including its children
+			err := killProcessGroup(p)
 			if err != nil {
 				Log.WithError(err).Debug("failed to kill " + name)
 			}
@@ -159,24 +336,18 @@ func pad(in string, length int) string {
 	return in
 }
 
-// create a readable string from commandChain commands
+// create a readable string from the parsed commands of a commandChain
 // example: (clean -> build -> install)
-func formatcommandChain(commands commandChain) string {
+func formatcommandChain(parsedCommands [][]string) string {
 
 	var out = "("
-	for i, cmd := range commands {
+	for i, args := range parsedCommands {
 
-		out += cmd.name
-
-		// check if command has params set
-		if len(cmd.params) > 0 {
-			for _, p := range cmd.params {
-				out += " " + p
-			}
-		}
+		// command name and params
+		out += strings.Join(args, " ")
 
 		// if not last elem
-		if !(i == len(commands)-1) {
+		if !(i == len(parsedCommands)-1) {
 			out += " -> "
 		}
 	}
@@ -196,6 +367,12 @@ func countCommandChain(chain commandChain) int {
 	count := 0
 	for _, cmd := range chain {
 		count++
+
+		err := cmd.resolveChain()
+		if err != nil {
+			Log.WithError(err).Error("failed to resolve command chain for " + cmd.name)
+		}
+
 		if len(cmd.commandChain) > 0 {
 			count += countCommandChain(cmd.commandChain)
 		}
@@ -204,6 +381,12 @@ func countCommandChain(chain commandChain) int {
 }
 
 func getTotalCommandCount(c *command) int {
+
+	err := c.resolveChain()
+	if err != nil {
+		Log.WithError(err).Error("failed to resolve command chain for " + c.name)
+	}
+
 	return 1 + countCommandChain(c.commandChain)
 }
 
@@ -217,88 +400,44 @@ func printPrompt() string {
 // arguments that contain string literals " or ' will be grouped before passing them to shell
 func passCommandToShell(commandName string, args []string) error {
 
-	// handle string literals
-	args = fixArgs(args)
+	if err := checkExecutionAllowed(commandName); err != nil {
+		return err
+	}
 
 	var cmd *exec.Cmd
 
 	// if there are arguments pass them
 	if len(args) > 0 {
-		cmd = exec.Command(commandName, args...)
+		cmd = systemCommand(commandName, args...)
 	} else {
-		cmd = exec.Command(commandName)
+		cmd = systemCommand(commandName)
 	}
 
 	// setup environment
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdin = runStdin()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
 	return cmd.Run()
 }
 
-// fix args in case there is a string literal in there
-// this will cause all strings in arguments to be passed as one to the shell
-// example:
-// ["git", "commit", "-m", "'what", "the", "hell'"] -> ["git", "commit", "-m", "'what the hell'"]
-func fixArgs(args []string) []string {
-
-	var (
-		fixed         = []string{}
-		insideLiteral bool
-		literalIndex  int
-	)
-
-	// range arguments until there appears a starting string literal
-	// from there on concatenate all following fields to the current one
-	// when the closing tag appears concatenation is stopped
-	for _, a := range args {
-
-		if insideLiteral {
-			fixed[literalIndex] += " " + a
-		} else {
-			fixed = append(fixed, a)
-		}
-
-		if isStartTag(a) {
-			insideLiteral = true
-			literalIndex = len(fixed) - 1
-		} else if isEndTag(a) {
-			insideLiteral = false
-		}
-	}
-
-	return fixed
-}
-
-// check if the string literal starts
-func isStartTag(s string) bool {
-	if strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") {
-		return true
-	}
-	return false
-}
-
-// check if the string literal ends
-func isEndTag(s string) bool {
-	if strings.HasSuffix(s, "\"") || strings.HasSuffix(s, "'") {
-		return true
-	}
-	return false
-}
-
 // check if its a valid command chain
 func validCommandChain(args []string) bool {
 
 	var (
-		chain       = strings.Join(args, " ")
-		job         = p.AddJob(chain)
-		commandList = parseCommandChain(chain)
+		chain = strings.Join(args, " ")
+		job   = p.AddJob(chain)
 	)
 
 	defer p.RemoveJob(job)
 
-	_, err := job.getCommandChain(commandList)
+	commandList, err := parseCommandChain(chain)
+	if err != nil {
+		Log.WithError(err).Error("failed to parse command chain")
+		return false
+	}
+
+	_, err = job.getCommandChain(commandList, nil)
 	if err != nil {
 		Log.WithError(err).Error("failed to get command chain")
 		return false
@@ -315,6 +454,20 @@ func handleHelpCommand(args []string) {
 		return
 	}
 
+	if args[1] == exportFlag {
+		if len(args) != 3 {
+			printHelpExportUsageErr()
+			return
+		}
+		path, err := exportHelp(args[2])
+		if err != nil {
+			Log.WithError(err).Error("failed to export the help")
+			return
+		}
+		Log.Info("exported the help to ", path)
+		return
+	}
+
 	if c, ok := commands[args[1]]; ok {
 		l.Println("\n" + c.manual)
 		return
@@ -325,7 +478,7 @@ func handleHelpCommand(args []string) {
 
 func printHelpUsageErr() {
 	Log.Error(ErrInvalidUsage)
-	Log.Info("usage: help <command>")
+	Log.Info("usage: help <command> | help --export <markdown|man|bash>")
 }
 
 // check if the argument type matches the expected one
@@ -340,7 +493,7 @@ func validArgType(in string, k reflect.Kind) bool {
 		_, err = strconv.ParseFloat(in, 64)
 	case reflect.String:
 	case reflect.Int:
-		_, err = strconv.ParseInt(in, 64, 0)
+		_, err = strconv.ParseInt(in, 10, 0)
 	default:
 		return false
 	}
@@ -350,3 +503,13 @@ func validArgType(in string, k reflect.Kind) bool {
 	}
 	return false
 }
+
+// path of a file in the home directory of the user
+// HOME is not set on windows, os.UserHomeDir knows the home directory on all platforms
+func homePath(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		Log.WithError(err).Debug("no home directory")
+	}
+	return filepath.Join(home, name)
+}
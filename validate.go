@@ -0,0 +1,337 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mgutz/ansi"
+)
+
+// ErrValidationFailed means the project validation found problems
+var ErrValidationFailed = errors.New("validation failed")
+
+// a problem found by the project validation
+type validationProblem struct {
+	path    string
+	line    int
+	message string
+}
+
+func (v *validationProblem) String() string {
+	if v.line > 0 {
+		return v.path + ":" + strconv.Itoa(v.line) + ": " + v.message
+	}
+	return v.path + ": " + v.message
+}
+
+// handle validate shell command
+// prints all problems of the project and returns an error if there were any
+func handleValidateCommand() error {
+
+	problems := validateProject()
+
+	for _, v := range problems {
+		l.Println(ansi.Red + v.String() + ansi.Reset)
+	}
+
+	if len(problems) > 0 {
+		l.Println(strconv.Itoa(len(problems)) + " problems found.")
+		return ErrValidationFailed
+	}
+
+	l.Println("no problems found.")
+	return nil
+}
+
+// check the whole project without executing anything
+// collects all problems instead of stopping at the first one
+func validateProject() (problems []*validationProblem) {
+
+	scripts, err := projectScripts()
+	if err != nil {
+		problems = append(problems, &validationProblem{path: zeusDir, message: err.Error()})
+	}
+
+	for _, path := range scripts {
+		problems = append(problems, validateScript(path)...)
+	}
+
+	// shell interpreter
+	if _, err := shellCommand(); err != nil {
+		problems = append(problems, &validationProblem{path: p.interpreter, message: err.Error()})
+	} else if _, err := os.Stat(p.interpreter); err != nil && filepath.IsAbs(p.interpreter) {
+		problems = append(problems, &validationProblem{path: p.interpreter, message: "interpreter not found"})
+	}
+
+	// events
+	for path, e := range projectData.Events {
+		if _, err := os.Stat(path); err != nil {
+			problems = append(problems, &validationProblem{path: projectDataPath, message: "event " + e.Op.String() + " watches missing path " + path})
+		}
+	}
+
+	// globals
+	for name, g := range projectData.Globals {
+		if err := g.validate(g.Value); err != nil {
+			problems = append(problems, &validationProblem{path: projectDataPath, message: "global " + name + ": " + err.Error()})
+		}
+	}
+
+	// aliases
+	for name := range projectData.Aliases {
+		if _, err := os.Stat(commandPathForName(name)); err == nil {
+			problems = append(problems, &validationProblem{path: projectDataPath, message: "alias " + name + " conflicts with a command"})
+		}
+		if _, ok := builtins[name]; ok {
+			problems = append(problems, &validationProblem{path: projectDataPath, message: "alias " + name + " conflicts with a builtin"})
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].path < problems[j].path
+	})
+
+	return
+}
+
+// collect the command scripts of the project and its packages
+func projectScripts() (scripts []string, err error) {
+
+	err = walkFollow(zeusDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && inNoCommandDir(path) {
+			return filepath.SkipDir
+		}
+		path = filepath.ToSlash(path)
+		if strings.HasSuffix(path, f.fileExtension) && !strings.HasPrefix(strings.TrimPrefix(path, zeusDir+"/"), "globals") {
+			scripts = append(scripts, path)
+		}
+		return nil
+	})
+
+	if len(packages) == 0 {
+		return append(scripts, findPackages()...), err
+	}
+
+	for _, pkg := range packages {
+		s, pkgErr := pkg.scripts()
+		if pkgErr != nil {
+			return scripts, pkgErr
+		}
+		scripts = append(scripts, s...)
+	}
+
+	return
+}
+
+// check if a command referenced in a chain exists
+// besides the scripts of the zeus directory, chains can reference the loaded commands,
+// like the commands generated by the config script, and the scripts discovered in the ScriptDirs
+func chainCommandExists(name string) bool {
+
+	commandMutex.Lock()
+	_, ok := commands[name]
+	commandMutex.Unlock()
+	if ok {
+		return true
+	}
+
+	if _, err := os.Stat(commandPathForName(name)); err == nil {
+		return true
+	}
+
+	if _, ok := generatedPaths[name]; ok {
+		return true
+	}
+
+	for _, path := range discoveredScripts() {
+		base := filepath.Base(path)
+		if strings.TrimSuffix(base, filepath.Ext(base)) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check the header of the script at path
+// mirrors the checks of the parser, without modifying the script or exiting
+func validateScript(path string) (problems []*validationProblem) {
+
+	var add = func(line int, message string) {
+		problems = append(problems, &validationProblem{path: path, line: line, message: message})
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		add(0, err.Error())
+		return
+	}
+	defer file.Close()
+
+	var (
+		scanner  = bufio.NewScanner(file)
+		pkg      = packageForPath(path)
+		counts   = map[string]int{}
+		manual   bool
+		c        int
+		reported bool
+	)
+	scanner.Split(scanRawLines)
+
+	for scanner.Scan() {
+
+		line, issue := normalizeLine(scanner.Text(), c == 0)
+		c++
+
+		if issue != "" && !reported {
+			add(c, issue)
+			reported = true
+		}
+
+		if c == 1 {
+			if line != p.shebang {
+				add(c, "first line does not contain the shebang "+p.shebang)
+			}
+			continue
+		}
+
+		// the header ends with the first line of code
+		if line != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+
+		// multiline help
+		if strings.HasPrefix(line, "# --------------------") {
+			manual = !manual
+			continue
+		}
+		if manual {
+			continue
+		}
+
+		for _, field := range []string{p.zeusFieldHelp, p.zeusFieldArgs, p.zeusFieldChain, p.zeusFieldBuildNumber, p.zeusFieldDependency, p.zeusFieldLimits, p.zeusFieldSandbox, p.zeusFieldEnv, p.zeusFieldResults, p.zeusFieldCoverage, p.zeusFieldDepends, p.zeusFieldFilter, p.zeusFieldTTY, p.zeusFieldRequires} {
+
+			if !strings.Contains(line, field) {
+				continue
+			}
+
+			counts[field]++
+			if counts[field] == 2 {
+				add(c, "duplicate "+field+" header field")
+			}
+
+			if !validzeusHeaderField.MatchString(line) {
+				add(c, "invalid "+field+" header field: "+line)
+				break
+			}
+
+			switch field {
+			case p.zeusFieldLimits:
+				if _, err := parseLimits(strings.TrimSpace(trimZeusPrefix(line))); err != nil {
+					add(c, err.Error())
+				}
+			case p.zeusFieldSandbox:
+				if _, err := parseSandbox(strings.TrimSpace(trimZeusPrefix(line))); err != nil {
+					add(c, err.Error())
+				}
+			case p.zeusFieldEnv:
+				if _, err := parseEnvNames(strings.TrimSpace(trimZeusPrefix(line))); err != nil {
+					add(c, err.Error())
+				}
+			case p.zeusFieldResults:
+				if err := validResultFormat(strings.TrimSpace(trimZeusPrefix(line))); err != nil {
+					add(c, err.Error())
+				}
+			case p.zeusFieldDepends:
+				if _, err := parseDepends(strings.TrimSpace(trimZeusPrefix(line))); err != nil {
+					add(c, err.Error())
+				}
+			case p.zeusFieldFilter:
+				if _, err := parseFilter(strings.TrimSpace(trimZeusPrefix(line))); err != nil {
+					add(c, err.Error())
+				}
+			case p.zeusFieldTTY:
+				if _, err := parseTTY(strings.TrimSpace(trimZeusPrefix(line))); err != nil {
+					add(c, err.Error())
+				}
+			case p.zeusFieldArgs:
+				problems = append(problems, validateArgs(path, c, line)...)
+			case p.zeusFieldChain:
+				chain, err := parseCommandChain(line)
+				if err != nil {
+					add(c, "invalid zeus-chain: "+err.Error())
+				}
+				for _, cmd := range chain {
+					if len(cmd) == 0 {
+						continue
+					}
+					name := pkg.qualify(cmd[0])
+					if !chainCommandExists(name) {
+						add(c, "unknown command in chain: "+name)
+					}
+				}
+			}
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		add(c, err.Error())
+	}
+
+	return
+}
+
+// check the argument definitions of a zeus-args header field
+func validateArgs(path string, line int, field string) (problems []*validationProblem) {
+
+	var names = map[string]bool{}
+
+	for _, s := range strings.Fields(strings.TrimSpace(trimZeusPrefix(field))) {
+
+		slice := strings.Split(s, ":")
+		if len(slice) != 2 {
+			if !conf.AllowUntypedArgs {
+				problems = append(problems, &validationProblem{path, line, "untyped argument: " + s})
+			}
+			continue
+		}
+
+		if names[slice[0]] {
+			problems = append(problems, &validationProblem{path, line, "argument name used twice: " + slice[0]})
+		}
+		names[slice[0]] = true
+
+		if _, ok := newCommandArg(slice[0], slice[1]); !ok {
+			problems = append(problems, &validationProblem{path, line, "invalid argument type: " + slice[1]})
+		}
+	}
+
+	return
+}
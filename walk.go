@@ -0,0 +1,97 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// directories inside the zeus directory that contain no commands
+// they hold the output of previous runs, the generated scripts of the config script and the plugins
+var noCommandDirs = []string{artifactsDir, runLogsDir, snapshotsDir, usageDir, generatedDir, pluginDir}
+
+// check if path is one of the noCommandDirs or inside of one
+// walkers collecting scripts must skip them
+func inNoCommandDir(path string) bool {
+
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	for _, dir := range noCommandDirs {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// walk the file tree at root like filepath.Walk, but follow symlinks
+// symlinked directories are entered only once, so links pointing to a parent directory cause no loops
+// when FollowSymlinks is disabled in the config, symlinks are skipped
+// the FileInfo passed to fn describes the link target
+func walkFollow(root string, fn filepath.WalkFunc) error {
+	return walkDir(root, fn, map[string]bool{})
+}
+
+// walk a single directory tree and record the real paths of the visited directories
+func walkDir(root string, fn filepath.WalkFunc, visited map[string]bool) error {
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+
+		// a walk starting at a symlink uses a trailing slash to enter the target
+		path = filepath.Clean(path)
+
+		if err != nil {
+			return fn(path, info, err)
+		}
+
+		if info.Mode()&os.ModeSymlink == 0 {
+			if info.IsDir() {
+				real, err := filepath.EvalSymlinks(path)
+				if err != nil {
+					return fn(path, info, err)
+				}
+				if visited[real] {
+					return filepath.SkipDir
+				}
+				visited[real] = true
+			}
+			return fn(path, info, nil)
+		}
+
+		if conf != nil && !conf.FollowSymlinks {
+			Log.Debug("skipping symlink: ", path)
+			return nil
+		}
+
+		target, err := os.Stat(path)
+		if err != nil {
+			Log.WithError(err).Warn("skipping broken symlink: ", path)
+			return nil
+		}
+
+		if target.IsDir() {
+			return walkDir(path+string(filepath.Separator), fn, visited)
+		}
+
+		return fn(path, target, nil)
+	})
+}
@@ -0,0 +1,230 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/fsnotify/fsnotify"
+)
+
+var (
+	// shared filesystem watches for all events
+	watches = newWatchManager()
+)
+
+// watchManager consolidates the watches for all events into per directory watches
+// a single fsnotify watcher is used, incoming events are dispatched to the handlers of the matching events
+type watchManager struct {
+	watcher *fsnotify.Watcher
+
+	// watched directories mapped to the number of events using them
+	dirs map[string]int
+
+	// registered events
+	events map[*Event]bool
+
+	mutex *sync.Mutex
+}
+
+// create a new watch manager instance
+func newWatchManager() *watchManager {
+	return &watchManager{
+		dirs:   make(map[string]int, 0),
+		events: make(map[*Event]bool, 0),
+		mutex:  &sync.Mutex{},
+	}
+}
+
+// get the directory that needs to be watched for the event
+// files are watched through their parent directory
+func watchDir(e *Event) string {
+	if e.isDir {
+		return filepath.Clean(e.Path)
+	}
+	return filepath.Dir(filepath.Clean(e.Path))
+}
+
+// register an event and watch its directory
+// thread safe
+func (w *watchManager) add(e *Event) error {
+
+	stat, err := os.Stat(e.Path)
+	if err != nil {
+		return err
+	}
+	e.isDir = stat.IsDir()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// init the watcher on first use
+	if w.watcher == nil {
+		w.watcher, err = fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		go w.dispatch(w.watcher)
+	}
+
+	dir := watchDir(e)
+	if w.dirs[dir] == 0 {
+		err = w.watcher.Add(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.dirs[dir]++
+	w.events[e] = true
+
+	return nil
+}
+
+// unregister an event and remove the directory watch when its no longer used
+// thread safe
+func (w *watchManager) remove(e *Event) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.events[e] {
+		return
+	}
+	delete(w.events, e)
+
+	dir := watchDir(e)
+	w.dirs[dir]--
+	if w.dirs[dir] <= 0 {
+		delete(w.dirs, dir)
+		err := w.watcher.Remove(dir)
+		if err != nil {
+			Log.WithError(err).Debug("failed to remove watch for ", dir)
+		}
+	}
+}
+
+// stop all registered events and close the watcher
+// thread safe
+func (w *watchManager) close() {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.watcher == nil {
+		return
+	}
+
+	// release the goroutines blocking in addEvent
+	for e := range w.events {
+		select {
+		case e.stopChan <- true:
+		default:
+		}
+	}
+
+	err := w.watcher.Close()
+	if err != nil {
+		Log.WithError(err).Debug("failed to close watcher")
+	}
+
+	w.watcher = nil
+	w.dirs = make(map[string]int, 0)
+	w.events = make(map[*Event]bool, 0)
+}
+
+// read incoming events and pass them to the matching handlers
+func (w *watchManager) dispatch(watcher *fsnotify.Watcher) {
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			Log.WithFields(logrus.Fields{
+				"event": event,
+			}).Debug("incoming event")
+
+			// fire handlers
+			for _, m := range w.matching(event) {
+				m.fire()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			Log.WithError(err).Error("watcher failed")
+		}
+	}
+}
+
+// an event matched by an incoming filesystem event
+type eventMatch struct {
+	e     *Event
+	event fsnotify.Event
+}
+
+// run the event handler
+// a panicking handler is reported and does not take down the watcher
+func (m *eventMatch) fire() {
+	defer recoverAndContinue()
+	m.e.handler(m.event)
+}
+
+// collect the registered events matching the incoming filesystem event
+// thread safe
+func (w *watchManager) matching(event fsnotify.Event) (matches []*eventMatch) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	var name = filepath.Clean(event.Name)
+
+	for e := range w.events {
+
+		// check operation type, an event can watch multiple operations
+		if event.Op&e.Op == 0 {
+			continue
+		}
+
+		path := filepath.Clean(e.Path)
+
+		if e.isDir {
+			if filepath.Dir(name) == path || name == path {
+				matches = append(matches, &eventMatch{e, event})
+			}
+			continue
+		}
+
+		if name == path {
+			// use the path as it was registered, handlers compare against it
+			matches = append(matches, &eventMatch{e, fsnotify.Event{
+				Name: e.Path,
+				Op:   event.Op,
+			}})
+		}
+	}
+
+	return
+}
@@ -0,0 +1,203 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mgutz/ansi"
+)
+
+// time without further changes before the command is executed again
+const watchQuietPeriod = 300 * time.Millisecond
+
+// receives an interrupt when the user hits Ctrl-C while no command is running
+// nil if nobody is waiting for it
+var idleInterrupt chan struct{}
+
+func printWatchUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: watch <command> [args]")
+}
+
+// handle watch shell command
+// executes the command again whenever a file of the project changes, until interrupted
+func handleWatchCommand(args []string) {
+
+	if len(args) < 2 {
+		printWatchUsageErr()
+		return
+	}
+
+	commandMutex.Lock()
+	cmd, ok := commands[args[1]]
+	commandMutex.Unlock()
+	if !ok {
+		Log.WithError(ErrUnknownCommand).Error(args[1])
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		Log.WithError(err).Error("failed to create watcher")
+		return
+	}
+	defer watcher.Close()
+
+	err = walkFollow(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != "." && ignoredByWatch(path) {
+				return filepath.SkipDir
+			}
+			return watcher.Add(path)
+		}
+		return nil
+	})
+	if err != nil {
+		Log.WithError(err).Error("failed to watch the project")
+		return
+	}
+
+	limits, err := newSessionLimits()
+	if err != nil {
+		Log.WithError(err).Error("invalid session limits")
+		return
+	}
+
+	signalMutex.Lock()
+	idleInterrupt = make(chan struct{}, 1)
+	signalMutex.Unlock()
+
+	defer func() {
+		signalMutex.Lock()
+		idleInterrupt = nil
+		signalMutex.Unlock()
+	}()
+
+	for run := 1; ; run++ {
+
+		clearScreen()
+		l.Println(cp.colorText + "watching " + cp.colorPrompt + cmd.name + cp.colorText + ", run " + strconv.Itoa(run) + " at " + time.Now().Format("15:04:05") + ", Ctrl-C to stop" + ansi.Reset)
+
+		numCommands = getTotalCommandCount(cmd)
+		start := time.Now()
+		err := cmd.Run(args[2:])
+		elapsed := time.Since(start).Round(time.Millisecond)
+		numCommands = 0
+		currentCommand = 0
+
+		if err != nil {
+			l.Println(ansi.Red + "FAIL " + cmd.name + " (exit code " + strconv.Itoa(exitCode(err)) + ") in " + elapsed.String() + ansi.Reset)
+		} else {
+			l.Println(ansi.Green + "PASS " + cmd.name + " in " + elapsed.String() + ansi.Reset)
+		}
+
+		// discard the changes made by the command itself
+		if !waitForChange(watcher, watchQuietPeriod, limits) {
+			return
+		}
+
+		// wait for the next relevant change, then let it settle
+		if !waitForChange(watcher, 0, limits) || !waitForChange(watcher, watchQuietPeriod, limits) {
+			return
+		}
+	}
+}
+
+// consume events until there were no relevant changes for the quiet period
+// with a quiet period of 0 it blocks until the first relevant change
+// returns false if the loop was interrupted, the watcher closed or the session limits were reached
+func waitForChange(watcher *fsnotify.Watcher, quiet time.Duration, limits *sessionLimits) bool {
+
+	var (
+		timeout <-chan time.Time
+		stop    = limits.timer()
+	)
+	if quiet > 0 {
+		timeout = time.After(quiet)
+	}
+
+	for {
+		select {
+		case <-idleInterrupt:
+			return false
+		case <-stop:
+			if reason := limits.expired(); reason != "" {
+				Log.Info("stopping watch mode: " + reason)
+				return false
+			}
+			stop = limits.timer()
+		case <-timeout:
+			return true
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return false
+			}
+			Log.WithError(err).Debug("watcher error")
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return false
+			}
+			if event.Op == fsnotify.Chmod || ignoredByWatch(event.Name) {
+				continue
+			}
+
+			// watch new directories as well
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					watcher.Add(event.Name)
+				}
+			}
+
+			if quiet == 0 {
+				return true
+			}
+			timeout = time.After(quiet)
+		}
+	}
+}
+
+// check if changes to path are irrelevant for the watch loop
+// the zeus directory only counts for scripts, other files in there are written by zeus itself
+func ignoredByWatch(path string) bool {
+
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	if strings.HasPrefix(path, zeusDir+"/") {
+		return inNoCommandDir(path) || !strings.HasSuffix(path, f.fileExtension)
+	}
+
+	for _, elem := range strings.Split(path, "/") {
+		for _, pattern := range strings.Fields(conf.WatchIgnore) {
+			if ok, _ := filepath.Match(pattern, elem); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,318 @@
+/*
+ *  ZEUS - A Powerful Build System
+ *  Copyright (c) 2017 Philipp Mieden <dreadl0ck@protonmail.ch>
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mgutz/ansi"
+)
+
+var (
+	// ErrUnknownProject means the project is not registered in the workspace
+	ErrUnknownProject = errors.New("unknown workspace project")
+
+	// ErrNotAZeusProject means the directory does not contain a zeus directory
+	ErrNotAZeusProject = errors.New("not a zeus project")
+
+	// path for the user level workspace file
+	workspacePath = homePath(".zeus_workspace.json")
+)
+
+// workspace contains all registered zeus projects
+type workspace struct {
+
+	// project names mapped to their absolute paths
+	Projects map[string]string
+}
+
+// result of running a command in a workspace project
+type workspaceResult struct {
+	project  string
+	err      error
+	duration time.Duration
+}
+
+func newWorkspace() *workspace {
+	return &workspace{
+		Projects: make(map[string]string, 0),
+	}
+}
+
+func printWorkspaceUsageErr() {
+	Log.Error(ErrInvalidUsage)
+	Log.Info("usage: workspace [add <name> [path]] [remove <name>] [run <name | --all> <command>]")
+}
+
+// parse the workspace JSON
+// returns an empty workspace if the file does not exist
+func parseWorkspace() (*workspace, error) {
+
+	var w = newWorkspace()
+
+	contents, err := ioutil.ReadFile(workspacePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return w, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(contents, w)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.Projects == nil {
+		w.Projects = make(map[string]string, 0)
+	}
+
+	return w, nil
+}
+
+// update workspace on disk
+func (w *workspace) update() error {
+
+	// make it pretty
+	b, err := json.MarshalIndent(w, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(workspacePath, b, 0700)
+}
+
+// get the sorted project names
+func (w *workspace) names() []string {
+
+	var names = make([]string, 0, len(w.Projects))
+	for name := range w.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// handle the -p <project> commandline flag
+// changes into the project directory and removes the flag from os.Args
+func handleProjectFlag() {
+
+	if len(os.Args) < 3 || os.Args[1] != "-p" {
+		return
+	}
+
+	w, err := parseWorkspace()
+	if err != nil {
+		Log.WithError(err).Fatal("failed to parse workspace")
+	}
+
+	path, ok := w.Projects[os.Args[2]]
+	if !ok {
+		Log.Fatal(ErrUnknownProject, ": ", os.Args[2])
+	}
+
+	err = os.Chdir(path)
+	if err != nil {
+		Log.WithError(err).Fatal("failed to change into project directory")
+	}
+
+	os.Args = append(os.Args[:1], os.Args[3:]...)
+}
+
+// handle workspace shell command
+func handleWorkspaceCommand(args []string) {
+
+	w, err := parseWorkspace()
+	if err != nil {
+		Log.WithError(err).Error("failed to parse workspace")
+		return
+	}
+
+	if len(args) < 2 {
+		w.list()
+		return
+	}
+
+	if len(args) < 3 {
+		printWorkspaceUsageErr()
+		return
+	}
+
+	switch args[1] {
+	case "add":
+		path := "."
+		if len(args) > 3 {
+			path = args[3]
+		}
+		err = w.add(args[2], path)
+		if err != nil {
+			Log.WithError(err).Error("failed to add project: ", args[2])
+			return
+		}
+		Log.Info("added project ", args[2])
+	case "remove":
+		if _, ok := w.Projects[args[2]]; !ok {
+			Log.Error(ErrUnknownProject, ": ", args[2])
+			return
+		}
+		delete(w.Projects, args[2])
+		err = w.update()
+		if err != nil {
+			Log.WithError(err).Error("failed to update workspace")
+			return
+		}
+		Log.Info("removed project ", args[2])
+	case "run":
+		if len(args) < 4 {
+			printWorkspaceUsageErr()
+			return
+		}
+
+		var names []string
+		if args[2] == "--all" {
+			names = w.names()
+		} else {
+			if _, ok := w.Projects[args[2]]; !ok {
+				Log.Error(ErrUnknownProject, ": ", args[2])
+				return
+			}
+			names = []string{args[2]}
+		}
+
+		results := w.run(names, args[3:])
+		if printWorkspaceSummary(results) > 0 && rl == nil {
+			shutdown(1)
+		}
+	default:
+		printWorkspaceUsageErr()
+	}
+}
+
+// register a project in the workspace
+func (w *workspace) add(name, path string) error {
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(filepath.Join(abs, zeusDir))
+	if err != nil || !stat.IsDir() {
+		return ErrNotAZeusProject
+	}
+
+	w.Projects[name] = abs
+
+	return w.update()
+}
+
+// print all registered projects
+func (w *workspace) list() {
+
+	if len(w.Projects) == 0 {
+		l.Println("no projects registered.")
+		return
+	}
+
+	var maxLen int
+	for name := range w.Projects {
+		if len(name) > maxLen {
+			maxLen = len(name)
+		}
+	}
+
+	l.Println(cp.colorText + "projects:")
+	for _, name := range w.names() {
+		l.Println(cp.colorCommandName + pad(name, maxLen+1) + cp.colorText + w.Projects[name])
+	}
+}
+
+// run the command sequentially in the named projects
+// every project is executed by a separate zeus process inside the project directory
+func (w *workspace) run(names []string, command []string) (results []*workspaceResult) {
+
+	executable, err := os.Executable()
+	if err != nil {
+		executable = os.Args[0]
+	}
+
+	for _, name := range names {
+
+		l.Println(cp.colorText + "[" + cp.colorPrompt + name + cp.colorText + "] " + strings.Join(command, " ") + ansi.Reset)
+
+		var (
+			start = time.Now()
+			cmd   = exec.Command(executable, command...)
+		)
+
+		// the projects are inspected as well
+		if inspectMode {
+			cmd.Args = append([]string{executable, readOnlyFlag}, command...)
+		}
+
+		cmd.Dir = w.Projects[name]
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		err := cmd.Run()
+		results = append(results, &workspaceResult{
+			project:  name,
+			err:      err,
+			duration: time.Now().Sub(start),
+		})
+	}
+
+	return
+}
+
+// print the combined results for a workspace run
+// returns the number of failed projects
+func printWorkspaceSummary(results []*workspaceResult) (failed int) {
+
+	var maxLen int
+	for _, r := range results {
+		if len(r.project) > maxLen {
+			maxLen = len(r.project)
+		}
+	}
+
+	l.Println("")
+	l.Println(cp.colorText + "summary:")
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			l.Println(cp.colorText+"├~» "+cp.colorCommandName+pad(r.project, maxLen+1)+ansi.Red+"FAILED "+cp.colorText, r.duration, "("+r.err.Error()+")")
+		} else {
+			l.Println(cp.colorText+"├~» "+cp.colorCommandName+pad(r.project, maxLen+1)+ansi.Green+"OK     "+cp.colorText, r.duration)
+		}
+	}
+	l.Println(cp.colorText+"└~» ", len(results)-failed, "succeeded,", failed, "failed"+ansi.Reset)
+
+	return
+}
@@ -24,13 +24,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/fatih/color"
 	"github.com/mgutz/ansi"
 
 	rice "github.com/GeertJohan/go.rice"
-	"github.com/chzyer/readline"
 	prefixed "github.com/x-cray/logrus-prefixed-formatter"
 )
 
@@ -49,12 +49,19 @@ var (
 	commands     = make(map[string]*command, 0)
 	commandMutex = &sync.Mutex{}
 
+	// synchronizes resolving command chains
+	chainMutex = &sync.Mutex{}
+
 	// process instances for all spawned commands, for cleaning up when we leave
-	processMap = make(map[string]*os.Process, 0)
+	processMap  = make(map[string]*os.Process, 0)
+	processLock = &sync.Mutex{}
 
-	// readline auto completion
+	// readline auto completion for builtins
 	completer = newCompleter()
 
+	// readline auto completion for commands and aliases
+	commandIndex = newCompletionIndex()
+
 	// assets folder
 	assetBox = rice.MustFindBox("assets")
 
@@ -86,31 +93,74 @@ func init() {
 
 	// set up formatter
 	Log.Formatter = new(prefixed.TextFormatter)
+
+	// translate the log messages into the selected language
+	Log.Hooks.Add(localizeHook{})
+	Log.Hooks.Add(errorCountHook{})
 }
 
 func main() {
 
 	var cLog = Log.WithField("prefix", "main")
 
+	// write a crash report instead of dying with a mangled terminal
+	defer recoverPanic()
+
+	// enable colored log output on windows consoles
+	// and keep secrets out of the log messages
+	Log.Out = &maskingWriter{stderr}
+
+	// fatal errors run the shutdown sequence as well
+	logrus.RegisterExitHandler(fatalExit)
+
+	// release the project lock and persist the project data when main returns
+	defer cleanup()
+
+	// start profiling zeus itself if requested
+	// the early returns flush the profile as well
+	handleProfileFlag()
+	defer stopSelfProfile()
+
+	// the flags in front of the command can be combined in any order
+	for n := 0; n != len(os.Args); {
+		n = len(os.Args)
+
+		// change into a workspace project if requested
+		handleProjectFlag()
+
+		// continue chains after failures if requested
+		handleKeepGoingFlag()
+
+		// browse the project without executing or modifying anything if requested
+		handleReadOnlyFlag()
+	}
+
+	// workspace commands do not need a zeus directory
+	if len(os.Args) > 1 && os.Args[1] == workspaceCommand {
+		cp = defaultProfile()
+		handleWorkspaceCommand(os.Args[1:])
+		return
+	}
+
 	// check if zeus directory exists
 	stat, err := os.Stat(zeusDir)
 	if err != nil {
 		if len(os.Args) > 1 {
-			if os.Args[1] == "bootstrap" {
+			if os.Args[1] == "bootstrap" && !inspectMode {
 				bootstrapCommand()
 				return
 			}
 		}
 
 		if len(os.Args) > 2 {
-			if os.Args[1] == "makefile" && os.Args[2] == "migrate" {
+			if os.Args[1] == "makefile" && os.Args[2] == "migrate" && !inspectMode {
 				migrateMakefile()
 				return
 			}
 		}
 		cLog.WithError(err).Error("zeus directory does not exist!")
 		cLog.Info("run 'zeus bootstrap' to create a default one, or 'zeus makefile migrate' if you want to migrate from a GNU Makefile.")
-		os.Exit(1)
+		shutdown(1)
 	}
 
 	// make sure its a directory
@@ -118,8 +168,35 @@ func main() {
 		cLog.Fatal("zeus is not a directory")
 	}
 
+	// scripts need a shell interpreter, fail before anything is started
+	// inspection mode never executes them
+	if !inspectMode {
+		if err := checkShell(); err != nil {
+			cLog.WithError(err).Error("cannot execute the zeus scripts")
+			shutdown(1)
+		}
+	}
+
+	// pass the command to the project daemon if there is one
+	// builtins are always handled by the current process
+	if len(os.Args) > 1 && !inspectMode {
+		if _, ok := builtins[os.Args[1]]; !ok || os.Args[1] == queueCommand {
+			if ok, code := runOnDaemon(os.Args[1:]); ok {
+				shutdown(code)
+			}
+		}
+	}
+
+	// make sure no other instance modifies the project at the same time
+	// inspection mode never modifies the project and does not need the lock
+	if !inspectMode {
+		acquireProjectLock()
+	}
+
 	clearScreen()
 
+	doneConfig := profilePhase("config")
+
 	// look for project config
 	conf, err = parseProjectConfig()
 	if err != nil {
@@ -136,6 +213,9 @@ func main() {
 		}
 	}
 
+	doneConfig()
+	doneData := profilePhase("project data")
+
 	// look for project data
 	projectData, err = parseProjectData()
 	if err != nil {
@@ -143,8 +223,52 @@ func main() {
 		projectData = newData()
 	}
 
+	// load the checksums of the approved scripts
+	loadPins()
+
+	doneData()
+
+	// the manifest must be created before the scripts can be verified
+	if len(os.Args) > 1 && os.Args[1] == signaturesCommand {
+		cp = defaultProfile()
+		handleSignaturesCommand(os.Args[1:])
+		return
+	}
+
+	// verify the provenance of the scripts before loading anything
+	if conf.SignatureVerification != "" {
+		err = verifySignatures()
+		if err != nil {
+			cLog.WithError(err).Fatal("failed to verify the signatures of the zeus scripts")
+		}
+	}
+
+	// validate before anything else can fail on an invalid project
+	if len(os.Args) > 1 && os.Args[1] == validateCommand {
+
+		// the commands are not loaded yet, chains can reference the commands generated by the config script
+		_, err = generateCommands()
+		if err != nil {
+			cLog.WithError(err).Error("failed to generate the commands of the config script")
+		}
+
+		if handleValidateCommand() != nil || err != nil {
+			shutdown(1)
+		}
+		return
+	}
+	doneEvents := profilePhase("event registration")
+
 	// load persisted events from project data
-	loadEvents()
+	// events would execute commands, so there are no watchers in inspection mode
+	if !inspectMode {
+		loadEvents()
+	}
+
+	doneEvents()
+
+	// validate typed globals
+	validateGlobals()
 
 	// validate aliases
 	for name := range projectData.Aliases {
@@ -154,7 +278,7 @@ func main() {
 		}
 
 		// add to completer
-		completer.Children = append(completer.Children, readline.PcItem(name, nil))
+		commandIndex.add(name)
 	}
 
 	// get debug value from config
@@ -184,7 +308,7 @@ func main() {
 		}
 	}
 
-	if conf.LogToFile || conf.LogToFileColor {
+	if (conf.LogToFile || conf.LogToFileColor) && !inspectMode {
 		f, err := logToFile()
 		if err != nil {
 			cLog.WithError(err).Fatal("failed to set up logging to file")
@@ -224,18 +348,25 @@ func main() {
 		l.Println(cp.colorText + "BuildNumber: " + cp.colorPrompt + strconv.Itoa(projectData.BuildNumber) + cp.colorText + "\n")
 	}
 
+	doneWatchers := profilePhase("watchers")
+
 	// start watchers when running in interactive mode
 	if conf.Interactive {
 
 		// watch config for changes
 		go conf.watch()
 
-		if conf.AutoFormat {
+		// receive the events of the peers
+		go listenEventBus()
+
+		if conf.AutoFormat && !readOnly {
 			// watch zeus directory for changes
 			go f.watchzeusDir()
 		}
 	}
 
+	doneWatchers()
+
 	printDeadline()
 	listMilestones()
 
@@ -247,12 +378,32 @@ func main() {
 	// create commandList
 	findCommands()
 
+	// load the plugins after the commands, their builtins must not shadow them
+	loadPlugins()
+
+	// keep the exported documentation in sync with the commands
+	updateHelpExport()
+
+	// startup is complete
+	stopSelfProfile()
+
+	// handle OS Signals
+	// all child processes need to be killed when theres an error
+	handleSignals(len(os.Args) == 1 && conf.Interactive)
+
 	if len(os.Args) > 1 {
 
 		var validCommand bool
 
+		// only the errors of the builtin make it fail
+		atomic.StoreInt64(&loggedErrors, 0)
+
 		switch os.Args[1] {
 		case helpCommand:
+			if len(os.Args) > 2 {
+				handleHelpCommand(os.Args[1:])
+				return
+			}
 			if conf.PrintBuiltins {
 				printBuiltins()
 			}
@@ -260,8 +411,8 @@ func main() {
 
 		case formatCommand:
 			f.formatCommand()
-		case "data":
-			printProjectData()
+		case dataCommand:
+			handleDataCommand(os.Args[1:])
 
 		case aliasCommand:
 			if len(os.Args) == 2 {
@@ -303,8 +454,79 @@ func main() {
 		case makefileCommand:
 			handleMakefileCommand(os.Args[1:])
 
+		case bumpCommand:
+			handleBumpCommand(os.Args[1:])
+
+		case globalsCommand:
+			handleGlobalsCommand(os.Args[1:])
+
+		case daemonCommand:
+			handleDaemonCommand(os.Args[1:])
+
+		case benchCommand:
+			handleBenchmarkCommand(os.Args[1:])
+
+		case watchCommand:
+			handleWatchCommand(os.Args[1:])
+
+		case queueCommand:
+			// the queue of the daemon is shown if there is one
+			Log.Info("no daemon is running, the queue is only available in the daemon and the interactive shell")
+
+		case gcCommand:
+			if handleGCCommand(os.Args[1:]) != nil {
+				shutdown(1)
+			}
+
+		case snapshotCommand:
+			if handleSnapshotCommand(os.Args[1:]) != nil {
+				shutdown(1)
+			}
+
+		case replayCommand:
+			if handleReplayCommand(os.Args[1:]) != nil {
+				shutdown(1)
+			}
+
+		case cleanCommand:
+			if handleCleanCommand(os.Args[1:]) != nil {
+				shutdown(1)
+			}
+
+		case busCommand:
+			handleBusCommand(os.Args[1:])
+
+		case usageCommand:
+			if handleUsageCommand(os.Args[1:]) != nil {
+				shutdown(1)
+			}
+
+		case doctorCommand:
+			if handleDoctorCommand() != nil {
+				shutdown(1)
+			}
+
+		case coverageCommand:
+			if err := handleCoverageCommand(os.Args[1:]); err != nil {
+				shutdown(1)
+			}
+
+		case uiCommand:
+			if err := handleUICommand(); err != nil {
+				cLog.WithError(err).Error("failed to start the dashboard")
+				shutdown(1)
+			}
+
 		default:
 
+			// check if its a builtin of a plugin
+			if p, ok := pluginBuiltins[os.Args[1]]; ok {
+				if err := p.runBuiltin(os.Args[1:]); err != nil {
+					shutdown(1)
+				}
+				return
+			}
+
 			// check if the command exists
 			if cmd, ok := commands[os.Args[1]]; ok {
 
@@ -313,26 +535,37 @@ func main() {
 
 				err := cmd.Run(os.Args[2:])
 				if err != nil {
-					cLog.WithError(err).Fatal("failed to execute " + cmd.name)
+					cLog.WithError(err).Error("failed to execute " + cmd.name)
+					shutdown(exitCode(err))
 				}
 			}
 
 			// check if its a commandchain supplied with "" or ''
 			if strings.Contains(os.Args[1], p.separator) {
-				executeCommandChain(strings.Join(os.Args[1:], " "))
+				if err := executeCommandChain(strings.Join(os.Args[1:], " ")); err != nil {
+					shutdown(exitCode(err))
+				}
 				return
 			}
 
 			// check if its an alias
 			if command, ok := projectData.Aliases[os.Args[1]]; ok {
-				executeCommand(command)
+				if err := executeCommand(command); err != nil {
+					shutdown(exitCode(err))
+				}
 				return
 			}
 
 			if !validCommand {
-				cLog.Fatal("unknown command: ", os.Args[1])
+				cLog.Error("unknown command: ", os.Args[1])
+				shutdown(exitUsageError)
 			}
 		}
+
+		// builtins fail when they logged an error, like the ones returning an error
+		if !validCommand && atomic.LoadInt64(&loggedErrors) > 0 {
+			shutdown(1)
+		}
 		return
 	}
 
@@ -344,10 +577,6 @@ func main() {
 			zeusPrompt = filepath.Base(workingDir)
 		}
 
-		// handle OS Signals
-		// all child processes need to be killed when theres an error
-		handleSignals()
-
 		// start interactive mode and start reading from stdin
 		err = readlineLoop()
 		if err != nil {